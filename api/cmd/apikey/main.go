@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/go-playground/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// issues and revokes api keys straight against the database, this is how the first admin key is made
+//
+//	go run ./cmd/apikey create -name ci -scopes email:send,email:read -expires 2160h -workspace marketing
+//	go run ./cmd/apikey create -name intern -role viewer -workspace marketing
+//	go run ./cmd/apikey create -name reviewer -scopes email:read,email:approve -owner lead@example.com -workspace marketing
+//	go run ./cmd/apikey list -workspace marketing
+//	go run ./cmd/apikey revoke <id>
+func main() {
+	// the environment may also come from the shell
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	db, err := sqlx.Connect("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fail(fmt.Errorf("unable to connect to database, err: %s", err.Error()))
+	}
+	defer db.Close()
+
+	// recorded without a key, as made from the command line, which never checks a stream token
+	usecase := apikey.NewUsecase(db, apikey.NewRepository(), audit.NewRecorder(audit.NewRepository()), config.Config{})
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "create":
+		create(ctx, usecase, os.Args[2:])
+	case "list":
+		list(ctx, usecase, os.Args[2:])
+	case "revoke":
+		if len(os.Args) != 3 {
+			usage()
+		}
+
+		if _, err := usecase.RevokeApiKey(ctx, os.Args[2]); err != nil {
+			fail(err)
+		}
+		fmt.Printf("api key %s revoked\n", os.Args[2])
+	default:
+		usage()
+	}
+}
+
+func create(ctx context.Context, usecase apikey.ApiKeyUsecase, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "name of the key")
+	role := flags.String("role", "", "role of the key: viewer, sender, template_editor or admin")
+	scopes := flags.String("scopes", "", "comma separated scopes on top of the role")
+	expires := flags.Duration("expires", 0, "lifetime of the key, it never expires when zero")
+	workspace := flags.String("workspace", models.DefaultWorkspaceId, "workspace the key belongs to")
+	senders := flags.String("senders", "", "comma separated senders the key may send as, every sender when empty")
+	owner := flags.String("owner", "", "email address of the person the key is issued to, required to approve batches")
+	flags.Parse(args)
+
+	if *name == "" {
+		fail(fmt.Errorf("name is required"))
+	}
+
+	// the first key is usually an admin key
+	if *role == "" && *scopes == "" {
+		*role = models.RoleAdmin
+	}
+
+	request := models.PostApiKeyRequest{
+		WorkspaceId: *workspace,
+		Name:        *name,
+		Role:        *role,
+		Owner:       *owner,
+	}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			request.Scopes = append(request.Scopes, scope)
+		}
+	}
+	for _, sender := range strings.Split(*senders, ",") {
+		if sender = strings.TrimSpace(sender); sender != "" {
+			request.SenderIds = append(request.SenderIds, sender)
+		}
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires)
+		request.ExpiresAt = &expiresAt
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		fail(err)
+	}
+
+	result, _, err := usecase.CreateApiKey(ctx, request)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Printf("id:        %s\n", result.Id)
+	fmt.Printf("workspace: %s\n", result.WorkspaceId)
+	fmt.Printf("role:      %s\n", result.Role)
+	fmt.Printf("owner:     %s\n", formatString(result.Owner))
+	fmt.Printf("scopes:    %s\n", strings.Join(result.Scopes, ","))
+	fmt.Printf("key:       %s\n", result.Key)
+	fmt.Println("the key is not shown again, store it now")
+}
+
+func list(ctx context.Context, usecase apikey.ApiKeyUsecase, args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	workspace := flags.String("workspace", "", "only list the keys of this workspace")
+	flags.Parse(args)
+
+	results, _, err := usecase.GetApiKeys(ctx, models.GetApiKeyRequest{
+		WorkspaceId: *workspace,
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWORKSPACE\tNAME\tOWNER\tPREFIX\tROLE\tSCOPES\tEXPIRES\tLAST USED\tREVOKED")
+	for _, item := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.Id, item.WorkspaceId, item.Name, formatString(item.Owner), item.Prefix, formatString(item.Role), strings.Join(item.Scopes, ","),
+			formatTime(item.ExpiresAt), formatTime(item.LastUsedAt), formatTime(item.RevokedAt),
+		)
+	}
+	w.Flush()
+}
+
+func formatString(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return "-"
+	}
+
+	return value.Format(time.RFC3339)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikey create -name NAME [-role ROLE] [-scopes a,b] [-expires 720h] [-workspace ID] [-senders a,b] | list [-workspace ID] | revoke ID")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
@@ -4,10 +4,19 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/abbyfakhri/toa-api/cmd/server"
+	"github.com/abbyfakhri/toa-api/internal/config"
 	"github.com/abbyfakhri/toa-api/internal/services/email"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	// time zones of scheduled batches must load on hosts without a zoneinfo database
+	_ "time/tzdata"
 )
 
 func main() {
@@ -15,12 +24,29 @@ func main() {
 		panic("no environment variable found")
 	}
 
+	// unsubscribe and tracking links are signed with it, every email carries them
+	if os.Getenv("TOKEN_SECRET") == "" {
+		panic("TOKEN_SECRET is required to sign unsubscribe and tracking links")
+	}
+
+	db, err := sqlx.Connect("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		panic(fmt.Sprintf("unable to connect to database, err: %s", err.Error()))
+	}
+
+	defer db.Close()
+
 	emailClient, err := email.NewClient(email.EmailConfig{
 		EmailFrom:     os.Getenv("EMAIL_FROM"),
 		EmailPassword: os.Getenv("EMAIL_PASSWORD"),
 		SMTPHost:      os.Getenv("SMTP_HOST"),
 		SMTPPort:      os.Getenv("SMTP_PORT"),
 		EmailAlias:    os.Getenv("EMAIL_ALIAS"),
+
+		DKIMSelector:       os.Getenv("DKIM_SELECTOR"),
+		DKIMDomain:         os.Getenv("DKIM_DOMAIN"),
+		DKIMPrivateKeyFile: os.Getenv("DKIM_PRIVATE_KEY_FILE"),
+		DKIMHeaders:        getEnvList("DKIM_HEADERS"),
 	})
 
 	if err != nil {
@@ -31,10 +57,48 @@ func main() {
 
 	defer emailClient.Quit()
 
+	// VERP addresses default to plus addressing on the sender mailbox
+	verpPrefix, verpDomain := utils.SplitAddress(os.Getenv("EMAIL_FROM"))
+
 	server := server.NewServer(server.ServerConfig{
 		Port:        os.Getenv("PORT"),
-		Db:          nil,
+		Db:          db,
 		EmailClient: emailClient,
+		App: config.Config{
+			BaseURL:          os.Getenv("BASE_URL"),
+			TokenSecret:      os.Getenv("TOKEN_SECRET"),
+			ScheduleInterval: getEnvDuration("SCHEDULE_INTERVAL", time.Minute),
+			ReportInterval:   getEnvDuration("REPORT_INTERVAL", 10*time.Minute),
+			Bounce: config.BounceConfig{
+				Protocol:     os.Getenv("BOUNCE_PROTOCOL"),
+				Host:         os.Getenv("BOUNCE_HOST"),
+				Port:         os.Getenv("BOUNCE_PORT"),
+				Username:     getEnvOr("BOUNCE_USERNAME", os.Getenv("EMAIL_FROM")),
+				Password:     getEnvOr("BOUNCE_PASSWORD", os.Getenv("EMAIL_PASSWORD")),
+				TLS:          getEnvBool("BOUNCE_TLS", true),
+				Folder:       os.Getenv("BOUNCE_FOLDER"),
+				PollInterval: getEnvDuration("BOUNCE_POLL_INTERVAL", 5*time.Minute),
+			},
+			Webhook: config.WebhookConfig{
+				ProgressInterval: getEnvDuration("WEBHOOK_PROGRESS_INTERVAL", time.Minute),
+				Timeout:          getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+				MaxAttempts:      getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+				AllowPrivate:     getEnvBool("WEBHOOK_ALLOW_PRIVATE", false),
+			},
+			Relay: config.RelayConfig{
+				FailureThreshold: getEnvInt("RELAY_FAILURE_THRESHOLD", 3),
+				Cooldown:         getEnvDuration("RELAY_COOLDOWN", time.Minute),
+			},
+			Approval: config.ApprovalConfig{
+				Threshold: getEnvInt("APPROVAL_THRESHOLD", 0),
+			},
+			IdempotencyWindow: getEnvDuration("IDEMPOTENCY_WINDOW", 24*time.Hour),
+			Verp: config.VerpConfig{
+				Enabled: getEnvBool("VERP_ENABLED", false),
+				Prefix:  getEnvOr("VERP_PREFIX", verpPrefix),
+				Domain:  getEnvOr("VERP_DOMAIN", verpDomain),
+			},
+		},
 	})
 
 	_, err = server.Start()
@@ -46,6 +110,52 @@ func main() {
 
 }
 
+func getEnvOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func getEnvList(key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
 func test(client email.EmailClient) error {
 	err := client.SendMail(email.Email{
 		To:      "abby.kuliah@gmail.com",
@@ -3,7 +3,10 @@ package server
 import (
 	"net/http"
 
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
 	"github.com/abbyfakhri/toa-api/internal/services"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
 	"github.com/abbyfakhri/toa-api/internal/services/email"
 	"github.com/go-playground/validator"
 	"github.com/jmoiron/sqlx"
@@ -15,6 +18,7 @@ type ServerConfig struct {
 	Port        string
 	Db          *sqlx.DB
 	EmailClient email.EmailClient
+	App         config.Config
 }
 
 type Server struct {
@@ -29,6 +33,8 @@ func NewServer(cfg ServerConfig) Server {
 
 func (s *Server) Start() (*echo.Echo, error) {
 	e := echo.New()
+	// the audit log keeps the address of the peer, a forwarded for header is set by the client and can't be trusted
+	e.IPExtractor = echo.ExtractIPDirect()
 	e.Validator = &customValidator{
 		validator: validator.New(),
 	}
@@ -48,10 +54,19 @@ func (s *Server) Start() (*echo.Echo, error) {
 			echo.HeaderContentType,
 			echo.HeaderAccept,
 			echo.HeaderAuthorization,
+			echo.HeaderXRequestID,
+			models.IdempotencyKeyHeader,
+		},
+		ExposeHeaders: []string{
+			echo.HeaderXRequestID,
+			echo.HeaderRetryAfter,
 		},
 	}))
+	// the audit log ties every change to the request that made it
+	e.Use(middleware.RequestID())
+	e.Use(audit.Middleware)
 
-	services.LoadServices(e, s.cfg.Db, s.cfg.EmailClient)
+	services.LoadServices(e, s.cfg.Db, s.cfg.EmailClient, s.cfg.App)
 
 	if err := e.Start(":" + s.cfg.Port); err != nil {
 		return nil, err
@@ -0,0 +1,79 @@
+package config
+
+import "time"
+
+// Config holds settings shared by the services that are not tied to a single client
+type Config struct {
+	// public url of this api, used to build the links we embed in emails
+	BaseURL string
+	// secret used to sign the tokens we embed in those links
+	TokenSecret string
+	// how often scheduled batches are checked for a send time that has come
+	ScheduleInterval time.Duration
+	// how often a running batch emails a progress report to its reportTo address
+	ReportInterval time.Duration
+	// how long an Idempotency-Key is remembered, a request reusing it after that sends a new batch
+	IdempotencyWindow time.Duration
+
+	Bounce  BounceConfig
+	Verp    VerpConfig
+	Webhook WebhookConfig
+	Relay   RelayConfig
+
+	Approval ApprovalConfig
+}
+
+// ApprovalConfig controls which batches wait in pending_approval until an approver lets them through
+// senders can also require approval for every batch sent as them
+type ApprovalConfig struct {
+	// batches with more recipients than this need approval, never when zero
+	Threshold int
+}
+
+// RelayConfig controls the circuit breakers of the smtp relays
+// a relay that fails FailureThreshold times in a row is left out of rotation for Cooldown,
+// then tried again with the next message
+type RelayConfig struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// WebhookConfig controls how batch events are delivered to webhooks
+type WebhookConfig struct {
+	// how often a sending batch reports its progress
+	ProgressInterval time.Duration
+	// per delivery attempt
+	Timeout time.Duration
+	// a delivery is given up after this many attempts
+	MaxAttempts int
+	// lets webhooks and the api urls of senders reach loopback and private addresses, for local development only
+	AllowPrivate bool
+}
+
+// VerpConfig turns on variable envelope return paths
+// every message gets its own envelope sender, e.g. bounces+42-<tag>@example.com for email 42,
+// so a bounce can be tied to the exact email even when it quotes nothing useful
+// the tag is signed with TokenSecret, VERP stays off while it is empty
+type VerpConfig struct {
+	Enabled bool
+	// local part before the +, usually the local part of the sender address
+	Prefix string
+	// usually the domain of the sender address, so bounces land in the same mailbox
+	Domain string
+}
+
+// BounceConfig describes the mailbox asynchronous bounces are delivered to
+// the bounce processor is disabled when Host is empty
+type BounceConfig struct {
+	// imap or pop3
+	Protocol string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// use implicit TLS, turn it off to talk to a local stand-in server
+	TLS bool
+	// imap folder to read, defaults to INBOX
+	Folder       string
+	PollInterval time.Duration
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ScopeEmailSend        = "email:send"
+	ScopeEmailRead        = "email:read"
+	ScopeEmailManage      = "email:manage"
+	ScopeSuppressionRead  = "suppression:read"
+	ScopeSuppressionWrite = "suppression:write"
+	ScopeWebhookManage    = "webhook:manage"
+	ScopeTemplateManage   = "template:manage"
+	ScopeEmailApprove     = "email:approve"
+	// every scope, and managing the api keys themselves
+	// admins of the default workspace also manage the workspaces
+	ScopeAdmin = "admin"
+)
+
+// roles are named sets of scopes, a key gets the scopes of its role on top of its own
+const (
+	RoleViewer         = "viewer"
+	RoleSender         = "sender"
+	RoleTemplateEditor = "template_editor"
+	RoleAdmin          = "admin"
+)
+
+var RoleScopes = map[string][]string{
+	RoleViewer:         {ScopeEmailRead, ScopeSuppressionRead},
+	RoleSender:         {ScopeEmailRead, ScopeSuppressionRead, ScopeEmailSend},
+	RoleTemplateEditor: {ScopeEmailRead, ScopeSuppressionRead, ScopeTemplateManage},
+	RoleAdmin:          {ScopeAdmin},
+}
+
+// impliedScopes keeps the keys issued before a scope was split out of another working as before
+var impliedScopes = map[string][]string{
+	ScopeEmailManage: {ScopeTemplateManage},
+}
+
+type ApiKey struct {
+	Id          string         `json:"id" db:"id"`
+	WorkspaceId string         `json:"workspaceId" db:"workspace_id"`
+	Name        string         `json:"name" db:"name"`
+	Prefix      string         `json:"prefix" db:"prefix"`
+	KeyHash     string         `json:"-" db:"key_hash"`
+	Scopes      pq.StringArray `json:"scopes" db:"scopes"`
+	ExpiresAt   *time.Time     `json:"expiresAt" db:"expires_at"`
+	LastUsedAt  *time.Time     `json:"lastUsedAt" db:"last_used_at"`
+	RevokedAt   *time.Time     `json:"revokedAt" db:"revoked_at"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+	// the senders the key may send as, every sender of the workspace when empty
+	SenderIds pq.StringArray `json:"senderIds" db:"sender_ids"`
+	Role      string         `json:"role" db:"role"`
+	// the person the key is issued to, only keys with an owner can decide a batch
+	Owner string `json:"owner" db:"owner"`
+}
+
+// HasScope reports whether the key grants scope, through its role or its own scopes, admin grants everything
+func (k ApiKey) HasScope(scope string) bool {
+	for _, item := range k.Grants() {
+		if item == scope || item == ScopeAdmin {
+			return true
+		}
+
+		for _, implied := range impliedScopes[item] {
+			if implied == scope {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Grants returns the scopes of the key's role followed by its own
+func (k ApiKey) Grants() []string {
+	return append(append([]string{}, RoleScopes[k.Role]...), k.Scopes...)
+}
+
+// CanUseSender reports whether the key may send as the sender
+func (k ApiKey) CanUseSender(senderId string) bool {
+	if len(k.SenderIds) == 0 {
+		return true
+	}
+
+	for _, item := range k.SenderIds {
+		if item == senderId {
+			return true
+		}
+	}
+
+	return false
+}
+
+type GetApiKeyRequest struct {
+	// only the admins of the default workspace can list another workspace
+	WorkspaceId string `query:"workspaceId"`
+}
+
+type PostApiKeyRequest struct {
+	// only the admins of the default workspace can issue keys for another workspace, defaults to the caller's
+	WorkspaceId string     `json:"workspaceId"`
+	Name        string     `json:"name" validate:"required"`
+	Scopes      []string   `json:"scopes" validate:"required_without=Role,dive,oneof=email:send email:read email:manage email:approve template:manage suppression:read suppression:write webhook:manage admin"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	// restricts the key to these senders
+	SenderIds []string `json:"senderIds"`
+	// a key needs a role, scopes or both
+	Role string `json:"role" validate:"required_without=Scopes,omitempty,oneof=viewer sender template_editor admin"`
+	// email address of the person the key is issued to
+	Owner string `json:"owner" validate:"omitempty,email"`
+}
+
+// PostApiKeyResponse is the only time the key is shown
+type PostApiKeyResponse struct {
+	ApiKey
+	Key string `json:"key"`
+}
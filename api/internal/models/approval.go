@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	ApprovalDecisionApproved = "approved"
+	ApprovalDecisionRejected = "rejected"
+)
+
+// BatchApproval is a decision on a batch that waited for approval, they are only ever added
+type BatchApproval struct {
+	Id          string `json:"id" db:"id"`
+	BatchId     string `json:"batchId" db:"batch_id"`
+	WorkspaceId string `json:"workspaceId" db:"workspace_id"`
+	// the api key of the approver, empty for calls made without a key
+	ApiKeyId  *string   `json:"apiKeyId" db:"api_key_id"`
+	Decision  string    `json:"decision" db:"decision"`
+	Comment   string    `json:"comment" db:"comment"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// GetBatchApprovalResponse is what an approver reviews before deciding
+type GetBatchApprovalResponse struct {
+	Batch EmailBatch `json:"batch"`
+	// recipients the batch would send to, suppressed ones left out
+	RecipientCount int `json:"recipientCount"`
+	// the content rendered for the first recipient, empty when there is none
+	Sample    *BatchSample    `json:"sample"`
+	Decisions []BatchApproval `json:"decisions"`
+}
+
+type BatchSample struct {
+	To      string `json:"to"`
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Html    string `json:"html"`
+}
+
+type PostBatchApprovalRequest struct {
+	// required to reject
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionImport  = "import"
+	AuditActionCancel  = "cancel"
+	AuditActionPause   = "pause"
+	AuditActionResume  = "resume"
+	AuditActionApprove = "approve"
+	AuditActionReject  = "reject"
+	AuditActionRevoke  = "revoke"
+)
+
+const (
+	AuditResourceBatch       = "batch"
+	AuditResourceTemplate    = "template"
+	AuditResourceSuppression = "suppression"
+	AuditResourceApiKey      = "api_key"
+	AuditResourceWorkspace   = "workspace"
+	AuditResourceSender      = "sender"
+	AuditResourceRelay       = "relay"
+	AuditResourceQuota       = "quota"
+	AuditResourceWebhook     = "webhook"
+)
+
+// AuditEntry records one change, entries are never updated or deleted
+type AuditEntry struct {
+	Id          int64  `json:"id" db:"id"`
+	WorkspaceId string `json:"workspaceId" db:"workspace_id"`
+	// the api key the change was made with, empty for the command line and the changes the api makes on its own,
+	// those are named "system: <name>"
+	ApiKeyId   *string `json:"apiKeyId" db:"api_key_id"`
+	ApiKeyName string  `json:"apiKeyName" db:"api_key_name"`
+	Ip         string  `json:"ip" db:"ip"`
+	RequestId  string  `json:"requestId" db:"request_id"`
+	Action     string  `json:"action" db:"action"`
+	Resource   string  `json:"resource" db:"resource"`
+	ResourceId string  `json:"resourceId" db:"resource_id"`
+	// the fields that changed, as they were and as they became, null when the resource did not exist
+	Before    AuditState `json:"before" db:"before"`
+	After     AuditState `json:"after" db:"after"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// AuditState holds fields of a resource keyed by their json name, stored as a jsonb object, nil is stored as NULL
+type AuditState map[string]any
+
+// Value implements driver.Valuer.
+func (s AuditState) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *AuditState) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, s)
+	case string:
+		return json.Unmarshal([]byte(value), s)
+	default:
+		return fmt.Errorf("unsupported audit state type: %T", src)
+	}
+}
+
+type GetAuditRequest struct {
+	// only the admins of the default workspace can read another workspace
+	WorkspaceId string `query:"workspaceId"`
+	Action      string `query:"action"`
+	Resource    string `query:"resource"`
+	ResourceId  string `query:"resourceId"`
+	ApiKeyId    string `query:"apiKeyId"`
+	// RFC 3339, both ends are optional
+	From   string `query:"from"`
+	To     string `query:"to"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
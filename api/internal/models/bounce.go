@@ -0,0 +1,18 @@
+package models
+
+const (
+	BounceTypeHard = "hard"
+	BounceTypeSoft = "soft"
+)
+
+// Bounce is a single failed recipient parsed from a delivery status notification
+type Bounce struct {
+	Recipient  string `json:"recipient"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	Diagnostic string `json:"diagnostic"`
+	// Message-ID of the message that bounced, used to find the original email
+	MessageId string `json:"messageId"`
+	// addresses the bounce was delivered to, a VERP return path shows up here
+	DeliveredTo []string `json:"deliveredTo"`
+}
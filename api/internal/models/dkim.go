@@ -0,0 +1,18 @@
+package models
+
+type PostDkimVerifyRequest struct {
+	// PEM, a DNS TXT record such as "v=DKIM1; k=rsa; p=..." or the bare p= value
+	PublicKey string `json:"publicKey" validate:"required"`
+	// raw signed message to check, a sample signed with our key is used when empty
+	Message string `json:"message"`
+	// signs the sample as this sender, as the workspace when empty
+	SenderId string `json:"senderId"`
+}
+
+type DkimVerifyResponse struct {
+	Valid     bool   `json:"valid"`
+	Error     string `json:"error,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// TXT record matching the configured private key
+	ExpectedRecord string `json:"expectedRecord,omitempty"`
+}
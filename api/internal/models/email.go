@@ -1,45 +1,283 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	EmailStatusPending    = "pending"
+	EmailStatusSent       = "sent"
+	EmailStatusFailed     = "failed"
+	EmailStatusSuppressed = "suppressed"
+	EmailStatusBounced    = "bounced"
+	EmailStatusCanceled   = "canceled"
+)
+
+const (
+	// waiting for an approver, see BatchApproval
+	BatchStatusPendingApproval = "pending_approval"
+	// waiting for send_at
+	BatchStatusScheduled = "scheduled"
+	// waiting for a worker
+	BatchStatusQueued  = "queued"
+	BatchStatusSending = "sending"
+	// started, then held back by a sending quota until send_at, it is not listed or rescheduled as a scheduled batch
+	BatchStatusDeferred = "deferred"
+	// stopped between two recipients, resumes from the first pending one
+	BatchStatusPaused    = "paused"
+	BatchStatusCompleted = "completed"
+	BatchStatusCanceled  = "canceled"
+)
 
 type EmailBatch struct {
-	Id           string    `json:"id" db:"id"`
-	From         string    `json:"from" db:"from"`
-	EmailCount   int       `json:"emailCount" db:"email_count"`
-	SuccessCount int       `json:"successCount" db:"success_count"`
-	FailCount    int       `json:"failCount" db:"fail_count"`
-	StartAt      time.Time `json:"startAt" db:"start_at"`
-	FinishAt     time.Time `json:"finishAt" db:"finish_at"`
+	Id          string `json:"id" db:"id"`
+	WorkspaceId string `json:"workspaceId" db:"workspace_id"`
+	Status      string `json:"status" db:"status"`
+	// the batch this one retries
+	ParentId *string `json:"parentId" db:"parent_id"`
+	// the api key the batch was created with
+	ApiKeyId *string `json:"apiKeyId" db:"api_key_id"`
+	// owner of that key, the same person cannot approve the batch with another key
+	RequestedBy string `json:"requestedBy" db:"requested_by"`
+	// sent as the workspace sender when empty
+	SenderId        *string `json:"senderId" db:"sender_id"`
+	From            string  `json:"from" db:"from"`
+	Subject         string  `json:"subject" db:"subject"`
+	Body            string  `json:"body" db:"body"`
+	Template        string  `json:"template" db:"template"`
+	EmailCount      int     `json:"emailCount" db:"email_count"`
+	SuccessCount    int     `json:"successCount" db:"success_count"`
+	FailCount       int     `json:"failCount" db:"fail_count"`
+	SuppressedCount int     `json:"suppressedCount" db:"suppressed_count"`
+	CanceledCount   int     `json:"canceledCount" db:"canceled_count"`
+	PendingCount    int     `json:"pendingCount" db:"pending_count"`
+	BounceCount     int     `json:"bounceCount" db:"bounce_count"`
+	TrackOpens      bool    `json:"trackOpens" db:"track_opens"`
+	TrackClicks     bool    `json:"trackClicks" db:"track_clicks"`
+	UtmParams       `json:"utm"`
+	WebhookUrl      string `json:"webhookUrl" db:"webhook_url"`
+	WebhookSecret   string `json:"-" db:"webhook_secret"`
+	ReportTo        string `json:"reportTo" db:"report_to"`
+	// header of the csv the recipients came from
+	MetadataColumns pq.StringArray `json:"metadataColumns" db:"metadata_columns"`
+	SendAt          *time.Time     `json:"sendAt" db:"send_at"`
+	TimeZone        string         `json:"timeZone" db:"time_zone"`
+	CreatedAt       time.Time      `json:"createdAt" db:"created_at"`
+	StartAt         *time.Time     `json:"startAt" db:"start_at"`
+	FinishAt        *time.Time     `json:"finishAt" db:"finish_at"`
+}
+
+// UtmParams are appended to every web link of a batch
+type UtmParams struct {
+	Source   string `json:"source" form:"utmSource" db:"utm_source"`
+	Medium   string `json:"medium" form:"utmMedium" db:"utm_medium"`
+	Campaign string `json:"campaign" form:"utmCampaign" db:"utm_campaign"`
+	Term     string `json:"term" form:"utmTerm" db:"utm_term"`
+	Content  string `json:"content" form:"utmContent" db:"utm_content"`
+}
+
+// Values returns the parameters that are set, keyed by their query name
+func (p UtmParams) Values() map[string]string {
+	values := map[string]string{}
+
+	for key, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+		"utm_term":     p.Term,
+		"utm_content":  p.Content,
+	} {
+		if value != "" {
+			values[key] = value
+		}
+	}
+
+	return values
 }
 
 type Email struct {
-	Id      int       `json:"id" db:"id"`
-	BatchId string    `json:"batchId" db:"batch_id"`
-	Email   string    `json:"email" db:"email"`
-	IsSent  bool      `json:"isSent" db:"is_sent"`
-	SentAt  time.Time `json:"sentAt" db:"sent_at"`
-	Log     *string   `json:"log" db:"log"`
+	Id         int        `json:"id" db:"id"`
+	BatchId    string     `json:"batchId" db:"batch_id"`
+	Email      string     `json:"email" db:"email"`
+	Status     string     `json:"status" db:"status"`
+	IsSent     bool       `json:"isSent" db:"is_sent"`
+	SentAt     *time.Time `json:"sentAt" db:"sent_at"`
+	Log        *string    `json:"log" db:"log"`
+	MessageId  *string    `json:"messageId" db:"message_id"`
+	BounceType *string    `json:"bounceType" db:"bounce_type"`
+	BouncedAt  *time.Time `json:"bouncedAt" db:"bounced_at"`
+
+	OpenCount     int        `json:"openCount" db:"open_count"`
+	FirstOpenedAt *time.Time `json:"firstOpenedAt" db:"first_opened_at"`
+	LastOpenedAt  *time.Time `json:"lastOpenedAt" db:"last_opened_at"`
+
+	ClickCount     int        `json:"clickCount" db:"click_count"`
+	FirstClickedAt *time.Time `json:"firstClickedAt" db:"first_clicked_at"`
+
+	// the csv row the recipient came from
+	Metadata Metadata `json:"metadata,omitempty" db:"metadata"`
+
+	// copied from the batch so a bounce can be tied to its workspace
+	WorkspaceId string `json:"-" db:"workspace_id"`
+
+	// the sending quota window the email is charged to, it is not sent before then
+	QuotaAt *time.Time `json:"-" db:"quota_at"`
+}
+
+// BatchStats is the engagement roll up of a batch
+type BatchStats struct {
+	UniqueOpens  int         `json:"uniqueOpens" db:"unique_opens"`
+	TotalOpens   int         `json:"totalOpens" db:"total_opens"`
+	OpenRate     float64     `json:"openRate" db:"-"`
+	UniqueClicks int         `json:"uniqueClicks" db:"unique_clicks"`
+	TotalClicks  int         `json:"totalClicks" db:"total_clicks"`
+	ClickRate    float64     `json:"clickRate" db:"-"`
+	Links        []LinkStats `json:"links" db:"-"`
+}
+
+// LinkStats counts the clicks on a single link of a batch
+type LinkStats struct {
+	Url          string `json:"url" db:"url"`
+	UniqueClicks int    `json:"uniqueClicks" db:"unique_clicks"`
+	TotalClicks  int    `json:"totalClicks" db:"total_clicks"`
+}
+
+const (
+	// first event of a stream, the state of the batch when the client connected
+	BatchEventState     = "state"
+	BatchEventStarted   = "started"
+	BatchEventSent      = "sent"
+	BatchEventFailed    = "failed"
+	BatchEventProgress  = "progress"
+	BatchEventCompleted = "completed"
+)
+
+// BatchTotals are the running totals of a batch, computed from its emails
+type BatchTotals struct {
+	Total      int `json:"total" db:"total"`
+	Sent       int `json:"sent" db:"sent"`
+	Failed     int `json:"failed" db:"failed"`
+	Pending    int `json:"pending" db:"pending"`
+	Suppressed int `json:"suppressed" db:"suppressed"`
+	Canceled   int `json:"canceled" db:"canceled"`
+}
+
+// BatchStreamEvent is pushed to the clients following a batch
+type BatchStreamEvent struct {
+	Type    string      `json:"type"`
+	BatchId string      `json:"batchId"`
+	Status  string      `json:"status"`
+	Email   *Email      `json:"email,omitempty"`
+	Totals  BatchTotals `json:"totals"`
+}
+
+// BatchStreamToken opens the event stream of a batch from a client that cannot set the Authorization header,
+// such as the browser EventSource, Url is the stream with the token in its query
+type BatchStreamToken struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// FailureReason counts the failed emails of a batch that share the same error
+type FailureReason struct {
+	Reason string `json:"reason" db:"reason"`
+	Count  int    `json:"count" db:"count"`
+}
+
+type GetBatchResponse struct {
+	EmailBatch
+	Stats BatchStats `json:"stats"`
 }
 
 type GetEmailRequest struct {
 	BatchId string `json:"batchId" db:"batch_id"`
 	Email   string `json:"email" db:"email"`
+	Status  string `json:"status" db:"status"`
+	// leaves out the emails a sending quota holds back until after then
+	DueBy *time.Time `json:"-" db:"-"`
+}
+
+type GetBatchRequest struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+type GetBatchExportRequest struct {
+	// csv or jsonl
+	Format string `query:"format" validate:"omitempty,oneof=csv jsonl"`
+	// add the columns of the csv the recipients came from
+	IncludeColumns bool `query:"includeColumns"`
+}
+
+type PutBatchScheduleRequest struct {
+	SendAt   string `json:"sendAt" validate:"required"`
+	TimeZone string `json:"timeZone"`
+}
+
+type PostBatchRetryRequest struct {
+	SendAt   string `json:"sendAt"`
+	TimeZone string `json:"timeZone"`
+}
+
+type PostBatchCloneRequest struct {
+	Destinations []string `json:"destinations" validate:"required"`
+	SendAt       string   `json:"sendAt"`
+	TimeZone     string   `json:"timeZone"`
 }
 
 type PostEmailRequest struct {
 	Destinations []string `json:"destinations" validate:"required"`
-	Subject      string   `json:"subject" validate:"required"`
-	Body         string   `json:"body"`
-	Template     string   `json:"template"`
+	// one of the senders of the workspace, the workspace sender when empty
+	SenderId string `json:"senderId"`
+	// the content of a stored template is used in place of subject, body and template
+	TemplateId  string    `json:"templateId"`
+	Subject     string    `json:"subject" validate:"required_without=TemplateId"`
+	Body        string    `json:"body"`
+	Template    string    `json:"template"`
+	TrackOpens  bool      `json:"trackOpens"`
+	TrackClicks bool      `json:"trackClicks"`
+	Utm         UtmParams `json:"utm"`
+	// events of this batch are also delivered here, signed with the secret
+	WebhookUrl    string `json:"webhookUrl" validate:"omitempty,url"`
+	WebhookSecret string `json:"webhookSecret"`
+	// progress reports and the final summary are emailed here
+	ReportTo string `json:"reportTo" validate:"omitempty,email"`
+	// RFC 3339, or a local time such as 2025-07-01T09:00 read in TimeZone
+	SendAt   string `json:"sendAt"`
+	TimeZone string `json:"timeZone"`
+
+	// read from the Idempotency-Key header
+	IdempotencyKey string `json:"-" validate:"max=255"`
 }
 
 type PostEmailRequestCsv struct {
-	Subject      string `form:"subject" validate:"required"`
+	SenderId     string `form:"senderId"`
+	TemplateId   string `form:"templateId"`
+	Subject      string `form:"subject" validate:"required_without=TemplateId"`
 	Body         string `form:"body"`
 	Template     string `form:"template"`
 	TargetColumn string `form:"targetColumn" validate:"required"`
+	TrackOpens   bool   `form:"trackOpens"`
+	TrackClicks  bool   `form:"trackClicks"`
+	// bound from the utmSource, utmMedium, ... form fields
+	Utm           UtmParams
+	WebhookUrl    string `form:"webhookUrl" validate:"omitempty,url"`
+	WebhookSecret string `form:"webhookSecret"`
+	ReportTo      string `form:"reportTo" validate:"omitempty,email"`
+	SendAt        string `form:"sendAt"`
+	TimeZone      string `form:"timeZone"`
+
+	// read from the Idempotency-Key header
+	IdempotencyKey string `json:"-" validate:"max=255"`
 }
 
 type PostEmailResponse struct {
 	BatchId string `json:"emailBatchId"`
+	Status  string `json:"status"`
+	// the batch was created by an earlier request with the same idempotency key
+	Replayed bool `json:"replayed"`
 }
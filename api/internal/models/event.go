@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	EmailEventOpen  = "open"
+	EmailEventClick = "click"
+)
+
+// EmailEvent is a single engagement recorded for a recipient
+type EmailEvent struct {
+	Id        int64  `json:"id" db:"id"`
+	EmailId   int    `json:"emailId" db:"email_id"`
+	BatchId   string `json:"batchId" db:"batch_id"`
+	Type      string `json:"type" db:"type"`
+	UserAgent string `json:"userAgent" db:"user_agent"`
+	Ip        string `json:"ip" db:"ip"`
+	// link that was clicked, empty for other events
+	Url       string    `json:"url" db:"url"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKeyHeader names the request header a send is retried under without sending the batch twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey remembers the batch a send created, until it expires
+type IdempotencyKey struct {
+	WorkspaceId string `db:"workspace_id"`
+	Key         string `db:"idempotency_key"`
+	// sha256 of the request, the key cannot be reused for another one
+	RequestHash string    `db:"request_hash"`
+	BatchId     string    `db:"batch_id"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
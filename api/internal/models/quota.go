@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	QuotaScopeApiKey    = "api_key"
+	QuotaScopeWorkspace = "workspace"
+	QuotaScopeSender    = "sender"
+)
+
+const (
+	QuotaPeriodHour  = "hour"
+	QuotaPeriodDay   = "day"
+	QuotaPeriodMonth = "month"
+)
+
+// Quota caps the emails an api key, a workspace or a sender is charged for in each hour, day or month
+// windows are calendar periods in UTC, an email is charged to the window it is planned to be sent in
+type Quota struct {
+	Id          string `json:"id" db:"id"`
+	WorkspaceId string `json:"workspaceId" db:"workspace_id"`
+	Scope       string `json:"scope" db:"scope"`
+	// the api key, workspace or sender the quota applies to
+	ScopeId   string    `json:"scopeId" db:"scope_id"`
+	Period    string    `json:"period" db:"period"`
+	Limit     int       `json:"limit" db:"max_emails"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	// allowance of the current window, filled when the quota is read through the api
+	Remaining *int       `json:"remaining,omitempty" db:"-"`
+	ResetAt   *time.Time `json:"resetAt,omitempty" db:"-"`
+}
+
+// Window returns the window of the quota that t falls in
+func (q Quota) Window(t time.Time) (start time.Time, end time.Time) {
+	t = t.UTC()
+
+	switch q.Period {
+	case QuotaPeriodHour:
+		start = t.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case QuotaPeriodMonth:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+type PostQuotaRequest struct {
+	Scope string `json:"scope" validate:"required,oneof=api_key workspace sender"`
+	// required unless the scope is workspace, which is always the workspace of the caller
+	ScopeId string `json:"scopeId"`
+	Period  string `json:"period" validate:"required,oneof=hour day month"`
+	Limit   int    `json:"limit" validate:"required,min=1"`
+}
+
+type PutQuotaRequest struct {
+	Limit int `json:"limit" validate:"required,min=1"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Relay is a smtp server a workspace sends through in place of its own connection
+// relays are tried by priority, lowest first, and share the traffic of a priority by weight
+type Relay struct {
+	Id           string `json:"id" db:"id"`
+	WorkspaceId  string `json:"workspaceId" db:"workspace_id"`
+	Name         string `json:"name" db:"name"`
+	SmtpHost     string `json:"smtpHost" db:"smtp_host"`
+	SmtpPort     string `json:"smtpPort" db:"smtp_port"`
+	SmtpUsername string `json:"smtpUsername" db:"smtp_username"`
+	SmtpPassword string `json:"-" db:"smtp_password"`
+	Priority     int    `json:"priority" db:"priority"`
+	Weight       int    `json:"weight" db:"weight"`
+	// routing rules, a relay with rules only carries the mail they match
+	RecipientDomains pq.StringArray `json:"recipientDomains" db:"recipient_domains"`
+	SenderIds        pq.StringArray `json:"senderIds" db:"sender_ids"`
+	CreatedAt        time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time      `json:"updatedAt" db:"updated_at"`
+	// set while the circuit breaker keeps the relay out of rotation
+	UnhealthyUntil *time.Time `json:"unhealthyUntil" db:"-"`
+}
+
+// HasRules reports whether the relay is reserved for the mail its rules match
+func (r Relay) HasRules() bool {
+	return len(r.RecipientDomains) > 0 || len(r.SenderIds) > 0
+}
+
+// Matches reports whether a message to domain sent as senderId is routed to the relay
+func (r Relay) Matches(domain string, senderId string) bool {
+	for _, item := range r.RecipientDomains {
+		if strings.EqualFold(item, domain) {
+			return true
+		}
+	}
+
+	for _, item := range r.SenderIds {
+		if senderId != "" && item == senderId {
+			return true
+		}
+	}
+
+	return false
+}
+
+type PostRelayRequest struct {
+	Name         string `json:"name" validate:"required"`
+	SmtpHost     string `json:"smtpHost" validate:"required"`
+	SmtpPort     string `json:"smtpPort" validate:"required,numeric"`
+	SmtpUsername string `json:"smtpUsername" validate:"required"`
+	SmtpPassword string `json:"smtpPassword"`
+	Priority     int    `json:"priority" validate:"min=0"`
+	// defaults to 1
+	Weight           int      `json:"weight" validate:"min=0"`
+	RecipientDomains []string `json:"recipientDomains" validate:"dive,fqdn"`
+	SenderIds        []string `json:"senderIds"`
+}
+
+type PutRelayRequest struct {
+	Name         string `json:"name" validate:"required"`
+	SmtpHost     string `json:"smtpHost" validate:"required"`
+	SmtpPort     string `json:"smtpPort" validate:"required,numeric"`
+	SmtpUsername string `json:"smtpUsername" validate:"required"`
+	// the stored password is kept when empty
+	SmtpPassword string `json:"smtpPassword"`
+	Priority     int    `json:"priority" validate:"min=0"`
+	// defaults to 1
+	Weight           int      `json:"weight" validate:"min=0"`
+	RecipientDomains []string `json:"recipientDomains" validate:"dive,fqdn"`
+	SenderIds        []string `json:"senderIds"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Sender is an identity a workspace sends as
+type Sender struct {
+	Id             string    `json:"id" db:"id"`
+	WorkspaceId    string    `json:"workspaceId" db:"workspace_id"`
+	Name           string    `json:"name" db:"name"`
+	EmailFrom      string    `json:"emailFrom" db:"email_from"`
+	EmailAlias     string    `json:"emailAlias" db:"email_alias"`
+	ReplyTo        string    `json:"replyTo" db:"reply_to"`
+	SmtpHost       string    `json:"smtpHost" db:"smtp_host"`
+	SmtpPort       string    `json:"smtpPort" db:"smtp_port"`
+	SmtpUsername   string    `json:"smtpUsername" db:"smtp_username"`
+	SmtpPassword   string    `json:"-" db:"smtp_password"`
+	DkimSelector   string    `json:"dkimSelector" db:"dkim_selector"`
+	DkimDomain     string    `json:"dkimDomain" db:"dkim_domain"`
+	DkimPrivateKey string    `json:"-" db:"dkim_private_key"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time `json:"updatedAt" db:"updated_at"`
+	// smtp or one of the http apis, see SenderTransport*
+	Transport string `json:"transport" db:"transport"`
+	// base url of the api, the provider's public endpoint when empty, the webhook url for webhook
+	ApiUrl string `json:"apiUrl" db:"api_url"`
+	// api key, access key id for ses
+	ApiKey string `json:"-" db:"api_key"`
+	// signing secret for webhook, secret access key for ses
+	ApiSecret string `json:"-" db:"api_secret"`
+	// aws region for ses
+	ApiRegion string `json:"apiRegion" db:"api_region"`
+	// every batch sent as the sender waits for an approver
+	RequiresApproval bool `json:"requiresApproval" db:"requires_approval"`
+}
+
+const (
+	SenderTransportSmtp     = "smtp"
+	SenderTransportWebhook  = "webhook"
+	SenderTransportSendgrid = "sendgrid"
+	SenderTransportMailgun  = "mailgun"
+	SenderTransportSes      = "ses"
+)
+
+// HasSmtp reports whether the sender has a smtp account of its own
+func (s Sender) HasSmtp() bool {
+	return s.UsesSmtp() && s.SmtpHost != ""
+}
+
+// UsesSmtp reports whether the sender sends over smtp rather than through an http api
+func (s Sender) UsesSmtp() bool {
+	return s.Transport == "" || s.Transport == SenderTransportSmtp
+}
+
+type PostSenderRequest struct {
+	Name         string `json:"name" validate:"required"`
+	EmailFrom    string `json:"emailFrom" validate:"required,email"`
+	EmailAlias   string `json:"emailAlias"`
+	ReplyTo      string `json:"replyTo" validate:"omitempty,email"`
+	SmtpHost     string `json:"smtpHost"`
+	SmtpPort     string `json:"smtpPort" validate:"required_with=SmtpHost,omitempty,numeric"`
+	SmtpUsername string `json:"smtpUsername"`
+	SmtpPassword string `json:"smtpPassword"`
+	DkimSelector string `json:"dkimSelector" validate:"required_with=DkimDomain DkimPrivateKey"`
+	DkimDomain   string `json:"dkimDomain" validate:"required_with=DkimSelector DkimPrivateKey"`
+	// PEM encoded rsa or ed25519 key
+	DkimPrivateKey string `json:"dkimPrivateKey" validate:"required_with=DkimSelector DkimDomain"`
+	// defaults to smtp
+	Transport string `json:"transport" validate:"omitempty,oneof=smtp webhook sendgrid mailgun ses"`
+	ApiUrl    string `json:"apiUrl" validate:"omitempty,url"`
+	ApiKey    string `json:"apiKey"`
+	ApiSecret string `json:"apiSecret"`
+	ApiRegion string `json:"apiRegion"`
+	// every batch sent as the sender waits for an approver
+	RequiresApproval bool `json:"requiresApproval"`
+}
+
+type PutSenderRequest struct {
+	Name         string `json:"name" validate:"required"`
+	EmailFrom    string `json:"emailFrom" validate:"required,email"`
+	EmailAlias   string `json:"emailAlias"`
+	ReplyTo      string `json:"replyTo" validate:"omitempty,email"`
+	SmtpHost     string `json:"smtpHost"`
+	SmtpPort     string `json:"smtpPort" validate:"required_with=SmtpHost,omitempty,numeric"`
+	SmtpUsername string `json:"smtpUsername"`
+	// the stored password is kept when empty
+	SmtpPassword string `json:"smtpPassword"`
+	DkimSelector string `json:"dkimSelector"`
+	DkimDomain   string `json:"dkimDomain" validate:"required_with=DkimSelector"`
+	// the stored key is kept when empty
+	DkimPrivateKey string `json:"dkimPrivateKey"`
+	// defaults to smtp
+	Transport string `json:"transport" validate:"omitempty,oneof=smtp webhook sendgrid mailgun ses"`
+	ApiUrl    string `json:"apiUrl" validate:"omitempty,url"`
+	// the stored api key and secret are kept when empty
+	ApiKey    string `json:"apiKey"`
+	ApiSecret string `json:"apiSecret"`
+	ApiRegion string `json:"apiRegion"`
+	// every batch sent as the sender waits for an approver
+	RequiresApproval bool `json:"requiresApproval"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	SuppressionReasonUnsubscribed = "unsubscribed"
+	SuppressionReasonHardBounce   = "hard_bounce"
+	SuppressionReasonComplaint    = "complaint"
+	SuppressionReasonManual       = "manual"
+)
+
+type Suppression struct {
+	Email     string    `json:"email" db:"email"`
+	Reason    string    `json:"reason" db:"reason"`
+	Source    string    `json:"source" db:"source"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	WorkspaceId string `json:"-" db:"workspace_id"`
+}
+
+type GetSuppressionRequest struct {
+	Reason string `query:"reason"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+type PostSuppressionRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Reason string `json:"reason" validate:"required,oneof=unsubscribed hard_bounce complaint manual"`
+	Source string `json:"source"`
+}
+
+type PutSuppressionRequest struct {
+	Reason string `json:"reason" validate:"required,oneof=unsubscribed hard_bounce complaint manual"`
+	Source string `json:"source"`
+}
+
+type PostSuppressionImportRequest struct {
+	Reason       string `form:"reason" validate:"required,oneof=unsubscribed hard_bounce complaint manual"`
+	Source       string `form:"source"`
+	TargetColumn string `form:"targetColumn" validate:"required"`
+}
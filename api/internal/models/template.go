@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Template is stored content a workspace can send by id
+type Template struct {
+	Id          string    `json:"id" db:"id"`
+	WorkspaceId string    `json:"workspaceId" db:"workspace_id"`
+	Name        string    `json:"name" db:"name"`
+	Subject     string    `json:"subject" db:"subject"`
+	Body        string    `json:"body" db:"body"`
+	Template    string    `json:"template" db:"template"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type PostTemplateRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Subject  string `json:"subject" validate:"required"`
+	Body     string `json:"body"`
+	Template string `json:"template"`
+}
+
+type PutTemplateRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Subject  string `json:"subject" validate:"required"`
+	Body     string `json:"body"`
+	Template string `json:"template"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookEventBatchStarted   = "batch.started"
+	WebhookEventBatchProgress  = "batch.progress"
+	WebhookEventBatchCompleted = "batch.completed"
+	WebhookEventEmailFailed    = "email.failed"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// gave up after the last attempt
+	WebhookDeliveryFailed = "failed"
+)
+
+type WebhookSubscription struct {
+	Id          string `json:"id" db:"id"`
+	WorkspaceId string `json:"workspaceId" db:"workspace_id"`
+	Url         string `json:"url" db:"url"`
+	Secret      string `json:"-" db:"secret"`
+	// empty means every event
+	Events    pq.StringArray `json:"events" db:"events"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id             int64      `json:"id" db:"id"`
+	WorkspaceId    string     `json:"workspaceId" db:"workspace_id"`
+	SubscriptionId *string    `json:"subscriptionId" db:"subscription_id"`
+	BatchId        *string    `json:"batchId" db:"batch_id"`
+	EventId        string     `json:"eventId" db:"event_id"`
+	Event          string     `json:"event" db:"event"`
+	Url            string     `json:"url" db:"url"`
+	Secret         string     `json:"-" db:"secret"`
+	Payload        string     `json:"payload" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	ResponseCode   *int       `json:"responseCode" db:"response_code"`
+	Error          *string    `json:"error" db:"error"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt" db:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	DeliveredAt    *time.Time `json:"deliveredAt" db:"delivered_at"`
+}
+
+// WebhookEvent is the body of every delivery
+type WebhookEvent struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// EmailFailedData is the data of an email.failed event
+type EmailFailedData struct {
+	BatchId string `json:"batchId"`
+	Email   Email  `json:"email"`
+}
+
+type PostWebhookRequest struct {
+	Url string `json:"url" validate:"required,url"`
+	// generated when empty
+	Secret string   `json:"secret"`
+	Events []string `json:"events" validate:"dive,oneof=batch.started batch.progress batch.completed email.failed"`
+}
+
+// PostWebhookResponse is the only time the secret is shown
+type PostWebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type GetWebhookDeliveryRequest struct {
+	BatchId string `query:"batchId"`
+	Status  string `query:"status"`
+	Limit   int    `query:"limit"`
+	Offset  int    `query:"offset"`
+}
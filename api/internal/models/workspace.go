@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// DefaultWorkspaceId owns the data from before workspaces, its admin keys manage the other workspaces
+const DefaultWorkspaceId = "default"
+
+type Workspace struct {
+	Id           string    `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	EmailFrom    string    `json:"emailFrom" db:"email_from"`
+	EmailAlias   string    `json:"emailAlias" db:"email_alias"`
+	SmtpHost     string    `json:"smtpHost" db:"smtp_host"`
+	SmtpPort     string    `json:"smtpPort" db:"smtp_port"`
+	SmtpUsername string    `json:"smtpUsername" db:"smtp_username"`
+	SmtpPassword string    `json:"-" db:"smtp_password"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// HasSmtp reports whether the workspace sends through its own smtp server
+func (w Workspace) HasSmtp() bool {
+	return w.SmtpHost != ""
+}
+
+type PostWorkspaceRequest struct {
+	// generated when empty
+	Id           string `json:"id" validate:"omitempty,max=36"`
+	Name         string `json:"name" validate:"required"`
+	EmailFrom    string `json:"emailFrom" validate:"required_with=SmtpHost,omitempty,email"`
+	EmailAlias   string `json:"emailAlias"`
+	SmtpHost     string `json:"smtpHost"`
+	SmtpPort     string `json:"smtpPort" validate:"required_with=SmtpHost,omitempty,numeric"`
+	SmtpUsername string `json:"smtpUsername"`
+	SmtpPassword string `json:"smtpPassword"`
+}
+
+type PutWorkspaceRequest struct {
+	Name         string `json:"name" validate:"required"`
+	EmailFrom    string `json:"emailFrom" validate:"required_with=SmtpHost,omitempty,email"`
+	EmailAlias   string `json:"emailAlias"`
+	SmtpHost     string `json:"smtpHost"`
+	SmtpPort     string `json:"smtpPort" validate:"required_with=SmtpHost,omitempty,numeric"`
+	SmtpUsername string `json:"smtpUsername"`
+	// the stored password is kept when empty
+	SmtpPassword string `json:"smtpPassword"`
+}
@@ -0,0 +1,89 @@
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase ApiKeyUsecase
+}
+
+// GetApiKeys implements ApiKeyHandler.
+func (h Handler) GetApiKeys(e echo.Context) error {
+	var request models.GetApiKeyRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	results, statusCode, err := h.usecase.GetApiKeys(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// PostApiKey implements ApiKeyHandler.
+func (h Handler) PostApiKey(e echo.Context) error {
+	var request models.PostApiKeyRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateApiKey(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// DeleteApiKey implements ApiKeyHandler.
+func (h Handler) DeleteApiKey(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.RevokeApiKey(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+func NewHandler(usecase ApiKeyUsecase) ApiKeyHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// api keys clients authenticate with as Authorization: Bearer <key>
+// a client that cannot set headers, such as the browser EventSource, opens a stream with a short lived token instead
+// only a hash of a key is stored, the key is shown once when it is issued
+// a key belongs to one workspace and every request made with it is scoped to that workspace
+
+type ApiKeyHandler interface {
+	GetApiKeys(e echo.Context) error
+	PostApiKey(e echo.Context) error
+	DeleteApiKey(e echo.Context) error
+}
+
+type ApiKeyUsecase interface {
+	GetApiKeys(ctx context.Context, param models.GetApiKeyRequest) ([]models.ApiKey, int, error)
+	CreateApiKey(ctx context.Context, param models.PostApiKeyRequest) (models.PostApiKeyResponse, int, error)
+	RevokeApiKey(ctx context.Context, id string) (int, error)
+	// Authenticate returns the active key matching token
+	Authenticate(ctx context.Context, token string) (models.ApiKey, int, error)
+	// AuthenticateStream returns the active key a stream token issued for path was signed for, see SignStreamToken
+	AuthenticateStream(ctx context.Context, token string, path string) (models.ApiKey, int, error)
+}
+
+// Auditor records the keys issued and revoked, it is the Recorder of the audit service which builds on this one
+type Auditor interface {
+	Record(ctx context.Context, tx *sqlx.Tx, action string, resource string, resourceId string, before any, after any) error
+}
+
+type ApiKeyRepository interface {
+	CreateApiKey(ctx context.Context, tx *sqlx.Tx, param models.ApiKey) error
+	ReadApiKeys(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.ApiKey, error)
+	ReadApiKey(ctx context.Context, db *sqlx.DB, id string) (models.ApiKey, error)
+	ReadApiKeyByHash(ctx context.Context, db *sqlx.DB, keyHash string) (models.ApiKey, error)
+	UpdateApiKeyLastUsed(ctx context.Context, tx *sqlx.Tx, id string, lastUsedAt time.Time) error
+	RevokeApiKey(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string, revokedAt time.Time) error
+}
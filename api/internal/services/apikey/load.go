@@ -0,0 +1,28 @@
+package apikey
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// Load returns the authorizer the other services guard their routes with
+func Load(e *echo.Echo, db *sqlx.DB, cfg config.Config, auditor Auditor) Authorizer {
+
+	// init repository
+	repository := NewRepository()
+
+	// init usecase
+	usecase := NewUsecase(db, repository, auditor, cfg)
+
+	// init authorizer
+	authorize := NewAuthorizer(usecase)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler, authorize)
+
+	return authorize
+}
@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Authorizer builds the middleware guarding a route, the key must grant every scope given
+type Authorizer func(scopes ...string) echo.MiddlewareFunc
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the key the request was authenticated with
+func NewContext(ctx context.Context, apiKey models.ApiKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, apiKey)
+}
+
+// FromContext returns the key the request was authenticated with
+func FromContext(ctx context.Context) (models.ApiKey, bool) {
+	apiKey, ok := ctx.Value(contextKey{}).(models.ApiKey)
+	return apiKey, ok
+}
+
+// WorkspaceFromContext returns the workspace every query of the request is scoped to
+// calls made without a key, such as from the command line, act on the default workspace
+func WorkspaceFromContext(ctx context.Context) string {
+	if apiKey, ok := FromContext(ctx); ok {
+		return apiKey.WorkspaceId
+	}
+
+	return models.DefaultWorkspaceId
+}
+
+// IsOperator reports whether the caller may act across workspaces,
+// which admin keys of the default workspace and calls made without a key may
+func IsOperator(ctx context.Context) bool {
+	apiKey, ok := FromContext(ctx)
+	if !ok {
+		return true
+	}
+
+	return apiKey.WorkspaceId == models.DefaultWorkspaceId && apiKey.HasScope(models.ScopeAdmin)
+}
+
+func NewAuthorizer(usecase ApiKeyUsecase) Authorizer {
+	return func(scopes ...string) echo.MiddlewareFunc {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(e echo.Context) error {
+				ctx := e.Request().Context()
+				header := e.Request().Header.Get(echo.HeaderAuthorization)
+
+				var apiKey models.ApiKey
+				var statusCode int
+				var err error
+
+				// a stream token only opens the stream it was issued for
+				if streamToken := e.QueryParam(StreamTokenParam); header == "" && streamToken != "" && e.Request().Method == http.MethodGet {
+					apiKey, statusCode, err = usecase.AuthenticateStream(ctx, streamToken, e.Request().URL.Path)
+				} else {
+					token, ok := strings.CutPrefix(header, "Bearer ")
+					if !ok || strings.TrimSpace(token) == "" {
+						e.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+						return e.JSON(http.StatusUnauthorized, map[string]string{
+							"error": "missing api key",
+						})
+					}
+
+					apiKey, statusCode, err = usecase.Authenticate(ctx, strings.TrimSpace(token))
+				}
+				if err != nil {
+					if statusCode == http.StatusUnauthorized {
+						e.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+					}
+					return e.JSON(statusCode, map[string]string{
+						"error": err.Error(),
+					})
+				}
+
+				for _, scope := range scopes {
+					if !apiKey.HasScope(scope) {
+						return e.JSON(http.StatusForbidden, map[string]string{
+							"error": "api key is missing scope: " + scope,
+						})
+					}
+				}
+
+				e.SetRequest(e.Request().WithContext(NewContext(ctx, apiKey)))
+
+				return next(e)
+			}
+		}
+	}
+}
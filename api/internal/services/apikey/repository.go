@@ -0,0 +1,88 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const apiKeyColumns = `id, workspace_id, name, prefix, key_hash, scopes, sender_ids, expires_at, last_used_at, revoked_at, created_at, role, owner`
+
+type Repository struct {
+}
+
+// CreateApiKey implements ApiKeyRepository.
+func (r Repository) CreateApiKey(ctx context.Context, tx *sqlx.Tx, param models.ApiKey) error {
+	query := `
+		INSERT INTO api_keys (` + apiKeyColumns + `)
+		VALUES (:id, :workspace_id, :name, :prefix, :key_hash, :scopes, :sender_ids, :expires_at, :last_used_at, :revoked_at, :created_at, :role, :owner)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadApiKeys implements ApiKeyRepository.
+// an empty workspace reads the keys of every workspace
+func (r Repository) ReadApiKeys(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.ApiKey, error) {
+	results := []models.ApiKey{}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE ($1 = '' OR workspace_id = $1) ORDER BY created_at`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// ReadApiKey implements ApiKeyRepository.
+func (r Repository) ReadApiKey(ctx context.Context, db *sqlx.DB, id string) (models.ApiKey, error) {
+	var result models.ApiKey
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	err := db.GetContext(ctx, &result, query, id)
+
+	return result, err
+}
+
+// ReadApiKeyByHash implements ApiKeyRepository.
+func (r Repository) ReadApiKeyByHash(ctx context.Context, db *sqlx.DB, keyHash string) (models.ApiKey, error) {
+	var result models.ApiKey
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	err := db.GetContext(ctx, &result, query, keyHash)
+
+	return result, err
+}
+
+// UpdateApiKeyLastUsed implements ApiKeyRepository.
+func (r Repository) UpdateApiKeyLastUsed(ctx context.Context, tx *sqlx.Tx, id string, lastUsedAt time.Time) error {
+	_, err := tx.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, lastUsedAt)
+	return err
+}
+
+// RevokeApiKey implements ApiKeyRepository.
+// an empty workspace revokes the key whichever workspace it belongs to
+func (r Repository) RevokeApiKey(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string, revokedAt time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = $3 WHERE id = $2 AND ($1 = '' OR workspace_id = $1) AND revoked_at IS NULL`
+
+	res, err := tx.ExecContext(ctx, query, workspaceId, id, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func NewRepository() ApiKeyRepository {
+	return Repository{}
+}
@@ -0,0 +1,12 @@
+package apikey
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler ApiKeyHandler, authorize Authorizer) {
+	e.GET("/apikey", handler.GetApiKeys, authorize(models.ScopeAdmin))
+	e.POST("/apikey", handler.PostApiKey, authorize(models.ScopeAdmin))
+	e.DELETE("/apikey/:id", handler.DeleteApiKey, authorize(models.ScopeAdmin))
+}
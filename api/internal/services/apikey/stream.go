@@ -0,0 +1,52 @@
+package apikey
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/utils"
+)
+
+const streamPurpose = "stream"
+
+// StreamTokenParam is the query parameter a stream token is sent in
+const StreamTokenParam = "access_token"
+
+// StreamTokenTTL is how long a stream token opens its stream, an open stream is not cut when it expires
+const StreamTokenTTL = 5 * time.Minute
+
+// SignStreamToken issues a token that authenticates GET requests to path as the api key until expiresAt
+// it stands in for the Authorization header where a client cannot set it, the key is checked again on every use
+func SignStreamToken(secret string, apiKeyId string, path string, expiresAt time.Time) string {
+	return utils.SignToken(secret, streamPurpose, apiKeyId+"\n"+path+"\n"+strconv.FormatInt(expiresAt.Unix(), 10))
+}
+
+// parseStreamToken returns the api key a token for path was issued to
+func parseStreamToken(secret string, token string, path string, now time.Time) (string, error) {
+	payload, err := utils.VerifyToken(secret, streamPurpose, token)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream token")
+	}
+
+	parts := strings.Split(payload, "\n")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", fmt.Errorf("invalid stream token")
+	}
+
+	if parts[1] != path {
+		return "", fmt.Errorf("stream token was issued for another stream")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream token")
+	}
+
+	if !now.Before(time.Unix(expiresAt, 0)) {
+		return "", fmt.Errorf("stream token has expired")
+	}
+
+	return parts[0], nil
+}
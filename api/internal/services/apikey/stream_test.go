@@ -0,0 +1,41 @@
+package apikey
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStreamToken(t *testing.T) {
+	now := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	path := "/email/batch/b1/events"
+	token := SignStreamToken("secret", "key-1", path, now.Add(StreamTokenTTL))
+
+	tests := []struct {
+		name    string
+		secret  string
+		token   string
+		path    string
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "valid", secret: "secret", token: token, path: path, now: now},
+		{name: "another stream", secret: "secret", token: token, path: "/email/batch/b2/events", now: now, wantErr: true},
+		{name: "expired", secret: "secret", token: token, path: path, now: now.Add(StreamTokenTTL), wantErr: true},
+		{name: "another secret", secret: "other", token: token, path: path, now: now, wantErr: true},
+		{name: "empty secret", secret: "", token: SignStreamToken("", "key-1", path, now.Add(time.Minute)), path: path, now: now, wantErr: true},
+		{name: "garbage", secret: "secret", token: "not-a-token", path: path, now: now, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiKeyId, err := parseStreamToken(test.secret, test.token, test.path, test.now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseStreamToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if !test.wantErr && apiKeyId != "key-1" {
+				t.Errorf("parseStreamToken() = %q, want key-1", apiKeyId)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// last_used_at is written at most this often per key, not on every request
+const lastUsedResolution = time.Minute
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository ApiKeyRepository
+	auditor    Auditor
+	cfg        config.Config
+}
+
+// GetApiKeys implements ApiKeyUsecase.
+// operators see every workspace unless they ask for one
+func (u Usecase) GetApiKeys(ctx context.Context, param models.GetApiKeyRequest) ([]models.ApiKey, int, error) {
+	workspaceId := param.WorkspaceId
+	if !IsOperator(ctx) {
+		if workspaceId != "" && workspaceId != WorkspaceFromContext(ctx) {
+			return nil, http.StatusForbidden, fmt.Errorf("api key cannot read another workspace")
+		}
+		workspaceId = WorkspaceFromContext(ctx)
+	}
+
+	results, err := u.repository.ReadApiKeys(ctx, u.db, workspaceId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// CreateApiKey implements ApiKeyUsecase.
+func (u Usecase) CreateApiKey(ctx context.Context, param models.PostApiKeyRequest) (models.PostApiKeyResponse, int, error) {
+	if param.ExpiresAt != nil && !param.ExpiresAt.After(time.Now()) {
+		return models.PostApiKeyResponse{}, http.StatusBadRequest, fmt.Errorf("expiry must be in the future")
+	}
+
+	workspaceId := WorkspaceFromContext(ctx)
+	if param.WorkspaceId != "" && param.WorkspaceId != workspaceId {
+		if !IsOperator(ctx) {
+			return models.PostApiKeyResponse{}, http.StatusForbidden, fmt.Errorf("api key cannot issue keys for another workspace")
+		}
+		workspaceId = param.WorkspaceId
+	}
+
+	if param.Role == "" && len(param.Scopes) == 0 {
+		return models.PostApiKeyResponse{}, http.StatusBadRequest, fmt.Errorf("a role or at least one scope is required")
+	}
+
+	scopes := param.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	senderIds := param.SenderIds
+	if senderIds == nil {
+		senderIds = []string{}
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return models.PostApiKeyResponse{}, http.StatusInternalServerError, err
+	}
+
+	apiKey := models.ApiKey{
+		Id:          uuid.NewV4().String(),
+		WorkspaceId: workspaceId,
+		Name:        param.Name,
+		Prefix:      key[:displayLength],
+		KeyHash:     hashKey(key),
+		Scopes:      scopes,
+		SenderIds:   senderIds,
+		Role:        param.Role,
+		Owner:       utils.NormalizeEmail(param.Owner),
+		ExpiresAt:   param.ExpiresAt,
+		CreatedAt:   time.Now(),
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateApiKey(ctx, tx, apiKey); err != nil {
+			return err
+		}
+
+		return u.auditor.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceApiKey, apiKey.Id, nil, apiKey)
+	})
+	if utils.IsForeignKeyViolation(err) {
+		return models.PostApiKeyResponse{}, http.StatusBadRequest, fmt.Errorf("workspace: %s not found", workspaceId)
+	}
+	if err != nil {
+		return models.PostApiKeyResponse{}, http.StatusInternalServerError, err
+	}
+
+	return models.PostApiKeyResponse{
+		ApiKey: apiKey,
+		Key:    key,
+	}, http.StatusCreated, nil
+}
+
+// RevokeApiKey implements ApiKeyUsecase.
+func (u Usecase) RevokeApiKey(ctx context.Context, id string) (int, error) {
+	workspaceId := WorkspaceFromContext(ctx)
+	if IsOperator(ctx) {
+		workspaceId = ""
+	}
+
+	revokedAt := time.Now()
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.RevokeApiKey(ctx, tx, workspaceId, id, revokedAt); err != nil {
+			return err
+		}
+
+		return u.auditor.Record(ctx, tx, models.AuditActionRevoke, models.AuditResourceApiKey, id, nil, map[string]any{
+			"revokedAt": revokedAt,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("api key: %s not found or already revoked", id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// Authenticate implements ApiKeyUsecase.
+func (u Usecase) Authenticate(ctx context.Context, token string) (models.ApiKey, int, error) {
+	apiKey, err := u.repository.ReadApiKeyByHash(ctx, u.db, hashKey(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ApiKey{}, http.StatusUnauthorized, fmt.Errorf("invalid api key")
+	}
+	if err != nil {
+		return models.ApiKey{}, http.StatusInternalServerError, err
+	}
+
+	return u.use(ctx, apiKey)
+}
+
+// AuthenticateStream implements ApiKeyUsecase.
+func (u Usecase) AuthenticateStream(ctx context.Context, token string, path string) (models.ApiKey, int, error) {
+	apiKeyId, err := parseStreamToken(u.cfg.TokenSecret, token, path, time.Now())
+	if err != nil {
+		return models.ApiKey{}, http.StatusUnauthorized, err
+	}
+
+	apiKey, err := u.repository.ReadApiKey(ctx, u.db, apiKeyId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ApiKey{}, http.StatusUnauthorized, fmt.Errorf("invalid stream token")
+	}
+	if err != nil {
+		return models.ApiKey{}, http.StatusInternalServerError, err
+	}
+
+	return u.use(ctx, apiKey)
+}
+
+// use refuses a key that is revoked or expired and notes that it was used
+func (u Usecase) use(ctx context.Context, apiKey models.ApiKey) (models.ApiKey, int, error) {
+	now := time.Now()
+
+	if apiKey.RevokedAt != nil {
+		return models.ApiKey{}, http.StatusUnauthorized, fmt.Errorf("api key has been revoked")
+	}
+
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now) {
+		return models.ApiKey{}, http.StatusUnauthorized, fmt.Errorf("api key has expired")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+			return u.repository.UpdateApiKeyLastUsed(ctx, tx, apiKey.Id, now)
+		})
+		if err != nil {
+			// not worth failing the request for
+			log.Printf("fail to update last use of api key: %s, err: %s", apiKey.Id, err.Error())
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	return apiKey, http.StatusOK, nil
+}
+
+func NewUsecase(db *sqlx.DB, repository ApiKeyRepository, auditor Auditor, cfg config.Config) ApiKeyUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+		auditor:    auditor,
+		cfg:        cfg,
+	}
+}
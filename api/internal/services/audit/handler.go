@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase AuditUsecase
+}
+
+// GetAuditLog implements AuditHandler.
+func (h Handler) GetAuditLog(e echo.Context) error {
+	var request models.GetAuditRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	results, statusCode, err := h.usecase.GetAuditLog(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+func NewHandler(usecase AuditUsecase) AuditHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// append only log of the changes made through the api, see Recorder
+// an entry is written in the transaction of its change, so there is no change without its entry
+
+type AuditHandler interface {
+	GetAuditLog(e echo.Context) error
+}
+
+type AuditUsecase interface {
+	GetAuditLog(ctx context.Context, param models.GetAuditRequest) ([]models.AuditEntry, int, error)
+}
+
+type AuditRepository interface {
+	CreateEntry(ctx context.Context, tx *sqlx.Tx, param models.AuditEntry) error
+	// ReadEntries returns the newest entries first, an empty workspace reads every workspace
+	ReadEntries(ctx context.Context, db *sqlx.DB, param models.GetAuditRequest, from *time.Time, to *time.Time) ([]models.AuditEntry, error)
+}
@@ -0,0 +1,22 @@
+package audit
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+func Load(e *echo.Echo, db *sqlx.DB, authorize apikey.Authorizer) {
+
+	// init repository
+	repository := NewRepository()
+
+	// init usecase
+	usecase := NewUsecase(db, repository)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler, authorize)
+}
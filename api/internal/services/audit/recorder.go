@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+type requestKey struct{}
+
+// Request is where a request came from
+type Request struct {
+	Ip        string
+	RequestId string
+}
+
+// Middleware keeps where each request came from for the entries it records
+// the request id is the one the request id middleware answers with
+func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		requestId := e.Response().Header().Get(echo.HeaderXRequestID)
+		if requestId == "" {
+			requestId = e.Request().Header.Get(echo.HeaderXRequestID)
+		}
+
+		ctx := context.WithValue(e.Request().Context(), requestKey{}, Request{
+			Ip:        e.RealIP(),
+			RequestId: requestId,
+		})
+		e.SetRequest(e.Request().WithContext(ctx))
+
+		return next(e)
+	}
+}
+
+// RequestFromContext returns where the request came from, empty outside of a request
+func RequestFromContext(ctx context.Context) Request {
+	request, _ := ctx.Value(requestKey{}).(Request)
+	return request
+}
+
+// Recorder appends the entries of the changes the services make
+type Recorder struct {
+	repository AuditRepository
+}
+
+func NewRecorder(repository AuditRepository) Recorder {
+	return Recorder{
+		repository: repository,
+	}
+}
+
+// System is the actor of the changes the api makes without an api key, such as the unsubscribe links and the bounce processor
+type System struct {
+	WorkspaceId string
+	Name        string
+}
+
+// Record appends the entry of a change made in tx, the actor is the api key of the request
+// before and after are the resource around the change, nil when it did not exist,
+// an update only keeps the fields that changed
+func (r Recorder) Record(ctx context.Context, tx *sqlx.Tx, action string, resource string, resourceId string, before any, after any) error {
+	entry := models.AuditEntry{
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+	}
+
+	if apiKey, ok := apikey.FromContext(ctx); ok {
+		entry.ApiKeyId = &apiKey.Id
+		entry.ApiKeyName = apiKey.Name
+	}
+
+	return r.record(ctx, tx, entry, action, resource, resourceId, before, after)
+}
+
+// RecordSystem appends the entry of a change the system made in tx for the workspace of system, as Record does
+func (r Recorder) RecordSystem(ctx context.Context, tx *sqlx.Tx, system System, action string, resource string, resourceId string, before any, after any) error {
+	entry := models.AuditEntry{
+		WorkspaceId: system.WorkspaceId,
+		ApiKeyName:  "system: " + system.Name,
+	}
+
+	return r.record(ctx, tx, entry, action, resource, resourceId, before, after)
+}
+
+func (r Recorder) record(ctx context.Context, tx *sqlx.Tx, entry models.AuditEntry, action string, resource string, resourceId string, before any, after any) error {
+	beforeState, err := state(before)
+	if err != nil {
+		return err
+	}
+
+	afterState, err := state(after)
+	if err != nil {
+		return err
+	}
+
+	if beforeState != nil && afterState != nil {
+		for key, value := range beforeState {
+			if other, ok := afterState[key]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeState, key)
+				delete(afterState, key)
+			}
+		}
+	}
+
+	request := RequestFromContext(ctx)
+	entry.Ip = request.Ip
+	entry.RequestId = request.RequestId
+	entry.Action = action
+	entry.Resource = resource
+	entry.ResourceId = resourceId
+	entry.Before = beforeState
+	entry.After = afterState
+	entry.CreatedAt = time.Now()
+
+	return r.repository.CreateEntry(ctx, tx, entry)
+}
+
+// state returns the fields of a resource as the api shows them, so secrets never reach the log
+func state(resource any) (models.AuditState, error) {
+	if resource == nil {
+		return nil, nil
+	}
+
+	value := reflect.ValueOf(resource)
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.AuditState{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
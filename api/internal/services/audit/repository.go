@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const entryColumns = `id, workspace_id, api_key_id, api_key_name, ip, request_id, action, resource, resource_id, before, after, created_at`
+
+type Repository struct {
+}
+
+// CreateEntry implements AuditRepository.
+func (r Repository) CreateEntry(ctx context.Context, tx *sqlx.Tx, param models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (workspace_id, api_key_id, api_key_name, ip, request_id, action, resource, resource_id, before, after, created_at)
+		VALUES (:workspace_id, :api_key_id, :api_key_name, :ip, :request_id, :action, :resource, :resource_id, :before, :after, :created_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadEntries implements AuditRepository.
+func (r Repository) ReadEntries(ctx context.Context, db *sqlx.DB, param models.GetAuditRequest, from *time.Time, to *time.Time) ([]models.AuditEntry, error) {
+	results := []models.AuditEntry{}
+
+	query := `
+		SELECT ` + entryColumns + ` FROM audit_log
+		WHERE ($1 = '' OR workspace_id = $1) AND ($2 = '' OR action = $2) AND ($3 = '' OR resource = $3)
+			AND ($4 = '' OR resource_id = $4) AND ($5 = '' OR api_key_id = $5)
+			AND ($6::TIMESTAMPTZ IS NULL OR created_at >= $6) AND ($7::TIMESTAMPTZ IS NULL OR created_at < $7)
+		ORDER BY id DESC
+		LIMIT $8 OFFSET $9`
+	err := db.SelectContext(ctx, &results, query,
+		param.WorkspaceId, param.Action, param.Resource, param.ResourceId, param.ApiKeyId, from, to, param.Limit, param.Offset)
+
+	return results, err
+}
+
+func NewRepository() AuditRepository {
+	return Repository{}
+}
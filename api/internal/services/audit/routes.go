@@ -0,0 +1,11 @@
+package audit
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler AuditHandler, authorize apikey.Authorizer) {
+	e.GET("/audit", handler.GetAuditLog, authorize(models.ScopeAdmin))
+}
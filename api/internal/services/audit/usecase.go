@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository AuditRepository
+}
+
+// GetAuditLog implements AuditUsecase.
+// operators see every workspace unless they ask for one
+func (u Usecase) GetAuditLog(ctx context.Context, param models.GetAuditRequest) ([]models.AuditEntry, int, error) {
+	if !apikey.IsOperator(ctx) {
+		if param.WorkspaceId != "" && param.WorkspaceId != apikey.WorkspaceFromContext(ctx) {
+			return nil, http.StatusForbidden, fmt.Errorf("api key cannot read another workspace")
+		}
+		param.WorkspaceId = apikey.WorkspaceFromContext(ctx)
+	}
+
+	from, err := parseTime(param.From)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid from, err: %s", err.Error())
+	}
+
+	to, err := parseTime(param.To)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid to, err: %s", err.Error())
+	}
+
+	if param.Limit <= 0 {
+		param.Limit = defaultListLimit
+	}
+	if param.Limit > maxListLimit {
+		param.Limit = maxListLimit
+	}
+	if param.Offset < 0 {
+		param.Offset = 0
+	}
+
+	results, err := u.repository.ReadEntries(ctx, u.db, param, from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func NewUsecase(db *sqlx.DB, repository AuditRepository) AuditUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+	}
+}
@@ -0,0 +1,206 @@
+package bounce
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+)
+
+// imapMailbox is a minimal IMAP4rev1 client, it only speaks the handful of commands the processor needs
+type imapMailbox struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse is what the server sent back for a single tagged command
+type imapResponse struct {
+	lines    []string
+	literals [][]byte
+}
+
+func NewIMAPDialer(cfg config.BounceConfig) Dialer {
+	return func() (Mailbox, error) {
+		conn, err := dial(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		m := &imapMailbox{
+			conn: conn,
+			r:    bufio.NewReader(conn),
+		}
+
+		// greeting
+		line, err := m.readLine()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "* OK") {
+			conn.Close()
+			return nil, fmt.Errorf("unexpected imap greeting: %s", line)
+		}
+
+		if _, err := m.command("LOGIN " + imapQuote(cfg.Username) + " " + imapQuote(cfg.Password)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		folder := cfg.Folder
+		if folder == "" {
+			folder = "INBOX"
+		}
+
+		if _, err := m.command("SELECT " + imapQuote(folder)); err != nil {
+			m.Close()
+			return nil, err
+		}
+
+		return m, nil
+	}
+}
+
+// List implements Mailbox.
+func (m *imapMailbox) List() ([]string, error) {
+	res, err := m.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, line := range res.lines {
+		if !strings.HasPrefix(line, "* SEARCH") {
+			continue
+		}
+		ids = append(ids, strings.Fields(strings.TrimPrefix(line, "* SEARCH"))...)
+	}
+
+	return ids, nil
+}
+
+// Fetch implements Mailbox.
+// BODY.PEEK leaves the message unseen until it is acknowledged
+func (m *imapMailbox) Fetch(id string) ([]byte, error) {
+	res, err := m.command("UID FETCH " + id + " (BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.literals) == 0 {
+		return nil, fmt.Errorf("message: %s not found", id)
+	}
+
+	return res.literals[0], nil
+}
+
+// Ack implements Mailbox.
+func (m *imapMailbox) Ack(id string) error {
+	_, err := m.command("UID STORE " + id + ` +FLAGS.SILENT (\Seen)`)
+	return err
+}
+
+// Close implements Mailbox.
+func (m *imapMailbox) Close() error {
+	m.command("LOGOUT")
+	return m.conn.Close()
+}
+
+// command sends a tagged command and collects everything up to its tagged completion
+func (m *imapMailbox) command(command string) (imapResponse, error) {
+	m.tag++
+	tag := "t" + strconv.Itoa(m.tag)
+
+	if _, err := fmt.Fprintf(m.conn, "%s %s\r\n", tag, command); err != nil {
+		return imapResponse{}, err
+	}
+
+	var res imapResponse
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return imapResponse{}, err
+		}
+
+		// a line ending with {n} is followed by n raw bytes, then the rest of the line
+		for {
+			size, ok := literalSize(line)
+			if !ok {
+				break
+			}
+
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(m.r, literal); err != nil {
+				return imapResponse{}, err
+			}
+			res.literals = append(res.literals, literal)
+
+			rest, err := m.readLine()
+			if err != nil {
+				return imapResponse{}, err
+			}
+			line = rest
+		}
+
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return imapResponse{}, fmt.Errorf("imap command failed: %s", status)
+			}
+			return res, nil
+		}
+
+		res.lines = append(res.lines, line)
+	}
+}
+
+func (m *imapMailbox) readLine() (string, error) {
+	line, err := m.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+
+	start := strings.LastIndex(line, "{")
+	if start < 0 {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(line[start+1 : len(line)-1])
+	if err != nil {
+		return 0, false
+	}
+
+	return size, true
+}
+
+func imapQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+func dial(cfg config.BounceConfig) (net.Conn, error) {
+	address := net.JoinHostPort(cfg.Host, cfg.Port)
+
+	if cfg.TLS {
+		return tls.Dial("tcp", address, &tls.Config{
+			ServerName: cfg.Host,
+		})
+	}
+
+	return net.Dial("tcp", address)
+}
@@ -0,0 +1,32 @@
+package bounce
+
+import (
+	"context"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+// bounce processor
+// it polls the bounce mailbox over imap or pop3
+// it parses delivery status notifications and common non standard bounces
+// it matches each bounce to the original email and records it
+// hard bounces are added to the suppression list
+
+// Mailbox is a connection to the mailbox bounces are delivered to
+type Mailbox interface {
+	// List returns the ids of the messages waiting in the mailbox
+	List() ([]string, error)
+	// Fetch returns the raw RFC 5322 message for an id returned by List
+	Fetch(id string) ([]byte, error)
+	// Ack marks a message as processed so it is not fetched again
+	Ack(id string) error
+	Close() error
+}
+
+// Dialer opens a new mailbox connection for every poll
+type Dialer func() (Mailbox, error)
+
+type BounceUsecase interface {
+	ProcessMailbox(ctx context.Context) (processed int, err error)
+	RecordBounce(ctx context.Context, bounce models.Bounce) error
+}
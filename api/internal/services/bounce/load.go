@@ -0,0 +1,64 @@
+package bounce
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/services/email"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/jmoiron/sqlx"
+)
+
+const defaultPollInterval = 5 * time.Minute
+
+func Load(db *sqlx.DB, cfg config.BounceConfig, verp config.VerpConfig, secret string) {
+	if cfg.Host == "" {
+		log.Print("bounce mailbox not configured, bounce processor disabled")
+		return
+	}
+
+	// init dialer
+	var dial Dialer
+	switch strings.ToLower(cfg.Protocol) {
+	case "pop3":
+		dial = NewPOP3Dialer(cfg)
+	default:
+		dial = NewIMAPDialer(cfg)
+	}
+
+	// init usecase
+	usecase := NewUsecase(db, email.NewRepository(), suppression.NewRepository(), audit.NewRecorder(audit.NewRepository()), dial, verp, secret)
+
+	// init processor
+	interval := cfg.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	go Poll(context.Background(), usecase, interval)
+}
+
+// Poll processes the bounce mailbox on every tick until ctx is done
+func Poll(ctx context.Context, usecase BounceUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := usecase.ProcessMailbox(ctx)
+		if err != nil {
+			log.Printf("fail to process bounce mailbox: %s", err.Error())
+		} else if processed > 0 {
+			log.Printf("processed %d bounces", processed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package bounce
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+)
+
+var standInMessage = string(dsn(recipientBlock("failed", "gone@example.org", "5.1.1", "550 5.1.1 user unknown")))
+
+// standIn serves a single connection with handle and records every command it reads
+type standIn struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+	done     chan struct{}
+}
+
+func newStandIn(t *testing.T, greeting string, handle func(s *standIn, w *bufio.Writer, line string) bool) *standIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &standIn{listener: listener, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		w := bufio.NewWriter(conn)
+
+		w.WriteString(greeting + "\r\n")
+		w.Flush()
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			s.mu.Lock()
+			s.commands = append(s.commands, line)
+			s.mu.Unlock()
+
+			more := handle(s, w, line)
+			w.Flush()
+			if !more {
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *standIn) config() config.BounceConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return config.BounceConfig{
+		Host:     host,
+		Port:     port,
+		Username: "bounces@toa.example.com",
+		Password: "secret",
+	}
+}
+
+func (s *standIn) received(command string) bool {
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, line := range s.commands {
+		if strings.Contains(line, command) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestIMAPMailbox(t *testing.T) {
+	server := newStandIn(t, "* OK stand-in ready", func(s *standIn, w *bufio.Writer, line string) bool {
+		tag, command, _ := strings.Cut(line, " ")
+
+		switch {
+		case strings.HasPrefix(command, "UID SEARCH"):
+			w.WriteString("* SEARCH 7\r\n")
+		case strings.HasPrefix(command, "UID FETCH 7"):
+			fmt.Fprintf(w, "* 1 FETCH (UID 7 BODY[] {%d}\r\n%s)\r\n", len(standInMessage), standInMessage)
+		case strings.HasPrefix(command, "LOGOUT"):
+			w.WriteString("* BYE\r\n")
+			w.WriteString(tag + " OK LOGOUT completed\r\n")
+			return false
+		}
+
+		w.WriteString(tag + " OK completed\r\n")
+		return true
+	})
+
+	mailbox, err := NewIMAPDialer(server.config())()
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	ids, err := mailbox.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "7" {
+		t.Fatalf("List() = %v, want [7]", ids)
+	}
+
+	raw, err := mailbox.Fetch("7")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	bounces, err := parseBounces(raw)
+	if err != nil || len(bounces) != 1 || bounces[0].Recipient != "gone@example.org" {
+		t.Fatalf("parseBounces() = %+v, %v", bounces, err)
+	}
+
+	if err := mailbox.Ack("7"); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	mailbox.Close()
+
+	for _, command := range []string{`LOGIN "bounces@toa.example.com" "secret"`, `SELECT "INBOX"`, `UID STORE 7 +FLAGS.SILENT (\Seen)`} {
+		if !server.received(command) {
+			t.Errorf("stand-in did not receive %q", command)
+		}
+	}
+}
+
+func TestIMAPMailboxLoginRejected(t *testing.T) {
+	server := newStandIn(t, "* OK stand-in ready", func(s *standIn, w *bufio.Writer, line string) bool {
+		tag, _, _ := strings.Cut(line, " ")
+		w.WriteString(tag + " NO invalid credentials\r\n")
+		return false
+	})
+
+	if _, err := NewIMAPDialer(server.config())(); err == nil {
+		t.Fatal("dial succeeded with rejected credentials")
+	}
+}
+
+func TestPOP3Mailbox(t *testing.T) {
+	server := newStandIn(t, "+OK stand-in ready", func(s *standIn, w *bufio.Writer, line string) bool {
+		switch {
+		case line == "UIDL":
+			w.WriteString("+OK\r\n1 uid-abc\r\n.\r\n")
+		case line == "RETR 1":
+			w.WriteString("+OK\r\n")
+			for _, body := range strings.Split(strings.TrimSuffix(standInMessage, "\r\n"), "\r\n") {
+				// dot stuffing
+				if strings.HasPrefix(body, ".") {
+					body = "." + body
+				}
+				w.WriteString(body + "\r\n")
+			}
+			w.WriteString(".\r\n")
+		case line == "QUIT":
+			w.WriteString("+OK bye\r\n")
+			return false
+		default:
+			w.WriteString("+OK\r\n")
+		}
+
+		return true
+	})
+
+	mailbox, err := NewPOP3Dialer(server.config())()
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	ids, err := mailbox.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "uid-abc" {
+		t.Fatalf("List() = %v, want [uid-abc]", ids)
+	}
+
+	raw, err := mailbox.Fetch("uid-abc")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	bounces, err := parseBounces(raw)
+	if err != nil || len(bounces) != 1 || bounces[0].Recipient != "gone@example.org" {
+		t.Fatalf("parseBounces() = %+v, %v", bounces, err)
+	}
+
+	if err := mailbox.Ack("uid-abc"); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	if err := mailbox.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for _, command := range []string{"USER bounces@toa.example.com", "PASS secret", "DELE 1", "QUIT"} {
+		if !server.received(command) {
+			t.Errorf("stand-in did not receive %q", command)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+var (
+	bounceSubjectPattern = regexp.MustCompile(`(?i)(undeliver|delivery status notification|delivery failure|mail delivery failed|returned mail|failure notice|could not be delivered|delivery has failed)`)
+	bounceSenderPattern  = regexp.MustCompile(`(?i)(mailer-daemon|postmaster)`)
+	messageIdPattern     = regexp.MustCompile(`(?im)^message-id:\s*(<[^>\s]+>)`)
+	returnPathPattern    = regexp.MustCompile(`(?im)^return-path:\s*<([^>\s]+)>`)
+	enhancedCodePattern  = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+	smtpCodePattern      = regexp.MustCompile(`\b([45])\d\d\b`)
+	addressPattern       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// wording used by servers that do not send enhanced status codes
+	softBouncePattern = regexp.MustCompile(`(?i)(mailbox (is )?full|over quota|quota exceeded|temporar|try again later|deferred|delayed)`)
+)
+
+// parsedMessage is a bounce message broken into the pieces the classifier needs
+type parsedMessage struct {
+	header mail.Header
+	// per recipient blocks of a message/delivery-status part
+	statuses []textproto.MIMEHeader
+	// headers of the original message quoted in the bounce
+	original string
+	// every text part, used by the non standard fallback
+	text string
+}
+
+// parseBounces returns the bounced recipients found in raw
+// it returns nothing for a message that is not a bounce, e.g. a human reply to the sender address
+func parseBounces(raw []byte) ([]models.Bounce, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := parsedMessage{header: msg.Header}
+	if err := parsed.walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+
+	messageId := ""
+	if match := messageIdPattern.FindStringSubmatch(parsed.original); match != nil {
+		messageId = match[1]
+	} else if match := messageIdPattern.FindStringSubmatch(parsed.text); match != nil {
+		messageId = match[1]
+	}
+
+	var bounces []models.Bounce
+	if len(parsed.statuses) > 0 {
+		bounces = parsed.standardBounces(messageId)
+	} else if parsed.looksLikeBounce() {
+		bounces = parsed.nonStandardBounces(messageId)
+	}
+
+	deliveredTo := parsed.deliveredTo()
+	for index := range bounces {
+		bounces[index].DeliveredTo = deliveredTo
+	}
+
+	return bounces, nil
+}
+
+// deliveredTo collects the envelope addresses the bounce was sent to
+// the receiving server records them in these headers, and the quoted original keeps its Return-Path
+func (p *parsedMessage) deliveredTo() []string {
+	addresses := []string{}
+
+	for _, key := range []string{"Delivered-To", "X-Original-To", "Envelope-To", "To"} {
+		for _, value := range p.header[textproto.CanonicalMIMEHeaderKey(key)] {
+			list, err := mail.ParseAddressList(value)
+			if err != nil {
+				addresses = append(addresses, strings.Trim(strings.TrimSpace(value), "<>"))
+				continue
+			}
+
+			for _, address := range list {
+				addresses = append(addresses, address.Address)
+			}
+		}
+	}
+
+	for _, match := range returnPathPattern.FindAllStringSubmatch(p.original, -1) {
+		addresses = append(addresses, match[1])
+	}
+
+	return addresses
+}
+
+// walk collects the parts of interest, descending into nested multiparts
+func (p *parsedMessage) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	body = decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				// a truncated bounce still carries what we need in the parts read so far
+				return nil
+			}
+
+			if err := p.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case "message/delivery-status", "message/global-delivery-status":
+		p.statuses = append(p.statuses, parseDeliveryStatus(content)...)
+	case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+		p.original += string(content)
+	default:
+		if strings.HasPrefix(mediaType, "text/") {
+			p.text += string(content) + "\n"
+		}
+	}
+
+	return nil
+}
+
+// standardBounces reads the recipient blocks of an RFC 3464 report
+func (p *parsedMessage) standardBounces(messageId string) []models.Bounce {
+	bounces := []models.Bounce{}
+
+	for _, status := range p.statuses {
+		action := strings.ToLower(strings.TrimSpace(status.Get("Action")))
+		if action != "failed" && action != "delayed" {
+			// delivered, relayed and expanded are successes
+			continue
+		}
+
+		recipient := addressFromField(status.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = addressFromField(status.Get("Original-Recipient"))
+		}
+
+		code := strings.TrimSpace(status.Get("Status"))
+		diagnostic := strings.TrimSpace(status.Get("Diagnostic-Code"))
+
+		bounceType := classify(code, diagnostic)
+		if action == "delayed" {
+			bounceType = models.BounceTypeSoft
+		}
+
+		bounces = append(bounces, models.Bounce{
+			Recipient:  recipient,
+			Type:       bounceType,
+			Status:     code,
+			Diagnostic: diagnostic,
+			MessageId:  messageId,
+		})
+	}
+
+	return bounces
+}
+
+// nonStandardBounces handles qmail, exim and similar plain text bounces
+func (p *parsedMessage) nonStandardBounces(messageId string) []models.Bounce {
+	recipients := []string{}
+
+	// exim and some others name the failed recipients in a header
+	if failed := p.header.Get("X-Failed-Recipients"); failed != "" {
+		for _, address := range strings.Split(failed, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				recipients = append(recipients, address)
+			}
+		}
+	}
+
+	// otherwise the first address in the explanation that is not the daemon itself
+	if len(recipients) == 0 {
+		for _, address := range addressPattern.FindAllString(p.text, -1) {
+			if !bounceSenderPattern.MatchString(address) {
+				recipients = append(recipients, address)
+				break
+			}
+		}
+	}
+
+	code := ""
+	if match := enhancedCodePattern.FindString(p.text); match != "" {
+		code = match
+	}
+
+	diagnostic := firstDiagnosticLine(p.text)
+	bounceType := classify(code, p.text)
+
+	bounces := []models.Bounce{}
+	for _, recipient := range recipients {
+		bounces = append(bounces, models.Bounce{
+			Recipient:  recipient,
+			Type:       bounceType,
+			Status:     code,
+			Diagnostic: diagnostic,
+			MessageId:  messageId,
+		})
+	}
+
+	// no recipient but a quoted Message-ID is still enough to find the email
+	if len(bounces) == 0 && messageId != "" {
+		bounces = append(bounces, models.Bounce{
+			Type:       bounceType,
+			Status:     code,
+			Diagnostic: diagnostic,
+			MessageId:  messageId,
+		})
+	}
+
+	return bounces
+}
+
+func (p *parsedMessage) looksLikeBounce() bool {
+	if p.header.Get("X-Failed-Recipients") != "" {
+		return true
+	}
+
+	if bounceSubjectPattern.MatchString(p.header.Get("Subject")) {
+		return true
+	}
+
+	return bounceSenderPattern.MatchString(p.header.Get("From")) || p.header.Get("Return-Path") == "<>"
+}
+
+// classify turns a status code and diagnostic text into a hard or soft bounce
+// 5.x.x is permanent and 4.x.x is transient, without a code we fall back to the wording
+func classify(code string, diagnostic string) string {
+	if match := enhancedCodePattern.FindStringSubmatch(code); match != nil {
+		if match[1] == "4" {
+			return models.BounceTypeSoft
+		}
+		return models.BounceTypeHard
+	}
+
+	if match := enhancedCodePattern.FindStringSubmatch(diagnostic); match != nil {
+		if match[1] == "4" {
+			return models.BounceTypeSoft
+		}
+		return models.BounceTypeHard
+	}
+
+	if softBouncePattern.MatchString(diagnostic) {
+		return models.BounceTypeSoft
+	}
+
+	if match := smtpCodePattern.FindStringSubmatch(diagnostic); match != nil && match[1] == "4" {
+		return models.BounceTypeSoft
+	}
+
+	return models.BounceTypeHard
+}
+
+// parseDeliveryStatus splits a message/delivery-status body into its blocks
+// the first block describes the message, the rest describe one recipient each
+func parseDeliveryStatus(content []byte) []textproto.MIMEHeader {
+	blocks := []textproto.MIMEHeader{}
+
+	normalized := strings.ReplaceAll(string(content), "\r\n", "\n")
+	for _, block := range strings.Split(normalized, "\n\n") {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+
+		reader := textproto.NewReader(bufio.NewReader(strings.NewReader(block + "\n\n")))
+		header, err := reader.ReadMIMEHeader()
+		if err != nil && len(header) == 0 {
+			continue
+		}
+
+		if header.Get("Action") != "" {
+			blocks = append(blocks, header)
+		}
+	}
+
+	return blocks
+}
+
+// addressFromField reads fields such as "rfc822; user@example.com"
+func addressFromField(value string) string {
+	if _, address, found := strings.Cut(value, ";"); found {
+		value = address
+	}
+
+	value = strings.Trim(strings.TrimSpace(value), "<>")
+	return value
+}
+
+func firstDiagnosticLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if enhancedCodePattern.MatchString(line) || smtpCodePattern.MatchString(line) {
+			return line
+		}
+	}
+
+	return ""
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
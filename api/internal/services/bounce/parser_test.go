@@ -0,0 +1,229 @@
+package bounce
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+// dsn builds an RFC 3464 report with one recipient block per entry of recipients
+func dsn(recipients ...string) []byte {
+	message := strings.Join([]string{
+		"From: Mail Delivery System <MAILER-DAEMON@mx.example.net>",
+		"To: bounces+42@toa.example.com",
+		"Delivered-To: bounces+42@toa.example.com",
+		"Subject: Undelivered Mail Returned to Sender",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"`,
+		"",
+		"--BOUNDARY",
+		"Content-Type: text/plain",
+		"",
+		"This is the mail system at host mx.example.net.",
+		"",
+		"--BOUNDARY",
+		"Content-Type: message/delivery-status",
+		"",
+		"Reporting-MTA: dns; mx.example.net",
+		"",
+		strings.Join(recipients, "\n\n"),
+		"",
+		"--BOUNDARY",
+		"Content-Type: text/rfc822-headers",
+		"",
+		"Return-Path: <bounces+42@toa.example.com>",
+		"Message-ID: <original@toa.example.com>",
+		"Subject: hello",
+		"",
+		"--BOUNDARY--",
+		"",
+	}, "\r\n")
+
+	return []byte(message)
+}
+
+func recipientBlock(action string, recipient string, status string, diagnostic string) string {
+	return strings.Join([]string{
+		"Final-Recipient: rfc822; " + recipient,
+		"Action: " + action,
+		"Status: " + status,
+		"Diagnostic-Code: smtp; " + diagnostic,
+	}, "\r\n")
+}
+
+func TestParseBouncesStandard(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want []models.Bounce
+	}{
+		{
+			name: "hard bounce",
+			raw:  dsn(recipientBlock("failed", "gone@example.org", "5.1.1", "550 5.1.1 user unknown")),
+			want: []models.Bounce{{Recipient: "gone@example.org", Type: models.BounceTypeHard, Status: "5.1.1"}},
+		},
+		{
+			name: "soft bounce",
+			raw:  dsn(recipientBlock("failed", "full@example.org", "4.2.2", "452 4.2.2 mailbox full")),
+			want: []models.Bounce{{Recipient: "full@example.org", Type: models.BounceTypeSoft, Status: "4.2.2"}},
+		},
+		{
+			name: "delayed is soft whatever its code",
+			raw:  dsn(recipientBlock("delayed", "slow@example.org", "5.0.0", "still trying")),
+			want: []models.Bounce{{Recipient: "slow@example.org", Type: models.BounceTypeSoft, Status: "5.0.0"}},
+		},
+		{
+			name: "delivered recipients are left out",
+			raw: dsn(
+				recipientBlock("delivered", "ok@example.org", "2.0.0", "250 ok"),
+				recipientBlock("failed", "gone@example.org", "5.1.1", "550 5.1.1 user unknown"),
+			),
+			want: []models.Bounce{{Recipient: "gone@example.org", Type: models.BounceTypeHard, Status: "5.1.1"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bounces, err := parseBounces(test.raw)
+			if err != nil {
+				t.Fatalf("parseBounces() error = %v", err)
+			}
+
+			if len(bounces) != len(test.want) {
+				t.Fatalf("parseBounces() returned %d bounces, want %d: %+v", len(bounces), len(test.want), bounces)
+			}
+
+			for i, want := range test.want {
+				got := bounces[i]
+				if got.Recipient != want.Recipient || got.Type != want.Type || got.Status != want.Status {
+					t.Errorf("bounce %d = %+v, want %+v", i, got, want)
+				}
+
+				if got.MessageId != "<original@toa.example.com>" {
+					t.Errorf("bounce %d message id = %q", i, got.MessageId)
+				}
+
+				if !contains(got.DeliveredTo, "bounces+42@toa.example.com") {
+					t.Errorf("bounce %d delivered to = %v", i, got.DeliveredTo)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBouncesNonStandard(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []models.Bounce
+	}{
+		{
+			name: "exim names the failed recipients in a header",
+			raw: "From: Mail Delivery System <Mailer-Daemon@mx.example.net>\r\n" +
+				"Subject: Mail delivery failed: returning message to sender\r\n" +
+				"X-Failed-Recipients: gone@example.org\r\n" +
+				"\r\n" +
+				"This message was created automatically by mail delivery software.\r\n" +
+				"  gone@example.org\r\n" +
+				"    SMTP error from remote mail server after RCPT TO:<gone@example.org>:\r\n" +
+				"    550 5.1.1 No such user\r\n" +
+				"\r\n" +
+				"Message-ID: <original@toa.example.com>\r\n",
+			want: []models.Bounce{{Recipient: "gone@example.org", Type: models.BounceTypeHard, Status: "5.1.1", MessageId: "<original@toa.example.com>"}},
+		},
+		{
+			name: "qmail mentions the recipient in the text",
+			raw: "From: MAILER-DAEMON@mx.example.net\r\n" +
+				"Subject: failure notice\r\n" +
+				"\r\n" +
+				"Hi. This is the qmail-send program at mx.example.net.\r\n" +
+				"<full@example.org>:\r\n" +
+				"Sorry, the mailbox is over quota, try again later.\r\n",
+			want: []models.Bounce{{Recipient: "full@example.org", Type: models.BounceTypeSoft}},
+		},
+		{
+			name: "a reply to the sender is not a bounce",
+			raw: "From: Someone <someone@example.org>\r\n" +
+				"Subject: Re: hello\r\n" +
+				"\r\n" +
+				"Thanks, see you at someone@example.org\r\n",
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bounces, err := parseBounces([]byte(test.raw))
+			if err != nil {
+				t.Fatalf("parseBounces() error = %v", err)
+			}
+
+			if len(bounces) != len(test.want) {
+				t.Fatalf("parseBounces() returned %d bounces, want %d: %+v", len(bounces), len(test.want), bounces)
+			}
+
+			for i, want := range test.want {
+				got := bounces[i]
+				if got.Recipient != want.Recipient || got.Type != want.Type || got.Status != want.Status || got.MessageId != want.MessageId {
+					t.Errorf("bounce %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		code       string
+		diagnostic string
+		want       string
+	}{
+		{"5.1.1", "", models.BounceTypeHard},
+		{"4.4.7", "", models.BounceTypeSoft},
+		{"", "550 5.7.1 rejected", models.BounceTypeHard},
+		{"", "421 4.7.0 try later", models.BounceTypeSoft},
+		{"", "mailbox full", models.BounceTypeSoft},
+		{"", "451 local error", models.BounceTypeSoft},
+		{"", "user unknown", models.BounceTypeHard},
+	}
+
+	for _, test := range tests {
+		if got := classify(test.code, test.diagnostic); got != test.want {
+			t.Errorf("classify(%q, %q) = %s, want %s", test.code, test.diagnostic, got, test.want)
+		}
+	}
+}
+
+func TestUpgrades(t *testing.T) {
+	soft, hard := models.BounceTypeSoft, models.BounceTypeHard
+
+	tests := []struct {
+		current *string
+		bounce  string
+		want    bool
+	}{
+		{nil, soft, true},
+		{nil, hard, true},
+		{&soft, hard, true},
+		{&soft, soft, false},
+		{&hard, hard, false},
+		{&hard, soft, false},
+	}
+
+	for _, test := range tests {
+		if got := upgrades(test.current, test.bounce); got != test.want {
+			t.Errorf("upgrades(%v, %s) = %v, want %v", test.current, test.bounce, got, test.want)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
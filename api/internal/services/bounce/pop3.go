@@ -0,0 +1,129 @@
+package bounce
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+)
+
+// pop3Mailbox is a minimal POP3 client
+// messages are addressed by UIDL so ids stay stable across connections
+// acknowledged messages are deleted when the session ends
+type pop3Mailbox struct {
+	conn *textproto.Conn
+	// uidl to message number for the current session
+	numbers map[string]string
+}
+
+func NewPOP3Dialer(cfg config.BounceConfig) Dialer {
+	return func() (Mailbox, error) {
+		conn, err := dial(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		m := &pop3Mailbox{
+			conn:    textproto.NewConn(conn),
+			numbers: map[string]string{},
+		}
+
+		// greeting
+		if _, err := m.readStatus(); err != nil {
+			m.conn.Close()
+			return nil, err
+		}
+
+		if _, err := m.command("USER %s", cfg.Username); err != nil {
+			m.conn.Close()
+			return nil, err
+		}
+
+		if _, err := m.command("PASS %s", cfg.Password); err != nil {
+			m.conn.Close()
+			return nil, err
+		}
+
+		return m, nil
+	}
+}
+
+// List implements Mailbox.
+func (m *pop3Mailbox) List() ([]string, error) {
+	if _, err := m.command("UIDL"); err != nil {
+		return nil, err
+	}
+
+	lines, err := m.conn.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		m.numbers[fields[1]] = fields[0]
+		ids = append(ids, fields[1])
+	}
+
+	return ids, nil
+}
+
+// Fetch implements Mailbox.
+func (m *pop3Mailbox) Fetch(id string) ([]byte, error) {
+	number, ok := m.numbers[id]
+	if !ok {
+		return nil, fmt.Errorf("message: %s not found", id)
+	}
+
+	if _, err := m.command("RETR %s", number); err != nil {
+		return nil, err
+	}
+
+	return m.conn.ReadDotBytes()
+}
+
+// Ack implements Mailbox.
+func (m *pop3Mailbox) Ack(id string) error {
+	number, ok := m.numbers[id]
+	if !ok {
+		return fmt.Errorf("message: %s not found", id)
+	}
+
+	_, err := m.command("DELE %s", number)
+	return err
+}
+
+// Close implements Mailbox.
+// QUIT is what commits the deletes
+func (m *pop3Mailbox) Close() error {
+	_, err := m.command("QUIT")
+	m.conn.Close()
+	return err
+}
+
+func (m *pop3Mailbox) command(format string, args ...any) (string, error) {
+	if err := m.conn.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+
+	return m.readStatus()
+}
+
+func (m *pop3Mailbox) readStatus() (string, error) {
+	line, err := m.conn.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("pop3 command failed: %s", line)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
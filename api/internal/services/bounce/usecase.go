@@ -0,0 +1,236 @@
+package bounce
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/services/email"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+type Usecase struct {
+	db                    *sqlx.DB
+	emailRepository       email.EmailRepository
+	suppressionRepository suppression.SuppressionRepository
+	recorder              audit.Recorder
+	dial                  Dialer
+	verp                  config.VerpConfig
+	// signs VERP return paths
+	secret string
+
+	// messages that are not bounces stay in the mailbox, remember them so they are only parsed once
+	mu      *sync.Mutex
+	ignored map[string]bool
+}
+
+// ProcessMailbox implements BounceUsecase.
+func (u Usecase) ProcessMailbox(ctx context.Context) (processed int, err error) {
+	mailbox, err := u.dial()
+	if err != nil {
+		return 0, fmt.Errorf("unable to connect to bounce mailbox, err: %s", err.Error())
+	}
+	defer mailbox.Close()
+
+	ids, err := mailbox.List()
+	if err != nil {
+		return 0, err
+	}
+
+	u.forgetGone(ids)
+
+	for _, id := range ids {
+		if u.isIgnored(id) {
+			continue
+		}
+
+		raw, err := mailbox.Fetch(id)
+		if err != nil {
+			return processed, err
+		}
+
+		bounces, err := parseBounces(raw)
+		if err != nil || len(bounces) == 0 {
+			u.ignore(id)
+			continue
+		}
+
+		for _, bounce := range bounces {
+			if err := u.RecordBounce(ctx, bounce); err != nil {
+				return processed, err
+			}
+		}
+
+		if err := mailbox.Ack(id); err != nil {
+			return processed, err
+		}
+
+		processed++
+	}
+
+	return processed, nil
+}
+
+// RecordBounce implements BounceUsecase.
+func (u Usecase) RecordBounce(ctx context.Context, bounce models.Bounce) error {
+	original, err := u.matchEmail(ctx, bounce)
+	if err != nil {
+		return err
+	}
+
+	recipient := bounce.Recipient
+	if recipient == "" && original != nil {
+		recipient = original.Email
+	}
+
+	if original == nil {
+		log.Printf("bounce for: %s (%s) does not match any email", recipient, bounce.MessageId)
+	}
+
+	return utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if original != nil && upgrades(original.BounceType, bounce.Type) {
+			now := time.Now()
+			diagnostic := fmt.Sprintf("%s bounce: %s %s", bounce.Type, bounce.Status, bounce.Diagnostic)
+
+			original.BounceType = &bounce.Type
+			original.BouncedAt = &now
+			original.Log = &diagnostic
+
+			// a soft bounce or a delayed notice is not final, the remote server keeps trying
+			if bounce.Type == models.BounceTypeHard {
+				original.Status = models.EmailStatusBounced
+			}
+
+			if err := u.emailRepository.UpdateEmail(ctx, tx, *original); err != nil {
+				return err
+			}
+
+			if bounce.Type == models.BounceTypeHard {
+				if err := u.emailRepository.IncrementBatchBounceCount(ctx, tx, original.WorkspaceId, original.BatchId); err != nil {
+					return err
+				}
+			}
+		}
+
+		// only a bounce of an email we sent suppresses, anything else in the mailbox could be backscatter or spam
+		if bounce.Type != models.BounceTypeHard || original == nil || recipient == "" {
+			return nil
+		}
+
+		suppression := models.Suppression{
+			WorkspaceId: original.WorkspaceId,
+			Email:       utils.NormalizeEmail(recipient),
+			Reason:      models.SuppressionReasonHardBounce,
+			Source:      "bounce processor, batch: " + original.BatchId,
+		}
+
+		if err := u.suppressionRepository.CreateSuppression(ctx, tx, suppression); err != nil {
+			return err
+		}
+
+		system := audit.System{WorkspaceId: original.WorkspaceId, Name: "bounce processor"}
+		return u.recorder.RecordSystem(ctx, tx, system, models.AuditActionCreate, models.AuditResourceSuppression, suppression.Email, nil, suppression)
+	})
+}
+
+// matchEmail finds the email a bounce refers to, it returns nil when there is no match
+// a VERP return path names the exact email so it wins over the quoted Message-ID
+// VERP addresses are only read while it is enabled, a plus addressed mailbox would otherwise match unrelated ids
+func (u Usecase) matchEmail(ctx context.Context, bounce models.Bounce) (*models.Email, error) {
+	if u.verp.Enabled {
+		for _, address := range bounce.DeliveredTo {
+			emailId, ok := utils.ParseVerpAddress(u.secret, u.verp.Prefix, u.verp.Domain, address)
+			if !ok {
+				continue
+			}
+
+			original, err := u.emailRepository.ReadAnyEmailById(ctx, u.db, emailId)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			return &original, nil
+		}
+	}
+
+	if bounce.MessageId == "" {
+		return nil, nil
+	}
+
+	original, err := u.emailRepository.ReadAnyEmailByMessageId(ctx, u.db, bounce.MessageId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &original, nil
+}
+
+// upgrades reports whether a bounce is news for an email that already bounced as current
+// the first bounce is kept, except that a hard bounce replaces an earlier soft one
+func upgrades(current *string, bounceType string) bool {
+	if current == nil {
+		return true
+	}
+
+	return *current == models.BounceTypeSoft && bounceType == models.BounceTypeHard
+}
+
+func (u Usecase) isIgnored(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.ignored[id]
+}
+
+func (u Usecase) ignore(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.ignored[id] = true
+}
+
+// forgetGone drops the ignored messages that have left the mailbox, so the set never outgrows it
+func (u Usecase) forgetGone(ids []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+
+	for id := range u.ignored {
+		if !listed[id] {
+			delete(u.ignored, id)
+		}
+	}
+}
+
+func NewUsecase(db *sqlx.DB, emailRepository email.EmailRepository, suppressionRepository suppression.SuppressionRepository, recorder audit.Recorder, dial Dialer, verp config.VerpConfig, secret string) BounceUsecase {
+	return Usecase{
+		db:                    db,
+		emailRepository:       emailRepository,
+		suppressionRepository: suppressionRepository,
+		recorder:              recorder,
+		dial:                  dial,
+		verp:                  verp,
+		secret:                secret,
+		mu:                    &sync.Mutex{},
+		ignored:               map[string]bool{},
+	}
+}
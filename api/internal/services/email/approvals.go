@@ -0,0 +1,263 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// stops StreamEmails once the sample recipient is found
+var errSampleFound = errors.New("sample found")
+
+// GetPendingApprovalBatches implements EmailUsecase.
+func (u Usecase) GetPendingApprovalBatches(ctx context.Context, param models.GetBatchRequest) ([]models.EmailBatch, int, error) {
+	return u.listBatches(ctx, models.BatchStatusPendingApproval, param)
+}
+
+// GetBatchApproval implements EmailUsecase.
+func (u Usecase) GetBatchApproval(ctx context.Context, batchId string) (models.GetBatchApprovalResponse, int, error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.GetBatchApprovalResponse{}, statusCode, err
+	}
+
+	decisions, err := u.repository.ReadBatchApprovals(ctx, u.db, batch.WorkspaceId, batchId)
+	if err != nil {
+		return models.GetBatchApprovalResponse{}, http.StatusInternalServerError, err
+	}
+
+	sample, err := u.sampleBatch(ctx, batch)
+	if err != nil {
+		return models.GetBatchApprovalResponse{}, http.StatusInternalServerError, err
+	}
+
+	return models.GetBatchApprovalResponse{
+		Batch:          batch,
+		RecipientCount: batch.EmailCount - batch.SuppressedCount,
+		Sample:         sample,
+		Decisions:      decisions,
+	}, http.StatusOK, nil
+}
+
+// ApproveBatch implements EmailUsecase.
+// the batch goes to the workers, or waits for its send time when that is still to come
+func (u Usecase) ApproveBatch(ctx context.Context, batchId string, param models.PostBatchApprovalRequest) (models.EmailBatch, int, error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	if statusCode, err := checkApprover(ctx, batch); err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	status := models.BatchStatusQueued
+	if batch.SendAt != nil && batch.SendAt.After(time.Now()) {
+		status = models.BatchStatusScheduled
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batchId, []string{models.BatchStatusPendingApproval}, status); err != nil {
+			return err
+		}
+
+		if err := u.repository.CreateBatchApproval(ctx, tx, newApproval(ctx, batch, models.ApprovalDecisionApproved, param.Comment)); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionApprove, models.AuditResourceBatch, batchId, batchStatus(batch.Status), batchStatus(status))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusConflict, fmt.Errorf("batch: %s is %s, it is not waiting for approval", batchId, batch.Status)
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	batch, statusCode, err = u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	if batch.Status == models.BatchStatusQueued {
+		go u.runBatch(batch)
+	}
+
+	return batch, http.StatusOK, nil
+}
+
+// RejectBatch implements EmailUsecase.
+// the batch is canceled with none of its emails sent
+func (u Usecase) RejectBatch(ctx context.Context, batchId string, param models.PostBatchApprovalRequest) (models.EmailBatch, int, error) {
+	if param.Comment == "" {
+		return models.EmailBatch{}, http.StatusBadRequest, fmt.Errorf("comment is required to reject a batch")
+	}
+
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	if statusCode, err := checkApprover(ctx, batch); err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batchId, []string{models.BatchStatusPendingApproval}, models.BatchStatusCanceled); err != nil {
+			return err
+		}
+
+		if err := u.repository.UpdateEmailsStatus(ctx, tx, batch.WorkspaceId, batchId, models.EmailStatusPending, models.EmailStatusCanceled); err != nil {
+			return err
+		}
+
+		if err := u.repository.UpdateBatchCounts(ctx, tx, batch.WorkspaceId, batchId); err != nil {
+			return err
+		}
+
+		if err := u.repository.FinishBatch(ctx, tx, batch.WorkspaceId, batchId, time.Now()); err != nil {
+			return err
+		}
+
+		if err := u.repository.CreateBatchApproval(ctx, tx, newApproval(ctx, batch, models.ApprovalDecisionRejected, param.Comment)); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionReject, models.AuditResourceBatch, batchId, batchStatus(batch.Status), batchStatus(models.BatchStatusCanceled))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusConflict, fmt.Errorf("batch: %s is %s, it is not waiting for approval", batchId, batch.Status)
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	batch, statusCode, err = u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	u.notify(batchId, func(observer BatchObserver) error {
+		return observer.BatchCompleted(ctx, batch)
+	})
+
+	return batch, http.StatusOK, nil
+}
+
+// needsApproval reports whether a batch of count recipients waits for an approver before it is sent
+func (u Usecase) needsApproval(ctx context.Context, batch models.EmailBatch, count int) (bool, error) {
+	if u.cfg.Approval.Threshold > 0 && count > u.cfg.Approval.Threshold {
+		return true, nil
+	}
+
+	if batch.SenderId == nil {
+		return false, nil
+	}
+
+	sender, err := u.repository.ReadSender(ctx, u.db, batch.WorkspaceId, *batch.SenderId)
+	if err != nil {
+		return false, err
+	}
+
+	return sender.RequiresApproval, nil
+}
+
+// sampleBatch renders the content of a batch for its first recipient that is not suppressed, nil when there is none
+// links are left as written, a tracked link followed by the approver would count as the recipient's click
+func (u Usecase) sampleBatch(ctx context.Context, batch models.EmailBatch) (*models.BatchSample, error) {
+	var recipient *models.Email
+	err := u.repository.StreamEmails(ctx, u.db, batch.WorkspaceId, batch.Id, "", func(email models.Email) error {
+		if email.Status == models.EmailStatusSuppressed {
+			return nil
+		}
+
+		recipient = &email
+		return errSampleFound
+	})
+	if err != nil && !errors.Is(err, errSampleFound) {
+		return nil, err
+	}
+
+	if recipient == nil {
+		return nil, nil
+	}
+
+	content, err := compileContent(Email{
+		Subject:  batch.Subject,
+		Body:     batch.Body,
+		Template: batch.Template,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := content.render(TemplateData{
+		UnsubscribeURL: suppression.UnsubscribeURL(u.cfg, batch.Id, recipient.Email),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BatchSample{
+		To:      recipient.Email,
+		From:    batch.From,
+		Subject: message.Subject,
+		Body:    message.Body,
+		Html:    message.Template,
+	}, nil
+}
+
+// checkApprover lets only a second person decide a batch
+// the decision takes a key with the approve scope that is issued to a person,
+// and neither that key nor another key of the same owner may have requested the batch
+func checkApprover(ctx context.Context, batch models.EmailBatch) (int, error) {
+	apiKey, ok := apikey.FromContext(ctx)
+	if !ok {
+		return http.StatusForbidden, fmt.Errorf("batch: %s can only be decided with an api key", batch.Id)
+	}
+
+	if !apiKey.HasScope(models.ScopeEmailApprove) {
+		return http.StatusForbidden, fmt.Errorf("api key is missing scope: %s", models.ScopeEmailApprove)
+	}
+
+	if apiKey.Owner == "" {
+		return http.StatusForbidden, fmt.Errorf("batch: %s can only be decided with an api key issued to an owner", batch.Id)
+	}
+
+	if batch.ApiKeyId != nil && *batch.ApiKeyId == apiKey.Id {
+		return http.StatusForbidden, fmt.Errorf("batch: %s cannot be decided with the api key that created it", batch.Id)
+	}
+
+	if batch.RequestedBy != "" && batch.RequestedBy == apiKey.Owner {
+		return http.StatusForbidden, fmt.Errorf("batch: %s cannot be decided by the owner who requested it", batch.Id)
+	}
+
+	return http.StatusOK, nil
+}
+
+func newApproval(ctx context.Context, batch models.EmailBatch, decision string, comment string) models.BatchApproval {
+	approval := models.BatchApproval{
+		Id:          uuid.NewV4().String(),
+		BatchId:     batch.Id,
+		WorkspaceId: batch.WorkspaceId,
+		Decision:    decision,
+		Comment:     comment,
+		CreatedAt:   time.Now(),
+	}
+
+	if apiKey, ok := apikey.FromContext(ctx); ok {
+		approval.ApiKeyId = &apiKey.Id
+	}
+
+	return approval
+}
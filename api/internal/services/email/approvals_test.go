@@ -0,0 +1,40 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+)
+
+func TestCheckApprover(t *testing.T) {
+	requesterKey := "key-1"
+	batch := models.EmailBatch{Id: "batch", ApiKeyId: &requesterKey, RequestedBy: "author@example.com"}
+
+	approver := func(id string, owner string, scopes ...string) context.Context {
+		return apikey.NewContext(context.Background(), models.ApiKey{Id: id, Owner: owner, Scopes: scopes})
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"another person", approver("key-2", "lead@example.com", models.ScopeEmailApprove), http.StatusOK},
+		{"an admin", approver("key-2", "lead@example.com", models.ScopeAdmin), http.StatusOK},
+		{"without a key", context.Background(), http.StatusForbidden},
+		{"without the approve scope", approver("key-2", "lead@example.com", models.ScopeEmailSend), http.StatusForbidden},
+		{"a key without an owner", approver("key-2", "", models.ScopeEmailApprove), http.StatusForbidden},
+		{"the requesting key", approver("key-1", "lead@example.com", models.ScopeEmailApprove), http.StatusForbidden},
+		{"the requester with a second key", approver("key-2", "author@example.com", models.ScopeEmailApprove), http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		statusCode, err := checkApprover(test.ctx, batch)
+		if statusCode != test.want || (err != nil) != (test.want != http.StatusOK) {
+			t.Errorf("%s: checkApprover() = %d, %v, want %d", test.name, statusCode, err, test.want)
+		}
+	}
+}
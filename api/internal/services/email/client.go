@@ -1,23 +1,53 @@
 package email
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type EmailConfig struct {
-	EmailFrom     string
-	EmailAlias    string
+	EmailFrom  string
+	EmailAlias string
+	// written as the Reply-To header unless the message sets its own
+	ReplyTo string
+	// smtp login, defaults to the from address
+	SMTPUsername  string
 	EmailPassword string
 	SMTPHost      string
 	SMTPPort      string
+
+	// messages are DKIM signed when a selector, domain and key file or key are set
+	DKIMSelector       string
+	DKIMDomain         string
+	DKIMPrivateKeyFile string
+	// PEM encoded key, used in place of the key file
+	DKIMPrivateKey []byte
+	// header names to sign, a sensible default list is used when empty
+	DKIMHeaders []string
 }
 
 type EmailClient struct {
+	// batches are sent concurrently over the one connection, a transaction must not interleave with another
+	mu     *sync.Mutex
 	client *smtp.Client
+	composer
+}
+
+// composer renders the messages of a sender identity, the smtp client and the http api senders share it
+type composer struct {
 	config EmailConfig
+	dkim   *dkimSigner
 }
 
 type Email struct {
@@ -25,9 +55,26 @@ type Email struct {
 	Subject  string
 	Body     string
 	Template string
+	// extra headers such as List-Unsubscribe, written after the standard ones
+	Headers map[string]string
+	// envelope sender, bounces are delivered here, defaults to the from address
+	ReturnPath string
+	// sent as multipart/mixed after the body when set
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
 }
 
 func NewClient(cfg EmailConfig) (EmailClient, error) {
+	signer, err := newClientSigner(cfg)
+	if err != nil {
+		return EmailClient{}, err
+	}
+
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: false,
 		ServerName:         cfg.SMTPHost,
@@ -44,7 +91,12 @@ func NewClient(cfg EmailConfig) (EmailClient, error) {
 	}
 
 	// authenticate client
-	auth := smtp.PlainAuth("", cfg.EmailFrom, cfg.EmailPassword, cfg.SMTPHost)
+	username := cfg.SMTPUsername
+	if username == "" {
+		username = cfg.EmailFrom
+	}
+
+	auth := smtp.PlainAuth("", username, cfg.EmailPassword, cfg.SMTPHost)
 	if err := client.Auth(auth); err != nil {
 		return EmailClient{}, err
 	}
@@ -52,68 +104,193 @@ func NewClient(cfg EmailConfig) (EmailClient, error) {
 	log.Printf("SMTP client for email: %s connected", cfg.EmailFrom)
 
 	return EmailClient{
+		mu:     &sync.Mutex{},
 		client: client,
-		config: cfg,
+		composer: composer{
+			config: cfg,
+			dkim:   signer,
+		},
 	}, nil
 }
 
+// newClientSigner returns the dkim signer configured by cfg, nil when signing is not configured
+func newClientSigner(cfg EmailConfig) (*dkimSigner, error) {
+	if cfg.DKIMSelector == "" || cfg.DKIMDomain == "" || (cfg.DKIMPrivateKeyFile == "" && len(cfg.DKIMPrivateKey) == 0) {
+		return nil, nil
+	}
+
+	return newDkimSigner(cfg)
+}
+
 // if content type is text/html then the email body will be ignored
 func (c *EmailClient) SendMail(param Email) error {
+	message, err := c.buildMessage(param)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// set sender
-	if err := c.client.Mail(c.config.EmailFrom); err != nil {
+	returnPath := param.ReturnPath
+	if returnPath == "" {
+		returnPath = c.config.EmailFrom
+	}
+
+	if err := c.send(returnPath, param.To, message); err != nil {
+		// abort the transaction so a rejected recipient does not fail the messages after it
+		c.client.Reset()
+		return err
+	}
+
+	log.Printf("email sent from: %s to: %s", c.config.EmailFrom, param.To)
+
+	return nil
+
+}
+
+// send runs one smtp transaction, the caller holds the lock
+func (c *EmailClient) send(returnPath string, to string, message []byte) error {
+	if err := c.client.Mail(returnPath); err != nil {
 		return err
 	}
 
 	// set recipient
-	if err := c.client.Rcpt(param.To); err != nil {
+	if err := c.client.Rcpt(to); err != nil {
+		return err
+	}
+
+	// send data
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
 		return err
 	}
 
+	return w.Close()
+}
+
+// buildMessage renders the full message with CRLF line endings and signs it when DKIM is configured
+func (c composer) buildMessage(param Email) ([]byte, error) {
 	// switch content type and body
 	var contentType string
 	var contentBody string
 
 	if param.Template != "" {
-		contentType = "text/html"
+		contentType = "text/html; charset=\"UTF-8\""
 		contentBody = param.Template
 	} else {
-		contentType = "text/plain"
+		contentType = "text/plain; charset=\"UTF-8\""
 		contentBody = param.Body
 	}
 
-	// send data
-	w, err := c.client.Data()
-	if err != nil {
-		return err
+	if len(param.Attachments) > 0 {
+		var err error
+		contentType, contentBody, err = buildMultipart(contentType, contentBody, param.Attachments)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	headers := c.buildHeaders(param, contentType)
 
-	fullMessage := headers + contentBody
+	// the smtp data writer turns bare LF into CRLF, do it up front so the signed body is what goes out
+	contentBody = strings.ReplaceAll(contentBody, "\r\n", "\n")
+	contentBody = strings.ReplaceAll(contentBody, "\n", "\r\n")
 
-	if _, err := w.Write([]byte(fullMessage)); err != nil {
-		return err
-	}
+	fullMessage := headers + contentBody
 
-	if err := w.Close(); err != nil {
-		return err
+	if c.dkim != nil {
+		signature, err := c.dkim.Sign([]byte(fullMessage))
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign message, err: %s", err.Error())
+		}
+		fullMessage = signature + fullMessage
 	}
 
-	log.Printf("email sent from: %s to: %s", c.config.EmailFrom, param.To)
-
-	return nil
-
+	return []byte(fullMessage), nil
 }
 
-func (c *EmailClient) buildHeaders(param Email, contentType string) string {
+func (c composer) buildHeaders(param Email, contentType string) string {
 	headers := "From: \"" + c.config.EmailAlias + "\" <" + c.config.EmailFrom + ">\r\n" +
 		"To: " + param.To + "\r\n" +
 		"Subject: " + param.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
 		"MIME-Version: 1.0\r\n" +
-		"Content-Type: " + contentType + "; charset=\"UTF-8\"\r\n" +
-		"\r\n"
+		"Content-Type: " + contentType + "\r\n"
+
+	if _, ok := param.Headers["Reply-To"]; !ok && c.config.ReplyTo != "" {
+		headers += "Reply-To: " + c.config.ReplyTo + "\r\n"
+	}
+
+	// sort so the output is stable between sends
+	keys := make([]string, 0, len(param.Headers))
+	for key := range param.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		headers += key + ": " + param.Headers[key] + "\r\n"
+	}
+
+	return headers + "\r\n"
+}
+
+// buildMultipart wraps the body and the attachments in a multipart/mixed body
+func buildMultipart(contentType string, contentBody string, attachments []Attachment) (string, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {contentType},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := part.Write([]byte(contentBody)); err != nil {
+		return "", "", err
+	}
+
+	for _, attachment := range attachments {
+		attachmentType := attachment.ContentType
+		if attachmentType == "" {
+			attachmentType = "application/octet-stream"
+		}
+
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachmentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return "", "", err
+		}
+
+		// base64 lines are kept to 76 characters as RFC 2045 asks
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return "", "", err
+			}
+			encoded = encoded[76:]
+		}
+
+		if _, err := part.Write([]byte(encoded)); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
 
-	return headers
+	return "multipart/mixed; boundary=\"" + writer.Boundary() + "\"", buf.String(), nil
 }
 
 func (c *EmailClient) Quit() error {
@@ -0,0 +1,374 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/webhook"
+	"github.com/abbyfakhri/toa-api/internal/services/workspace"
+	"github.com/jmoiron/sqlx"
+)
+
+// ClientPool hands out the Sender a batch sends through
+// a workspace without smtp settings of its own sends through the server client, as the server sender
+// a sender without a smtp account shares the connection of its workspace and only changes who the message is from
+// a workspace with relays sends through them instead, unless the sender has a smtp account or an http api of its own
+// a client is connected on first use and again once its settings change or the server drops it
+type ClientPool struct {
+	db         *sqlx.DB
+	workspaces workspace.WorkspaceRepository
+	repository EmailRepository
+	fallback   EmailClient
+	breakers   *breakers
+	// shared by the http api senders
+	http *http.Client
+
+	mu        sync.Mutex
+	connected map[string]connectedClient
+}
+
+type connectedClient struct {
+	client EmailClient
+	// settings the client was connected with
+	updatedAt time.Time
+}
+
+const serverClientKey = "server"
+
+func NewClientPool(db *sqlx.DB, workspaces workspace.WorkspaceRepository, repository EmailRepository, fallback EmailClient, cfg config.Config) *ClientPool {
+	return &ClientPool{
+		db:         db,
+		workspaces: workspaces,
+		repository: repository,
+		fallback:   fallback,
+		breakers:   newBreakers(cfg.Relay),
+		// guarded like webhook deliveries, the api url of a sender is set by the workspace
+		http: webhook.NewClient(defaultApiTimeout, cfg.Webhook.AllowPrivate),
+		// the server client is pooled too so it can be dialed again
+		connected: map[string]connectedClient{serverClientKey: {client: fallback}},
+	}
+}
+
+// get returns the Sender of a batch, connecting it when needed
+func (c *ClientPool) get(ctx context.Context, batch models.EmailBatch) (Sender, error) {
+	item, sender, err := c.resolve(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	if sender != nil && sender.HasSmtp() {
+		key, updatedAt, config := "sender:"+sender.Id, sender.UpdatedAt, senderConfig(*sender)
+
+		return c.redial(key, func() (Sender, error) {
+			client, err := c.connect(key, updatedAt, config)
+			if err != nil {
+				return nil, err
+			}
+
+			return &client, nil
+		})
+	}
+
+	identity, err := c.identity(item, sender)
+	if err != nil {
+		return nil, err
+	}
+
+	if sender != nil && !sender.UsesSmtp() {
+		return newApiSender(*sender, identity, c.http)
+	}
+
+	relays, err := c.repository.ReadRelays(ctx, c.db, batch.WorkspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(relays) == 0 {
+		return c.home(item, identity)
+	}
+
+	route := &router{
+		pool:     c,
+		relays:   relays,
+		identity: identity,
+		home: func() (Sender, error) {
+			return c.home(item, identity)
+		},
+	}
+	if sender != nil {
+		route.senderId = sender.Id
+	}
+
+	return route, nil
+}
+
+// signing returns who the messages of a batch are from and how they are signed, as get would send them, without connecting
+func (c *ClientPool) signing(ctx context.Context, batch models.EmailBatch) (composer, error) {
+	item, sender, err := c.resolve(ctx, batch)
+	if err != nil {
+		return composer{}, err
+	}
+
+	// a sender with a smtp account is signed with its own key or not at all
+	if sender != nil && sender.HasSmtp() {
+		config := senderConfig(*sender)
+
+		signer, err := newClientSigner(config)
+		if err != nil {
+			return composer{}, err
+		}
+
+		return composer{config: config, dkim: signer}, nil
+	}
+
+	return c.identity(item, sender)
+}
+
+// resolve reads the workspace of a batch and its sender, nil when the batch is sent as the workspace
+func (c *ClientPool) resolve(ctx context.Context, batch models.EmailBatch) (models.Workspace, *models.Sender, error) {
+	item, err := c.workspaces.ReadWorkspace(ctx, c.db, batch.WorkspaceId)
+	if err != nil {
+		return models.Workspace{}, nil, err
+	}
+
+	if batch.SenderId == nil {
+		return item, nil, nil
+	}
+
+	sender, err := c.repository.ReadSender(ctx, c.db, batch.WorkspaceId, *batch.SenderId)
+	if err != nil {
+		return models.Workspace{}, nil, err
+	}
+
+	return item, &sender, nil
+}
+
+// from returns the address a batch is sent from, without connecting
+func (c *ClientPool) from(ctx context.Context, batch models.EmailBatch) (string, error) {
+	if batch.SenderId != nil {
+		sender, err := c.repository.ReadSender(ctx, c.db, batch.WorkspaceId, *batch.SenderId)
+		if err != nil {
+			return "", err
+		}
+
+		return sender.EmailFrom, nil
+	}
+
+	item, err := c.workspaces.ReadWorkspace(ctx, c.db, batch.WorkspaceId)
+	if err != nil {
+		return "", err
+	}
+
+	if !item.HasSmtp() {
+		return c.fallback.config.EmailFrom, nil
+	}
+
+	return item.EmailFrom, nil
+}
+
+// identity returns who the messages of a workspace and sender are from and how they are signed, it is not connected
+func (c *ClientPool) identity(item models.Workspace, sender *models.Sender) (composer, error) {
+	identity := c.fallback.composer
+	if item.HasSmtp() {
+		identity = composer{
+			config: EmailConfig{
+				EmailFrom:  item.EmailFrom,
+				EmailAlias: item.EmailAlias,
+			},
+		}
+	}
+
+	if sender == nil {
+		return identity, nil
+	}
+
+	signer, err := newClientSigner(senderConfig(*sender))
+	if err != nil {
+		return composer{}, err
+	}
+
+	identity.config.EmailFrom = sender.EmailFrom
+	identity.config.EmailAlias = sender.EmailAlias
+	identity.config.ReplyTo = sender.ReplyTo
+	// without a key of its own the sender is signed as its workspace
+	if signer != nil {
+		identity.dkim = signer
+	}
+
+	return identity, nil
+}
+
+// home returns the connection of a workspace, the server client when it has no smtp settings
+func (c *ClientPool) home(item models.Workspace, identity composer) (Sender, error) {
+	key, updatedAt, config := serverClientKey, time.Time{}, c.fallback.config
+	if item.HasSmtp() {
+		key, updatedAt = "workspace:"+item.Id, item.UpdatedAt
+		config = EmailConfig{
+			EmailFrom:     item.EmailFrom,
+			EmailAlias:    item.EmailAlias,
+			SMTPUsername:  item.SmtpUsername,
+			EmailPassword: item.SmtpPassword,
+			SMTPHost:      item.SmtpHost,
+			SMTPPort:      item.SmtpPort,
+		}
+	}
+
+	return c.redial(key, func() (Sender, error) {
+		client, err := c.connect(key, updatedAt, config)
+		if err != nil {
+			return nil, err
+		}
+
+		client = withIdentity(client, identity)
+		return &client, nil
+	})
+}
+
+// redial connects under key now, so a batch that cannot connect fails up front, and returns a Sender that looks the connection up on every message
+func (c *ClientPool) redial(key string, connect func() (Sender, error)) (Sender, error) {
+	if _, err := connect(); err != nil {
+		return nil, err
+	}
+
+	return redialed{pool: c, key: key, connect: connect}, nil
+}
+
+// relay returns the connection of a relay, sending as identity
+func (c *ClientPool) relay(relay models.Relay, identity composer) (EmailClient, error) {
+	client, err := c.connect("relay:"+relay.Id, relay.UpdatedAt, EmailConfig{
+		EmailFrom:     identity.config.EmailFrom,
+		SMTPUsername:  relay.SmtpUsername,
+		EmailPassword: relay.SmtpPassword,
+		SMTPHost:      relay.SmtpHost,
+		SMTPPort:      relay.SmtpPort,
+	})
+	if err != nil {
+		return EmailClient{}, err
+	}
+
+	return withIdentity(client, identity), nil
+}
+
+// connect returns the client connected under key, a new one when the settings changed since it was connected
+func (c *ClientPool) connect(key string, updatedAt time.Time, config EmailConfig) (EmailClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.connected[key]
+	if ok && current.updatedAt.Equal(updatedAt) {
+		return current.client, nil
+	}
+
+	client, err := NewClient(config)
+	if err != nil {
+		return EmailClient{}, err
+	}
+
+	if ok {
+		go quit(key, current.client)
+	}
+
+	c.connected[key] = connectedClient{
+		client:    client,
+		updatedAt: updatedAt,
+	}
+
+	return client, nil
+}
+
+// disconnect drops the client connected under key, the next use connects again
+func (c *ClientPool) disconnect(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.connected[key]
+	if !ok {
+		return
+	}
+
+	delete(c.connected, key)
+	go quit(key, current.client)
+}
+
+// redialed sends through the client connected under key
+// a connection the server dropped while idle is dialed again and the message sent once more
+type redialed struct {
+	pool    *ClientPool
+	key     string
+	connect func() (Sender, error)
+}
+
+// SendMail implements Sender.
+func (r redialed) SendMail(param Email) error {
+	client, err := r.connect()
+	if err != nil {
+		return err
+	}
+
+	err = client.SendMail(param)
+	if err == nil || !dropped(err) {
+		return err
+	}
+
+	log.Printf("smtp client: %s was dropped, connecting again, err: %s", r.key, err.Error())
+	r.pool.disconnect(r.key)
+
+	client, err = r.connect()
+	if err != nil {
+		return err
+	}
+
+	return client.SendMail(param)
+}
+
+// dropped reports whether the connection is gone, the server hung up, timed out or is closing it with a 421
+func dropped(err error) bool {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code == 421
+	}
+
+	return unreachable(err)
+}
+
+// quit waits for a message in flight on the connection before closing it
+func quit(key string, client EmailClient) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if err := client.Quit(); err != nil {
+		log.Printf("fail to close smtp client: %s, err: %s", key, err.Error())
+	}
+}
+
+// withIdentity returns client sending as identity over the same connection
+func withIdentity(client EmailClient, identity composer) EmailClient {
+	client.config.EmailFrom = identity.config.EmailFrom
+	client.config.EmailAlias = identity.config.EmailAlias
+	client.config.ReplyTo = identity.config.ReplyTo
+	client.dkim = identity.dkim
+
+	return client
+}
+
+func senderConfig(sender models.Sender) EmailConfig {
+	return EmailConfig{
+		EmailFrom:      sender.EmailFrom,
+		EmailAlias:     sender.EmailAlias,
+		ReplyTo:        sender.ReplyTo,
+		SMTPUsername:   sender.SmtpUsername,
+		EmailPassword:  sender.SmtpPassword,
+		SMTPHost:       sender.SmtpHost,
+		SMTPPort:       sender.SmtpPort,
+		DKIMSelector:   sender.DkimSelector,
+		DKIMDomain:     sender.DkimDomain,
+		DKIMPrivateKey: []byte(sender.DkimPrivateKey),
+	}
+}
@@ -0,0 +1,175 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"testing"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/workspace"
+	"github.com/jmoiron/sqlx"
+)
+
+// scriptedSender fails with the next error of its script, nil sends
+type scriptedSender struct {
+	errs *[]error
+}
+
+func (s scriptedSender) SendMail(param Email) error {
+	err := (*s.errs)[0]
+	*s.errs = (*s.errs)[1:]
+	return err
+}
+
+func TestRedialed(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   bool
+		wantDials int
+	}{
+		{name: "sent", errs: []error{nil}, wantDials: 1},
+		{name: "dropped while idle", errs: []error{io.EOF, nil}, wantDials: 2},
+		{name: "closing with 421", errs: []error{&textproto.Error{Code: 421, Msg: "4.4.2 idle timeout"}, nil}, wantDials: 2},
+		{name: "dropped twice", errs: []error{io.EOF, io.EOF}, wantErr: true, wantDials: 2},
+		{name: "rejected recipient", errs: []error{&textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}}, wantErr: true, wantDials: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := &ClientPool{connected: map[string]connectedClient{}}
+			errs := test.errs
+
+			dials := 0
+			sender := redialed{pool: pool, key: "workspace:1", connect: func() (Sender, error) {
+				dials++
+				return scriptedSender{errs: &errs}, nil
+			}}
+
+			if err := sender.SendMail(Email{To: "someone@example.org"}); (err != nil) != test.wantErr {
+				t.Fatalf("SendMail() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if dials != test.wantDials {
+				t.Errorf("connected %d times, want %d", dials, test.wantDials)
+			}
+		})
+	}
+}
+
+func TestDropped(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"hung up", io.EOF, true},
+		{"broken pipe", &net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")}, true},
+		{"closing", &textproto.Error{Code: 421, Msg: "4.4.2 closing connection"}, true},
+		{"greylisted", &textproto.Error{Code: 451, Msg: "4.7.1 try again later"}, false},
+		{"rejected", &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}, false},
+		{"build failure", errors.New("unable to sign message"), false},
+	}
+
+	for _, test := range tests {
+		if got := dropped(test.err); got != test.want {
+			t.Errorf("%s: dropped() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// poolWorkspaces serves the workspaces the pool reads
+type poolWorkspaces struct {
+	workspace.WorkspaceRepository
+
+	workspaces map[string]models.Workspace
+}
+
+func (r poolWorkspaces) ReadWorkspace(ctx context.Context, db *sqlx.DB, id string) (models.Workspace, error) {
+	return r.workspaces[id], nil
+}
+
+// poolSenders serves the senders the pool reads
+type poolSenders struct {
+	EmailRepository
+
+	senders map[string]models.Sender
+}
+
+func (r poolSenders) ReadSender(ctx context.Context, db *sqlx.DB, workspaceId string, senderId string) (models.Sender, error) {
+	return r.senders[senderId], nil
+}
+
+func TestClientPoolSigning(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	server, err := newDkimSigner(EmailConfig{DKIMSelector: "toa", DKIMDomain: "server.example.com", DKIMPrivateKey: []byte(pemKey)})
+	if err != nil {
+		t.Fatalf("newDkimSigner() error = %v", err)
+	}
+
+	pool := NewClientPool(nil,
+		poolWorkspaces{workspaces: map[string]models.Workspace{
+			"shared": {Id: "shared"},
+			"own":    {Id: "own", SmtpHost: "smtp.own.example.com", EmailFrom: "hello@own.example.com"},
+		}},
+		poolSenders{senders: map[string]models.Sender{
+			"signed":      {Id: "signed", EmailFrom: "news@sender.example.com", DkimSelector: "s1", DkimDomain: "sender.example.com", DkimPrivateKey: pemKey},
+			"unsigned":    {Id: "unsigned", EmailFrom: "news@sender.example.com"},
+			"smtp":        {Id: "smtp", EmailFrom: "news@smtp.example.com", SmtpHost: "smtp.example.com"},
+			"smtp-signed": {Id: "smtp-signed", EmailFrom: "news@smtp.example.com", SmtpHost: "smtp.example.com", DkimSelector: "s1", DkimDomain: "smtp.example.com", DkimPrivateKey: pemKey},
+		}},
+		EmailClient{composer: composer{config: EmailConfig{EmailFrom: "toa@server.example.com"}, dkim: server}},
+		config.Config{},
+	)
+
+	tests := []struct {
+		name      string
+		workspace string
+		senderId  string
+		// signing domain, empty when the messages are not signed
+		want string
+	}{
+		{name: "workspace on the server client", workspace: "shared", want: "server.example.com"},
+		{name: "workspace with its own smtp", workspace: "own"},
+		{name: "sender with a key", workspace: "shared", senderId: "signed", want: "sender.example.com"},
+		{name: "sender without a key", workspace: "shared", senderId: "unsigned", want: "server.example.com"},
+		{name: "smtp sender without a key", workspace: "shared", senderId: "smtp"},
+		{name: "smtp sender with a key", workspace: "own", senderId: "smtp-signed", want: "smtp.example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			batch := models.EmailBatch{WorkspaceId: test.workspace}
+			if test.senderId != "" {
+				batch.SenderId = &test.senderId
+			}
+
+			identity, err := pool.signing(context.Background(), batch)
+			if err != nil {
+				t.Fatalf("signing() error = %v", err)
+			}
+
+			domain := ""
+			if identity.dkim != nil {
+				domain = identity.dkim.domain
+			}
+
+			if domain != test.want {
+				t.Errorf("signing() signs as %q, want %q", domain, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dkimAlgorithmRSA     = "rsa-sha256"
+	dkimAlgorithmEd25519 = "ed25519-sha256"
+)
+
+// headers signed when EmailConfig.DKIMHeaders is empty
+var defaultDkimHeaders = []string{"From", "Reply-To", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post"}
+
+var whitespacePattern = regexp.MustCompile(`[ \t]+`)
+
+// dkimSigner signs outgoing messages with relaxed/relaxed canonicalization as described in RFC 6376
+type dkimSigner struct {
+	domain    string
+	selector  string
+	headers   []string
+	key       crypto.Signer
+	algorithm string
+}
+
+func newDkimSigner(cfg EmailConfig) (*dkimSigner, error) {
+	pemBytes := cfg.DKIMPrivateKey
+	if len(pemBytes) == 0 {
+		var err error
+		pemBytes, err = os.ReadFile(cfg.DKIMPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read dkim private key, err: %s", err.Error())
+		}
+	}
+
+	key, err := parseDkimPrivateKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &dkimSigner{
+		domain:   cfg.DKIMDomain,
+		selector: cfg.DKIMSelector,
+		headers:  cfg.DKIMHeaders,
+		key:      key,
+	}
+
+	if len(signer.headers) == 0 {
+		signer.headers = defaultDkimHeaders
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		signer.algorithm = dkimAlgorithmRSA
+	case ed25519.PrivateKey:
+		signer.algorithm = dkimAlgorithmEd25519
+	default:
+		return nil, fmt.Errorf("unsupported dkim key type: %T", key)
+	}
+
+	return signer, nil
+}
+
+// Sign returns the DKIM-Signature header line, including its trailing CRLF, to prepend to message
+// message must use CRLF line endings, which is what buildMessage produces
+func (s *dkimSigner) Sign(message []byte) (string, error) {
+	headers, body := splitMessage(message)
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body))
+
+	// only sign the configured headers the message actually has
+	signed := []string{}
+	for _, name := range s.headers {
+		if _, ok := findHeader(headers, name, map[int]bool{}); ok {
+			signed = append(signed, strings.ToLower(name))
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + s.algorithm,
+		"c=relaxed/relaxed",
+		"d=" + s.domain,
+		"s=" + s.selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(signed, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+		"b=",
+	}
+	value := " " + strings.Join(tags, ";\r\n\t")
+
+	digest := headerHash(headers, signed, "DKIM-Signature:"+value)
+
+	var signature []byte
+	var err error
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the sha256 hash with pure ed25519
+		signature = ed25519.Sign(key, digest)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return "DKIM-Signature:" + value + base64.StdEncoding.EncodeToString(signature) + "\r\n", nil
+}
+
+// verifyDkim checks the first DKIM-Signature of message against publicKey
+func verifyDkim(message []byte, publicKey crypto.PublicKey) error {
+	headers, body := splitMessage(message)
+
+	index, ok := findHeader(headers, "DKIM-Signature", map[int]bool{})
+	if !ok {
+		return fmt.Errorf("message has no DKIM-Signature header")
+	}
+
+	field := headers[index]
+	_, rawValue, _ := strings.Cut(field, ":")
+	tags := parseDkimTags(rawValue)
+
+	if tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unsupported canonicalization: %s", tags["c"])
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return fmt.Errorf("body hash does not match, the body was modified after signing")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %s", err.Error())
+	}
+
+	// the signature is computed with the b= value left empty
+	unsigned := emptySignatureTag(field)
+
+	signed := strings.Split(tags["h"], ":")
+	digest := headerHash(removeHeader(headers, index), signed, unsigned)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != dkimAlgorithmRSA {
+			return fmt.Errorf("signature algorithm %s does not match an rsa key", tags["a"])
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature); err != nil {
+			return fmt.Errorf("signature does not match the public key")
+		}
+	case ed25519.PublicKey:
+		if tags["a"] != dkimAlgorithmEd25519 {
+			return fmt.Errorf("signature algorithm %s does not match an ed25519 key", tags["a"])
+		}
+		if !ed25519.Verify(key, digest, signature) {
+			return fmt.Errorf("signature does not match the public key")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+
+	return nil
+}
+
+// headerHash hashes the signed headers followed by the signature header itself, without its CRLF
+func headerHash(headers []string, signed []string, signatureField string) []byte {
+	var data bytes.Buffer
+
+	// each listed name consumes the next instance from the bottom up
+	used := map[int]bool{}
+	for _, name := range signed {
+		index, ok := findHeader(headers, strings.TrimSpace(name), used)
+		if !ok {
+			continue
+		}
+		used[index] = true
+		data.WriteString(canonicalizeHeader(headers[index]))
+	}
+
+	data.WriteString(strings.TrimSuffix(canonicalizeHeader(signatureField), "\r\n"))
+
+	digest := sha256.Sum256(data.Bytes())
+	return digest[:]
+}
+
+// canonicalizeHeader applies the relaxed header algorithm of RFC 6376 section 3.4.2
+func canonicalizeHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = whitespacePattern.ReplaceAllString(value, " ")
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(value) + "\r\n"
+}
+
+// canonicalizeBody applies the relaxed body algorithm of RFC 6376 section 3.4.4
+func canonicalizeBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	for index, line := range lines {
+		line = whitespacePattern.ReplaceAllString(line, " ")
+		lines[index] = strings.TrimRight(line, " ")
+	}
+
+	// drop empty lines at the end of the body
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return []byte{}
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// splitMessage returns the header fields, each including folded lines and its CRLF, and the body
+func splitMessage(message []byte) ([]string, []byte) {
+	raw, body, _ := bytes.Cut(message, []byte("\r\n\r\n"))
+
+	headers := []string{}
+	for _, line := range strings.SplitAfter(string(raw)+"\r\n", "\r\n") {
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1] += line
+			continue
+		}
+
+		headers = append(headers, line)
+	}
+
+	return headers, body
+}
+
+// findHeader returns the last instance of name that is not in used
+func findHeader(headers []string, name string, used map[int]bool) (int, bool) {
+	for index := len(headers) - 1; index >= 0; index-- {
+		if used[index] {
+			continue
+		}
+
+		fieldName, _, _ := strings.Cut(headers[index], ":")
+		if strings.EqualFold(strings.TrimSpace(fieldName), name) {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
+// emptySignatureTag strips the value of the b= tag, keeping everything else byte for byte
+func emptySignatureTag(field string) string {
+	segments := strings.Split(field, ";")
+
+	for index, segment := range segments {
+		key, _, found := strings.Cut(segment, "=")
+		if found && strings.TrimSpace(key) == "b" {
+			segments[index] = segment[:strings.Index(segment, "=")+1]
+		}
+	}
+
+	return strings.Join(segments, ";")
+}
+
+func removeHeader(headers []string, index int) []string {
+	result := append([]string{}, headers[:index]...)
+	return append(result, headers[index+1:]...)
+}
+
+func parseDkimTags(value string) map[string]string {
+	tags := map[string]string{}
+
+	for _, tag := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(tag, "=")
+		if !found {
+			continue
+		}
+
+		// whitespace is allowed anywhere in a tag value and is not part of it
+		tags[strings.TrimSpace(key)] = strings.Join(strings.Fields(val), "")
+	}
+
+	return tags
+}
+
+func parseDkimPrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("dkim private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse dkim private key, err: %s", err.Error())
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported dkim key type: %T", key)
+	}
+
+	return signer, nil
+}
+
+// parseDkimPublicKey accepts a PEM public key, a DNS TXT record such as "v=DKIM1; k=rsa; p=..." or the bare p= value
+func parseDkimPublicKey(value string) (crypto.PublicKey, error) {
+	value = strings.TrimSpace(value)
+
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+
+	keyType := "rsa"
+	if strings.Contains(value, "p=") {
+		tags := parseDkimTags(value)
+		value = tags["p"]
+		if tags["k"] != "" {
+			keyType = tags["k"]
+		}
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not valid base64, err: %s", err.Error())
+	}
+
+	// ed25519 records carry the raw 32 byte key
+	if keyType == "ed25519" && len(der) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(der), nil
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key, err: %s", err.Error())
+	}
+
+	return key, nil
+}
+
+// dkimDNSRecord is the TXT record to publish at <selector>._domainkey.<domain>
+func (s *dkimSigner) dnsRecord() (string, error) {
+	switch key := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(key), nil
+	default:
+		return "", fmt.Errorf("unsupported dkim key type: %T", key)
+	}
+}
@@ -0,0 +1,137 @@
+package email
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+const (
+	exportFormatCsv   = "csv"
+	exportFormatJsonl = "jsonl"
+)
+
+// exportHeader is the header of the csv export, the csv columns of the batch follow when asked for
+var exportHeader = []string{
+	"email", "status", "isSent", "sentAt", "log", "messageId", "bounceType", "bouncedAt",
+	"openCount", "firstOpenedAt", "lastOpenedAt", "clickCount", "firstClickedAt",
+}
+
+// exportRow is a line of the jsonl export
+type exportRow struct {
+	Email          string            `json:"email"`
+	Status         string            `json:"status"`
+	IsSent         bool              `json:"isSent"`
+	SentAt         *time.Time        `json:"sentAt"`
+	Log            *string           `json:"log"`
+	MessageId      *string           `json:"messageId"`
+	BounceType     *string           `json:"bounceType"`
+	BouncedAt      *time.Time        `json:"bouncedAt"`
+	OpenCount      int               `json:"openCount"`
+	FirstOpenedAt  *time.Time        `json:"firstOpenedAt"`
+	LastOpenedAt   *time.Time        `json:"lastOpenedAt"`
+	ClickCount     int               `json:"clickCount"`
+	FirstClickedAt *time.Time        `json:"firstClickedAt"`
+	Columns        map[string]string `json:"columns,omitempty"`
+}
+
+// ExportBatch implements EmailUsecase.
+func (u Usecase) ExportBatch(ctx context.Context, batchId string, param models.GetBatchExportRequest) (export func(w io.Writer) error, contentType string, filename string, statusCode int, err error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return nil, "", "", statusCode, err
+	}
+
+	columns := []string{}
+	if param.IncludeColumns {
+		columns = batch.MetadataColumns
+	}
+
+	if param.Format == exportFormatJsonl {
+		return func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+
+			return u.repository.StreamEmails(ctx, u.db, batch.WorkspaceId, batch.Id, "", func(email models.Email) error {
+				row := exportRow{
+					Email:          email.Email,
+					Status:         email.Status,
+					IsSent:         email.IsSent,
+					SentAt:         email.SentAt,
+					Log:            email.Log,
+					MessageId:      email.MessageId,
+					BounceType:     email.BounceType,
+					BouncedAt:      email.BouncedAt,
+					OpenCount:      email.OpenCount,
+					FirstOpenedAt:  email.FirstOpenedAt,
+					LastOpenedAt:   email.LastOpenedAt,
+					ClickCount:     email.ClickCount,
+					FirstClickedAt: email.FirstClickedAt,
+				}
+				if param.IncludeColumns {
+					row.Columns = email.Metadata
+				}
+
+				return encoder.Encode(row)
+			})
+		}, "application/x-ndjson", batch.Id + ".jsonl", http.StatusOK, nil
+	}
+
+	return func(w io.Writer) error {
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write(append(append([]string{}, exportHeader...), columns...)); err != nil {
+			return err
+		}
+
+		err := u.repository.StreamEmails(ctx, u.db, batch.WorkspaceId, batch.Id, "", func(email models.Email) error {
+			record := []string{
+				email.Email,
+				email.Status,
+				strconv.FormatBool(email.IsSent),
+				formatExportTime(email.SentAt),
+				formatExportString(email.Log),
+				formatExportString(email.MessageId),
+				formatExportString(email.BounceType),
+				formatExportTime(email.BouncedAt),
+				strconv.Itoa(email.OpenCount),
+				formatExportTime(email.FirstOpenedAt),
+				formatExportTime(email.LastOpenedAt),
+				strconv.Itoa(email.ClickCount),
+				formatExportTime(email.FirstClickedAt),
+			}
+			for _, column := range columns {
+				record = append(record, email.Metadata[column])
+			}
+
+			return writer.Write(record)
+		})
+		if err != nil {
+			return err
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}, "text/csv", batch.Id + ".csv", http.StatusOK, nil
+}
+
+func formatExportTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+
+	return value.UTC().Format(time.RFC3339)
+}
+
+func formatExportString(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
@@ -1,9 +1,16 @@
 package email
 
 import (
+	"errors"
+	"log"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/quota"
 	"github.com/labstack/echo/v4"
 )
 
@@ -21,6 +28,7 @@ func (h Handler) PostEmailWithCsv(e echo.Context) error {
 			"error": err.Error(),
 		})
 	}
+	request.IdempotencyKey = e.Request().Header.Get(models.IdempotencyKeyHeader)
 
 	// get file
 	file, err := e.FormFile("data")
@@ -46,7 +54,471 @@ func (h Handler) PostEmailWithCsv(e echo.Context) error {
 	}
 
 	// call usecase
-	batchId, statusCode, err := h.usecase.SendEmailsWithCsv(e.Request().Context(), request, src)
+	response, statusCode, err := h.usecase.SendEmailsWithCsv(e.Request().Context(), request, src)
+	if err != nil {
+		return quotaErrorResponse(e, statusCode, err)
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": response,
+	})
+}
+
+// PostEmail implements EmailHandler.
+func (h Handler) PostEmail(e echo.Context) error {
+	var request models.PostEmailRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	request.IdempotencyKey = e.Request().Header.Get(models.IdempotencyKeyHeader)
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	response, statusCode, err := h.usecase.SendEmails(e.Request().Context(), request)
+	if err != nil {
+		return quotaErrorResponse(e, statusCode, err)
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": response,
+	})
+
+}
+
+// PostDkimVerify implements EmailHandler.
+func (h Handler) PostDkimVerify(e echo.Context) error {
+	var request models.PostDkimVerifyRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.VerifyDkim(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// GetBatch implements EmailHandler.
+func (h Handler) GetBatch(e echo.Context) error {
+	// call usecase
+	batch, statusCode, err := h.usecase.GetBatch(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// GetBatchEvents implements EmailHandler.
+// it streams server-sent events, the current state first and the completion last
+func (h Handler) GetBatchEvents(e echo.Context) error {
+	ctx := e.Request().Context()
+
+	// call usecase
+	state, events, statusCode, err := h.usecase.StreamBatch(ctx, e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	res := e.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	// keeps proxies such as nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(res, state); err != nil {
+		return nil
+	}
+
+	// the batch has already finished
+	if events == nil {
+		completed := state
+		completed.Type = models.BatchEventCompleted
+		writeEvent(res, completed)
+		return nil
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := res.Write([]byte(": keep-alive\n\n")); err != nil {
+				return nil
+			}
+			res.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if err := writeEvent(res, event); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// PostBatchEventsToken implements EmailHandler.
+func (h Handler) PostBatchEventsToken(e echo.Context) error {
+	// call usecase
+	token, statusCode, err := h.usecase.IssueStreamToken(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": token,
+	})
+}
+
+// GetBatchExport implements EmailHandler.
+func (h Handler) GetBatchExport(e echo.Context) error {
+	var request models.GetBatchExportRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	export, contentType, filename, statusCode, err := h.usecase.ExportBatch(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	res := e.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	res.WriteHeader(statusCode)
+
+	// the status is out once rows are streaming, a failure can only cut the file short
+	if err := export(res); err != nil {
+		log.Printf("fail to export batch: %s, err: %s", e.Param("id"), err.Error())
+	}
+
+	return nil
+}
+
+// GetScheduledBatches implements EmailHandler.
+func (h Handler) GetScheduledBatches(e echo.Context) error {
+	var request models.GetBatchRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batches, statusCode, err := h.usecase.GetScheduledBatches(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batches,
+	})
+}
+
+// PutBatchSchedule implements EmailHandler.
+func (h Handler) PutBatchSchedule(e echo.Context) error {
+	var request models.PutBatchScheduleRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batch, statusCode, err := h.usecase.RescheduleBatch(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return quotaErrorResponse(e, statusCode, err)
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// PostBatchPause implements EmailHandler.
+func (h Handler) PostBatchPause(e echo.Context) error {
+	// call usecase
+	batch, statusCode, err := h.usecase.PauseBatch(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// PostBatchResume implements EmailHandler.
+func (h Handler) PostBatchResume(e echo.Context) error {
+	// call usecase
+	batch, statusCode, err := h.usecase.ResumeBatch(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// PostBatchCancel implements EmailHandler.
+func (h Handler) PostBatchCancel(e echo.Context) error {
+	// call usecase
+	batch, statusCode, err := h.usecase.CancelBatch(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// PostBatchRetryFailed implements EmailHandler.
+func (h Handler) PostBatchRetryFailed(e echo.Context) error {
+	var request models.PostBatchRetryRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batchId, statusCode, err := h.usecase.RetryFailed(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return quotaErrorResponse(e, statusCode, err)
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": map[string]string{
+			"batchId": batchId,
+		},
+	})
+}
+
+// PostBatchClone implements EmailHandler.
+func (h Handler) PostBatchClone(e echo.Context) error {
+	var request models.PostBatchCloneRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batchId, statusCode, err := h.usecase.CloneBatch(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return quotaErrorResponse(e, statusCode, err)
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": map[string]string{
+			"batchId": batchId,
+		},
+	})
+}
+
+// GetPendingApprovalBatches implements EmailHandler.
+func (h Handler) GetPendingApprovalBatches(e echo.Context) error {
+	var request models.GetBatchRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batches, statusCode, err := h.usecase.GetPendingApprovalBatches(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batches,
+	})
+}
+
+// GetBatchApproval implements EmailHandler.
+func (h Handler) GetBatchApproval(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetBatchApproval(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostBatchApprove implements EmailHandler.
+func (h Handler) PostBatchApprove(e echo.Context) error {
+	var request models.PostBatchApprovalRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batch, statusCode, err := h.usecase.ApproveBatch(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
+	})
+}
+
+// PostBatchReject implements EmailHandler.
+func (h Handler) PostBatchReject(e echo.Context) error {
+	var request models.PostBatchApprovalRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	batch, statusCode, err := h.usecase.RejectBatch(e.Request().Context(), e.Param("id"), request)
 	if err != nil {
 		return e.JSON(statusCode, map[string]string{
 			"error": err.Error(),
@@ -54,16 +526,46 @@ func (h Handler) PostEmailWithCsv(e echo.Context) error {
 	}
 
 	// return response
-	return e.JSON(http.StatusAccepted, map[string]any{
-		"data": map[string]string{
-			"batchId": batchId,
-		},
+	return e.JSON(statusCode, map[string]any{
+		"data": batch,
 	})
 }
 
-// PostEmail implements EmailHandler.
-func (h Handler) PostEmail(e echo.Context) error {
-	var request models.PostEmailRequest
+// GetTemplates implements EmailHandler.
+func (h Handler) GetTemplates(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetTemplates(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// GetTemplate implements EmailHandler.
+func (h Handler) GetTemplate(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetTemplate(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostTemplate implements EmailHandler.
+func (h Handler) PostTemplate(e echo.Context) error {
+	var request models.PostTemplateRequest
 
 	// bind request
 	if err := e.Bind(&request); err != nil {
@@ -80,7 +582,7 @@ func (h Handler) PostEmail(e echo.Context) error {
 	}
 
 	// call usecase
-	batchId, statusCode, err := h.usecase.SendEmails(e.Request().Context(), request)
+	result, statusCode, err := h.usecase.CreateTemplate(e.Request().Context(), request)
 	if err != nil {
 		return e.JSON(statusCode, map[string]string{
 			"error": err.Error(),
@@ -88,12 +590,275 @@ func (h Handler) PostEmail(e echo.Context) error {
 	}
 
 	// return response
-	return e.JSON(http.StatusAccepted, map[string]any{
-		"data": map[string]string{
-			"batchId": batchId,
-		},
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PutTemplate implements EmailHandler.
+func (h Handler) PutTemplate(e echo.Context) error {
+	var request models.PutTemplateRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.UpdateTemplate(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// DeleteTemplate implements EmailHandler.
+func (h Handler) DeleteTemplate(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.DeleteTemplate(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+// GetSenders implements EmailHandler.
+func (h Handler) GetSenders(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetSenders(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// GetSender implements EmailHandler.
+func (h Handler) GetSender(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetSender(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostSender implements EmailHandler.
+func (h Handler) PostSender(e echo.Context) error {
+	var request models.PostSenderRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateSender(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PutSender implements EmailHandler.
+func (h Handler) PutSender(e echo.Context) error {
+	var request models.PutSenderRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.UpdateSender(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// DeleteSender implements EmailHandler.
+func (h Handler) DeleteSender(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.DeleteSender(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+// GetRelays implements EmailHandler.
+func (h Handler) GetRelays(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetRelays(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// GetRelay implements EmailHandler.
+func (h Handler) GetRelay(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetRelay(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostRelay implements EmailHandler.
+func (h Handler) PostRelay(e echo.Context) error {
+	var request models.PostRelayRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateRelay(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PutRelay implements EmailHandler.
+func (h Handler) PutRelay(e echo.Context) error {
+	var request models.PutRelayRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.UpdateRelay(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
 	})
+}
+
+// DeleteRelay implements EmailHandler.
+func (h Handler) DeleteRelay(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.DeleteRelay(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
 
+	// return response
+	return e.NoContent(statusCode)
 }
 
 func NewHandler(usecase EmailUsecase) EmailHandler {
@@ -101,3 +866,31 @@ func NewHandler(usecase EmailUsecase) EmailHandler {
 		usecase: usecase,
 	}
 }
+
+// quotaErrorResponse answers with err, a request over a sending quota also gets the exhausted quotas
+// with what is left of them and when they reset, and a Retry-After header set to the earliest reset
+func quotaErrorResponse(e echo.Context, statusCode int, err error) error {
+	var exceeded quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	var resetAt *time.Time
+	for _, item := range exceeded.Quotas {
+		if item.ResetAt != nil && (resetAt == nil || item.ResetAt.Before(*resetAt)) {
+			resetAt = item.ResetAt
+		}
+	}
+
+	if resetAt != nil {
+		seconds := int(math.Ceil(time.Until(*resetAt).Seconds()))
+		e.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+	}
+
+	return e.JSON(statusCode, map[string]any{
+		"error":  err.Error(),
+		"quotas": exceeded.Quotas,
+	})
+}
@@ -0,0 +1,78 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/quota"
+	"github.com/labstack/echo/v4"
+)
+
+func TestQuotaErrorResponse(t *testing.T) {
+	remaining := 0
+	soon := time.Now().Add(90 * time.Second)
+	later := time.Now().Add(time.Hour)
+
+	exceeded := quota.ExceededError{Quotas: []models.Quota{
+		{Id: "daily", Scope: models.QuotaScopeWorkspace, Period: models.QuotaPeriodDay, Limit: 100, Remaining: &remaining, ResetAt: &later},
+		{Id: "hourly", Scope: models.QuotaScopeApiKey, Period: models.QuotaPeriodHour, Limit: 10, Remaining: &remaining, ResetAt: &soon},
+	}}
+
+	rec := httptest.NewRecorder()
+	e := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/email", nil), rec)
+
+	if err := quotaErrorResponse(e, http.StatusTooManyRequests, exceeded); err != nil {
+		t.Fatalf("quotaErrorResponse() error = %v", err)
+	}
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d", rec.Code)
+	}
+
+	// the earliest reset, rounded up
+	retryAfter, err := strconv.Atoi(rec.Header().Get(echo.HeaderRetryAfter))
+	if err != nil || retryAfter < 89 || retryAfter > 90 {
+		t.Errorf("retry after = %q", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+
+	var body struct {
+		Error  string         `json:"error"`
+		Quotas []models.Quota `json:"quotas"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not json: %v", err)
+	}
+
+	if body.Error != exceeded.Error() || len(body.Quotas) != 2 {
+		t.Fatalf("body = %+v", body)
+	}
+
+	for _, item := range body.Quotas {
+		if item.Remaining == nil || *item.Remaining != 0 || item.ResetAt == nil {
+			t.Errorf("quota = %+v", item)
+		}
+	}
+}
+
+func TestQuotaErrorResponseOtherErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	e := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/email", nil), rec)
+
+	if err := quotaErrorResponse(e, http.StatusConflict, errors.New("batch is not scheduled")); err != nil {
+		t.Fatalf("quotaErrorResponse() error = %v", err)
+	}
+
+	if rec.Code != http.StatusConflict || rec.Header().Get(echo.HeaderRetryAfter) != "" {
+		t.Errorf("response = %d, retry after %q", rec.Code, rec.Header().Get(echo.HeaderRetryAfter))
+	}
+
+	if body := rec.Body.String(); body != "{\"error\":\"batch is not scheduled\"}\n" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,123 @@
+package email
+
+import (
+	"context"
+	"sync"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+// events buffered per client, a client that falls further behind misses events
+// until the next progress event brings its totals back in line
+const hubBufferSize = 256
+
+// hub fans the events of the batches sent by this process out to the clients following them
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.BatchStreamEvent]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: map[string]map[chan models.BatchStreamEvent]struct{}{},
+	}
+}
+
+// subscribe follows a batch, the channel is closed by unsubscribe
+func (h *hub) subscribe(batchId string) (events chan models.BatchStreamEvent, unsubscribe func()) {
+	events = make(chan models.BatchStreamEvent, hubBufferSize)
+
+	h.mu.Lock()
+	if h.subscribers[batchId] == nil {
+		h.subscribers[batchId] = map[chan models.BatchStreamEvent]struct{}{}
+	}
+	h.subscribers[batchId][events] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subscribers[batchId], events)
+			if len(h.subscribers[batchId]) == 0 {
+				delete(h.subscribers, batchId)
+			}
+			close(events)
+		})
+	}
+}
+
+// publish never blocks the worker
+func (h *hub) publish(event models.BatchStreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for events := range h.subscribers[event.BatchId] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// batchEvent carries the counts of the batch, they are up to date on progress and completion
+func batchEvent(eventType string, batch models.EmailBatch) models.BatchStreamEvent {
+	return models.BatchStreamEvent{
+		Type:    eventType,
+		BatchId: batch.Id,
+		Status:  batch.Status,
+		Totals: models.BatchTotals{
+			Total:      batch.EmailCount,
+			Sent:       batch.SuccessCount,
+			Failed:     batch.FailCount,
+			Pending:    batch.PendingCount,
+			Suppressed: batch.SuppressedCount,
+			Canceled:   batch.CanceledCount,
+		},
+	}
+}
+
+// BatchStarted implements BatchObserver.
+func (h *hub) BatchStarted(ctx context.Context, batch models.EmailBatch) error {
+	event := batchEvent(models.BatchEventStarted, batch)
+	event.Status = models.BatchStatusSending
+
+	h.publish(event)
+	return nil
+}
+
+// BatchProgress implements BatchObserver.
+func (h *hub) BatchProgress(ctx context.Context, batch models.EmailBatch) error {
+	h.publish(batchEvent(models.BatchEventProgress, batch))
+	return nil
+}
+
+// BatchCompleted implements BatchObserver.
+func (h *hub) BatchCompleted(ctx context.Context, batch models.EmailBatch) error {
+	h.publish(batchEvent(models.BatchEventCompleted, batch))
+	return nil
+}
+
+// EmailSent implements BatchObserver.
+func (h *hub) EmailSent(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	h.publish(models.BatchStreamEvent{
+		Type:    models.BatchEventSent,
+		BatchId: batch.Id,
+		Status:  models.BatchStatusSending,
+		Email:   &email,
+	})
+	return nil
+}
+
+// EmailFailed implements BatchObserver.
+func (h *hub) EmailFailed(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	h.publish(models.BatchStreamEvent{
+		Type:    models.BatchEventFailed,
+		BatchId: batch.Id,
+		Status:  models.BatchStatusSending,
+		Email:   &email,
+	})
+	return nil
+}
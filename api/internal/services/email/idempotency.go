@@ -0,0 +1,122 @@
+package email
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// errIdempotencyKeyTaken is a request losing the race for its key to a concurrent one
+var errIdempotencyKeyTaken = errors.New("idempotency key is taken")
+
+// acceptOnce accepts a batch at most once per idempotency key, an empty key always accepts it
+// a request reusing the key of an accepted one gets that batch back when it hashes the same, it is refused otherwise
+func (u Usecase) acceptOnce(ctx context.Context, key string, hash string, accept func(key *models.IdempotencyKey) (models.EmailBatch, int, error)) (models.PostEmailResponse, int, error) {
+	if key == "" {
+		batch, statusCode, err := accept(nil)
+		if err != nil {
+			return models.PostEmailResponse{}, statusCode, err
+		}
+
+		return models.PostEmailResponse{BatchId: batch.Id, Status: batch.Status}, statusCode, nil
+	}
+
+	window := u.cfg.IdempotencyWindow
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+
+	now := time.Now()
+	idempotencyKey := models.IdempotencyKey{
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+		Key:         key,
+		RequestHash: hash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(window),
+	}
+
+	response, statusCode, err := u.replay(ctx, idempotencyKey)
+	if err != nil || response.BatchId != "" {
+		return response, statusCode, err
+	}
+
+	batch, statusCode, err := accept(&idempotencyKey)
+	if errors.Is(err, errIdempotencyKeyTaken) {
+		// the concurrent request has committed its batch by now
+		response, statusCode, err = u.replay(ctx, idempotencyKey)
+		if err == nil && response.BatchId == "" {
+			return models.PostEmailResponse{}, http.StatusConflict, fmt.Errorf("idempotency key: %s is in use, retry the request", key)
+		}
+
+		return response, statusCode, err
+	}
+	if err != nil {
+		return models.PostEmailResponse{}, statusCode, err
+	}
+
+	return models.PostEmailResponse{BatchId: batch.Id, Status: batch.Status}, statusCode, nil
+}
+
+// replay returns the batch an earlier request with the key created, an empty response when the key is new or expired
+func (u Usecase) replay(ctx context.Context, key models.IdempotencyKey) (models.PostEmailResponse, int, error) {
+	stored, err := u.repository.ReadIdempotencyKey(ctx, u.db, key.WorkspaceId, key.Key, key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PostEmailResponse{}, http.StatusOK, nil
+	}
+	if err != nil {
+		return models.PostEmailResponse{}, http.StatusInternalServerError, err
+	}
+
+	if stored.RequestHash != key.RequestHash {
+		return models.PostEmailResponse{}, http.StatusUnprocessableEntity, fmt.Errorf("idempotency key: %s was used with a different request", key.Key)
+	}
+
+	batch, statusCode, err := u.readBatch(ctx, stored.BatchId)
+	if err != nil {
+		return models.PostEmailResponse{}, statusCode, err
+	}
+
+	return models.PostEmailResponse{
+		BatchId:  batch.Id,
+		Status:   batch.Status,
+		Replayed: true,
+	}, http.StatusAccepted, nil
+}
+
+// PruneIdempotencyKeys implements EmailUsecase.
+func (u Usecase) PruneIdempotencyKeys(ctx context.Context) (pruned int64, err error) {
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		pruned, err = u.repository.DeleteExpiredIdempotencyKeys(ctx, tx, time.Now())
+		return err
+	})
+
+	return pruned, err
+}
+
+// requestHash is the sha256 of a request as it was bound, file holds an uploaded file when there is one
+// hashing the bound request rather than the body ignores formatting and multipart boundaries
+func requestHash(param any, file []byte) (string, error) {
+	data, err := json.Marshal(param)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write(file)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
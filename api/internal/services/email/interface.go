@@ -3,6 +3,7 @@ package email
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/abbyfakhri/toa-api/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -14,25 +15,151 @@ import (
 // it store the email to database
 // it iterates to send all the email
 // for every 10 minutes or after it's all done it sends notif report to user
+// batches, templates and senders belong to the workspace of the api key, every lookup by an id from a request is scoped to it
 
 type EmailHandler interface {
 	PostEmail(e echo.Context) error
 	PostEmailWithCsv(e echo.Context) error
+	PostDkimVerify(e echo.Context) error
+	GetBatch(e echo.Context) error
+	GetBatchEvents(e echo.Context) error
+	PostBatchEventsToken(e echo.Context) error
+	GetBatchExport(e echo.Context) error
+	GetScheduledBatches(e echo.Context) error
+	PutBatchSchedule(e echo.Context) error
+	PostBatchPause(e echo.Context) error
+	PostBatchResume(e echo.Context) error
+	PostBatchCancel(e echo.Context) error
+	PostBatchRetryFailed(e echo.Context) error
+	PostBatchClone(e echo.Context) error
+	GetPendingApprovalBatches(e echo.Context) error
+	GetBatchApproval(e echo.Context) error
+	PostBatchApprove(e echo.Context) error
+	PostBatchReject(e echo.Context) error
+	GetTemplates(e echo.Context) error
+	GetTemplate(e echo.Context) error
+	PostTemplate(e echo.Context) error
+	PutTemplate(e echo.Context) error
+	DeleteTemplate(e echo.Context) error
+	GetSenders(e echo.Context) error
+	GetSender(e echo.Context) error
+	PostSender(e echo.Context) error
+	PutSender(e echo.Context) error
+	DeleteSender(e echo.Context) error
+	GetRelays(e echo.Context) error
+	GetRelay(e echo.Context) error
+	PostRelay(e echo.Context) error
+	PutRelay(e echo.Context) error
+	DeleteRelay(e echo.Context) error
 }
 
 type EmailUsecase interface {
-	SendEmails(ctx context.Context, param models.PostEmailRequest) (batchId string, statusCode int, err error)
-	SendEmailsWithCsv(ctx context.Context, param models.PostEmailRequestCsv, r io.Reader) (batchId string, statusCode int, err error)
+	SendEmails(ctx context.Context, param models.PostEmailRequest) (models.PostEmailResponse, int, error)
+	SendEmailsWithCsv(ctx context.Context, param models.PostEmailRequestCsv, r io.Reader) (models.PostEmailResponse, int, error)
+	VerifyDkim(ctx context.Context, param models.PostDkimVerifyRequest) (models.DkimVerifyResponse, int, error)
+	GetBatch(ctx context.Context, batchId string) (models.GetBatchResponse, int, error)
+	// StreamBatch returns the current state of a batch and, unless it has finished, its events until ctx is done
+	StreamBatch(ctx context.Context, batchId string) (state models.BatchStreamEvent, events <-chan models.BatchStreamEvent, statusCode int, err error)
+	// IssueStreamToken returns a short lived token opening the event stream of a batch as the api key of the request
+	IssueStreamToken(ctx context.Context, batchId string) (models.BatchStreamToken, int, error)
+	// ExportBatch checks the batch and returns the function writing its export
+	ExportBatch(ctx context.Context, batchId string, param models.GetBatchExportRequest) (export func(w io.Writer) error, contentType string, filename string, statusCode int, err error)
+	GetScheduledBatches(ctx context.Context, param models.GetBatchRequest) ([]models.EmailBatch, int, error)
+	RescheduleBatch(ctx context.Context, batchId string, param models.PutBatchScheduleRequest) (models.EmailBatch, int, error)
+	PauseBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error)
+	ResumeBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error)
+	CancelBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error)
+	RetryFailed(ctx context.Context, batchId string, param models.PostBatchRetryRequest) (newBatchId string, statusCode int, err error)
+	CloneBatch(ctx context.Context, batchId string, param models.PostBatchCloneRequest) (newBatchId string, statusCode int, err error)
+	GetPendingApprovalBatches(ctx context.Context, param models.GetBatchRequest) ([]models.EmailBatch, int, error)
+	// GetBatchApproval returns what an approver reviews, with the decisions made on the batch so far
+	GetBatchApproval(ctx context.Context, batchId string) (models.GetBatchApprovalResponse, int, error)
+	ApproveBatch(ctx context.Context, batchId string, param models.PostBatchApprovalRequest) (models.EmailBatch, int, error)
+	RejectBatch(ctx context.Context, batchId string, param models.PostBatchApprovalRequest) (models.EmailBatch, int, error)
+	GetTemplates(ctx context.Context) ([]models.Template, int, error)
+	GetTemplate(ctx context.Context, templateId string) (models.Template, int, error)
+	CreateTemplate(ctx context.Context, param models.PostTemplateRequest) (models.Template, int, error)
+	UpdateTemplate(ctx context.Context, templateId string, param models.PutTemplateRequest) (models.Template, int, error)
+	DeleteTemplate(ctx context.Context, templateId string) (int, error)
+	GetSenders(ctx context.Context) ([]models.Sender, int, error)
+	GetSender(ctx context.Context, senderId string) (models.Sender, int, error)
+	CreateSender(ctx context.Context, param models.PostSenderRequest) (models.Sender, int, error)
+	UpdateSender(ctx context.Context, senderId string, param models.PutSenderRequest) (models.Sender, int, error)
+	DeleteSender(ctx context.Context, senderId string) (int, error)
+	GetRelays(ctx context.Context) ([]models.Relay, int, error)
+	GetRelay(ctx context.Context, relayId string) (models.Relay, int, error)
+	CreateRelay(ctx context.Context, param models.PostRelayRequest) (models.Relay, int, error)
+	UpdateRelay(ctx context.Context, relayId string, param models.PutRelayRequest) (models.Relay, int, error)
+	DeleteRelay(ctx context.Context, relayId string) (int, error)
+
+	// ReleaseScheduledBatches hands the batches whose send time has come to the workers
+	ReleaseScheduledBatches(ctx context.Context) (released int, err error)
+	// ResumeInterruptedBatches restarts the batches that were queued or sending when the server stopped
+	ResumeInterruptedBatches(ctx context.Context) (resumed int, err error)
+	// PruneIdempotencyKeys forgets the idempotency keys whose window has passed
+	PruneIdempotencyKeys(ctx context.Context) (pruned int64, err error)
 }
 
 type EmailRepository interface {
 	CreateBatch(ctx context.Context, tx *sqlx.Tx, param models.EmailBatch) error
-	ReadBatch(ctx context.Context, db *sqlx.DB, batchId string) (models.EmailBatch, error)
+	ReadBatch(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.EmailBatch, error)
+	ReadBatches(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetBatchRequest) ([]models.EmailBatch, error)
+	ReadDueBatches(ctx context.Context, db *sqlx.DB, now time.Time) ([]models.EmailBatch, error)
+	ReadBatchStats(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.BatchStats, error)
+	ReadBatchTotals(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.BatchTotals, error)
+	ReadFailureReasons(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string, limit int) ([]models.FailureReason, error)
 	UpdateBatch(ctx context.Context, tx *sqlx.Tx, param models.EmailBatch) error
-	DeleteBatch(ctx context.Context, tx *sqlx.Tx, batchId string) error
+	// StartBatch stamps the start time of a batch that has none yet
+	StartBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, startAt time.Time) error
+	UpdateBatchStatus(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, from []string, to string) error
+	UpdateBatchCounts(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error
+	FinishBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, finishAt time.Time) error
+	// DeferBatch moves a sending batch to deferred until sendAt, when the rest of its emails are held back by a quota
+	DeferBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, sendAt time.Time) error
+	IncrementBatchBounceCount(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error
+	DeleteBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error
+
+	CreateBatchApproval(ctx context.Context, tx *sqlx.Tx, param models.BatchApproval) error
+	ReadBatchApprovals(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) ([]models.BatchApproval, error)
+
+	// CreateIdempotencyKey takes over an expired key, sql.ErrNoRows tells the caller the key is still held
+	CreateIdempotencyKey(ctx context.Context, tx *sqlx.Tx, param models.IdempotencyKey) error
+	// ReadIdempotencyKey reads a key that has not expired at now
+	ReadIdempotencyKey(ctx context.Context, db *sqlx.DB, workspaceId string, key string, now time.Time) (models.IdempotencyKey, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context, tx *sqlx.Tx, now time.Time) (int64, error)
 
-	CreateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) error
-	ReadEmail(ctx context.Context, db *sqlx.DB, param models.GetEmailRequest) ([]models.Email, error)
+	CreateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) (int, error)
+	ReadEmail(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetEmailRequest) ([]models.Email, error)
+	// ReadNextQuotaAt returns the earliest window a pending email of the batch is held back until, nil when none is
+	ReadNextQuotaAt(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (*time.Time, error)
+	StreamEmails(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string, status string, fn func(models.Email) error) error
+	ReadEmailById(ctx context.Context, db *sqlx.DB, workspaceId string, emailId int) (models.Email, error)
+	ReadEmailByMessageId(ctx context.Context, db *sqlx.DB, workspaceId string, messageId string) (models.Email, error)
+	// ReadAnyEmailById and ReadAnyEmailByMessageId read every workspace, a bounce only carries the id or Message-ID
+	ReadAnyEmailById(ctx context.Context, db *sqlx.DB, emailId int) (models.Email, error)
+	ReadAnyEmailByMessageId(ctx context.Context, db *sqlx.DB, messageId string) (models.Email, error)
 	UpdateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) error
-	DeleteEmail(ctx context.Context, tx *sqlx.Tx, emailId string) error
+	UpdateEmailsStatus(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, from string, to string) error
+	// ClearEmailsQuotaAt takes the pending emails of a batch out of their quota windows and returns their ids in order
+	ClearEmailsQuotaAt(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) ([]int64, error)
+	UpdateEmailsQuotaAt(ctx context.Context, tx *sqlx.Tx, workspaceId string, emailIds []int64, quotaAt time.Time) error
+	DeleteEmail(ctx context.Context, tx *sqlx.Tx, workspaceId string, emailId string) error
+
+	CreateTemplate(ctx context.Context, tx *sqlx.Tx, param models.Template) error
+	ReadTemplate(ctx context.Context, db *sqlx.DB, workspaceId string, templateId string) (models.Template, error)
+	ReadTemplates(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Template, error)
+	UpdateTemplate(ctx context.Context, tx *sqlx.Tx, param models.Template) error
+	DeleteTemplate(ctx context.Context, tx *sqlx.Tx, workspaceId string, templateId string) error
+
+	CreateSender(ctx context.Context, tx *sqlx.Tx, param models.Sender) error
+	ReadSender(ctx context.Context, db *sqlx.DB, workspaceId string, senderId string) (models.Sender, error)
+	ReadSenders(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Sender, error)
+	UpdateSender(ctx context.Context, tx *sqlx.Tx, param models.Sender) error
+	DeleteSender(ctx context.Context, tx *sqlx.Tx, workspaceId string, senderId string) error
+
+	CreateRelay(ctx context.Context, tx *sqlx.Tx, param models.Relay) error
+	ReadRelay(ctx context.Context, db *sqlx.DB, workspaceId string, relayId string) (models.Relay, error)
+	ReadRelays(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Relay, error)
+	UpdateRelay(ctx context.Context, tx *sqlx.Tx, param models.Relay) error
+	DeleteRelay(ctx context.Context, tx *sqlx.Tx, workspaceId string, relayId string) error
 }
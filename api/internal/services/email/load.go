@@ -1,21 +1,44 @@
 package email
 
 import (
+	"context"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/services/quota"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/abbyfakhri/toa-api/internal/services/workspace"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 )
 
-func Load(e *echo.Echo, db *sqlx.DB, emailClient EmailClient) {
+func Load(e *echo.Echo, db *sqlx.DB, emailClient EmailClient, cfg config.Config, authorize apikey.Authorizer, observers ...BatchObserver) {
 
 	// init repository
 	repository := NewRepository()
+	suppressionRepository := suppression.NewRepository()
+	workspaceRepository := workspace.NewRepository()
+	quotaRepository := quota.NewRepository()
+	recorder := audit.NewRecorder(audit.NewRepository())
 
 	// init usecase
-	usecase := NewUsecase(db, repository, emailClient)
+	clients := NewClientPool(db, workspaceRepository, repository, emailClient, cfg)
+	observers = append(observers, newReporter(db, repository, clients, cfg.ReportInterval))
+	planner := quota.NewPlanner(db, quotaRepository)
+	usecase := NewUsecase(db, repository, suppressionRepository, planner, recorder, clients, cfg, observers...)
 
 	// init handler
 	handler := NewHandler(usecase)
 
 	// init routes
-	NewRoutes(e, handler)
+	NewRoutes(e, handler, authorize)
+
+	// init scheduler
+	interval := cfg.ScheduleInterval
+	if interval <= 0 {
+		interval = defaultScheduleInterval
+	}
+
+	go Schedule(context.Background(), usecase, interval)
 }
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"context"
+	"log"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+// BatchObserver is told about the life cycle of a batch while it is sent
+// calls are made from the worker, an observer must return quickly
+type BatchObserver interface {
+	BatchStarted(ctx context.Context, batch models.EmailBatch) error
+	// periodically while the batch is sending, with up to date counts
+	BatchProgress(ctx context.Context, batch models.EmailBatch) error
+	// once the batch is completed or canceled
+	BatchCompleted(ctx context.Context, batch models.EmailBatch) error
+	EmailSent(ctx context.Context, batch models.EmailBatch, email models.Email) error
+	EmailFailed(ctx context.Context, batch models.EmailBatch, email models.Email) error
+}
+
+// notify calls every observer, a failing observer never stops the batch
+func (u Usecase) notify(batchId string, call func(observer BatchObserver) error) {
+	for _, observer := range u.observers {
+		if err := call(observer); err != nil {
+			log.Printf("fail to notify observer of batch: %s, err: %s", batchId, err.Error())
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetRelays implements EmailUsecase.
+func (u Usecase) GetRelays(ctx context.Context) ([]models.Relay, int, error) {
+	results, err := u.repository.ReadRelays(ctx, u.db, apikey.WorkspaceFromContext(ctx))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	now := time.Now()
+	for i := range results {
+		results[i].UnhealthyUntil = u.clients.breakers.openUntil(results[i].Id, now)
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetRelay implements EmailUsecase.
+func (u Usecase) GetRelay(ctx context.Context, relayId string) (models.Relay, int, error) {
+	result, err := u.repository.ReadRelay(ctx, u.db, apikey.WorkspaceFromContext(ctx), relayId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Relay{}, http.StatusNotFound, fmt.Errorf("relay: %s not found", relayId)
+	}
+	if err != nil {
+		return models.Relay{}, http.StatusInternalServerError, err
+	}
+
+	result.UnhealthyUntil = u.clients.breakers.openUntil(result.Id, time.Now())
+
+	return result, http.StatusOK, nil
+}
+
+// CreateRelay implements EmailUsecase.
+func (u Usecase) CreateRelay(ctx context.Context, param models.PostRelayRequest) (models.Relay, int, error) {
+	now := time.Now()
+	relay := models.Relay{
+		Id:               uuid.NewV4().String(),
+		WorkspaceId:      apikey.WorkspaceFromContext(ctx),
+		Name:             param.Name,
+		SmtpHost:         param.SmtpHost,
+		SmtpPort:         param.SmtpPort,
+		SmtpUsername:     param.SmtpUsername,
+		SmtpPassword:     param.SmtpPassword,
+		Priority:         param.Priority,
+		Weight:           relayWeight(param.Weight),
+		RecipientDomains: normalizeDomains(param.RecipientDomains),
+		SenderIds:        relaySenders(param.SenderIds),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if statusCode, err := u.checkRelaySenders(ctx, relay.SenderIds); err != nil {
+		return models.Relay{}, statusCode, err
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateRelay(ctx, tx, relay); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceRelay, relay.Id, nil, relay)
+	})
+	if utils.IsUniqueViolation(err) {
+		return models.Relay{}, http.StatusConflict, fmt.Errorf("relay: %s already exists", relay.Name)
+	}
+	if err != nil {
+		return models.Relay{}, http.StatusInternalServerError, err
+	}
+
+	return relay, http.StatusCreated, nil
+}
+
+// UpdateRelay implements EmailUsecase.
+// the relay reconnects with the new settings on its next message
+func (u Usecase) UpdateRelay(ctx context.Context, relayId string, param models.PutRelayRequest) (models.Relay, int, error) {
+	relay, statusCode, err := u.GetRelay(ctx, relayId)
+	if err != nil {
+		return models.Relay{}, statusCode, err
+	}
+
+	before := relay
+	relay.Name = param.Name
+	relay.SmtpHost = param.SmtpHost
+	relay.SmtpPort = param.SmtpPort
+	relay.SmtpUsername = param.SmtpUsername
+	if param.SmtpPassword != "" {
+		relay.SmtpPassword = param.SmtpPassword
+	}
+	relay.Priority = param.Priority
+	relay.Weight = relayWeight(param.Weight)
+	relay.RecipientDomains = normalizeDomains(param.RecipientDomains)
+	relay.SenderIds = relaySenders(param.SenderIds)
+	relay.UpdatedAt = time.Now()
+
+	if statusCode, err := u.checkRelaySenders(ctx, relay.SenderIds); err != nil {
+		return models.Relay{}, statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateRelay(ctx, tx, relay); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceRelay, relay.Id, before, relay)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Relay{}, http.StatusNotFound, fmt.Errorf("relay: %s not found", relayId)
+	}
+	if utils.IsUniqueViolation(err) {
+		return models.Relay{}, http.StatusConflict, fmt.Errorf("relay: %s already exists", relay.Name)
+	}
+	if err != nil {
+		return models.Relay{}, http.StatusInternalServerError, err
+	}
+
+	return relay, http.StatusOK, nil
+}
+
+// DeleteRelay implements EmailUsecase.
+func (u Usecase) DeleteRelay(ctx context.Context, relayId string) (int, error) {
+	before, statusCode, err := u.GetRelay(ctx, relayId)
+	if err != nil {
+		return statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteRelay(ctx, tx, apikey.WorkspaceFromContext(ctx), relayId); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceRelay, relayId, before, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("relay: %s not found", relayId)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	u.clients.disconnect("relay:" + relayId)
+
+	return http.StatusNoContent, nil
+}
+
+// checkRelaySenders rejects routing rules naming a sender outside the caller's workspace
+func (u Usecase) checkRelaySenders(ctx context.Context, senderIds []string) (int, error) {
+	for _, senderId := range senderIds {
+		_, statusCode, err := u.GetSender(ctx, senderId)
+		if err != nil {
+			if statusCode == http.StatusNotFound {
+				statusCode = http.StatusBadRequest
+			}
+			return statusCode, err
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+func relayWeight(weight int) int {
+	if weight == 0 {
+		return 1
+	}
+
+	return weight
+}
+
+func normalizeDomains(domains []string) []string {
+	results := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		results = append(results, strings.ToLower(strings.TrimSpace(domain)))
+	}
+
+	return results
+}
+
+// relaySenders keeps the column not null when no sender is given
+func relaySenders(senderIds []string) []string {
+	if senderIds == nil {
+		return []string{}
+	}
+
+	return senderIds
+}
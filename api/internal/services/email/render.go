@@ -0,0 +1,77 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+)
+
+// TemplateData holds the variables available to the body and template of a batch
+type TemplateData struct {
+	UnsubscribeURL string
+}
+
+// compiledContent is the content of a batch parsed once and rendered per recipient
+type compiledContent struct {
+	base     Email
+	body     *template.Template
+	template *template.Template
+}
+
+// compileContent parses the body and template and executes them against sample data
+// so a broken template is rejected before anything is queued
+func compileContent(content Email) (compiledContent, error) {
+	body, err := template.New("body").Option("missingkey=error").Parse(content.Body)
+	if err != nil {
+		return compiledContent{}, fmt.Errorf("invalid body, err: %s", err.Error())
+	}
+
+	html, err := template.New("template").Option("missingkey=error").Parse(content.Template)
+	if err != nil {
+		return compiledContent{}, fmt.Errorf("invalid template, err: %s", err.Error())
+	}
+
+	compiled := compiledContent{
+		base:     content,
+		body:     body,
+		template: html,
+	}
+
+	if _, err := compiled.render(TemplateData{UnsubscribeURL: "https://example.com/unsubscribe"}); err != nil {
+		return compiledContent{}, err
+	}
+
+	return compiled, nil
+}
+
+// render fills the recipient specific variables into a copy of the content
+func (c compiledContent) render(data TemplateData) (Email, error) {
+	var body, html strings.Builder
+
+	if err := c.body.Execute(&body, data); err != nil {
+		return Email{}, fmt.Errorf("invalid body, err: %s", err.Error())
+	}
+
+	if err := c.template.Execute(&html, data); err != nil {
+		return Email{}, fmt.Errorf("invalid template, err: %s", err.Error())
+	}
+
+	content := c.base
+	content.Body = body.String()
+	content.Template = html.String()
+
+	return content, nil
+}
+
+// injectOpenPixel adds the tracking image right before </body>, or at the end when there is none
+func injectOpenPixel(content string, pixelURL string) string {
+	pixel := `<img src="` + html.EscapeString(pixelURL) + `" width="1" height="1" alt="" style="display:block;width:1px;height:1px;border:0;" />`
+
+	index := strings.LastIndex(strings.ToLower(content), "</body>")
+	if index < 0 {
+		return content + pixel
+	}
+
+	return content[:index] + pixel + content[index:]
+}
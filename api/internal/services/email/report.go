@@ -0,0 +1,280 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultReportInterval = 10 * time.Minute
+	// failure reasons listed in a report
+	reportFailureReasons = 5
+)
+
+// reporter emails the reportTo address of a batch a progress report every interval while it runs and a summary once it finishes
+// reports are built and sent from goroutines of their own, the worker only starts and stops them
+type reporter struct {
+	db         *sqlx.DB
+	repository EmailRepository
+	// reports go out through the client of the batch
+	clients  *ClientPool
+	interval time.Duration
+
+	mu sync.Mutex
+	// stops the progress timer of each running batch
+	running map[string]chan struct{}
+}
+
+func newReporter(db *sqlx.DB, repository EmailRepository, clients *ClientPool, interval time.Duration) *reporter {
+	if interval <= 0 {
+		interval = defaultReportInterval
+	}
+
+	return &reporter{
+		db:         db,
+		repository: repository,
+		clients:    clients,
+		interval:   interval,
+		running:    map[string]chan struct{}{},
+	}
+}
+
+// BatchStarted implements BatchObserver.
+func (r *reporter) BatchStarted(ctx context.Context, batch models.EmailBatch) error {
+	r.watch(ctx, batch)
+	return nil
+}
+
+// BatchProgress implements BatchObserver.
+// a batch resumed after a restart is not started again, its timer starts on the first progress instead
+func (r *reporter) BatchProgress(ctx context.Context, batch models.EmailBatch) error {
+	r.watch(ctx, batch)
+	return nil
+}
+
+// BatchCompleted implements BatchObserver.
+func (r *reporter) BatchCompleted(ctx context.Context, batch models.EmailBatch) error {
+	r.stop(batch.Id)
+
+	if batch.ReportTo == "" {
+		return nil
+	}
+
+	go r.report(context.WithoutCancel(ctx), batch, true)
+	return nil
+}
+
+// EmailSent implements BatchObserver.
+func (r *reporter) EmailSent(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	return nil
+}
+
+// EmailFailed implements BatchObserver.
+func (r *reporter) EmailFailed(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	return nil
+}
+
+// watch starts the progress timer of a batch unless it is running already
+func (r *reporter) watch(ctx context.Context, batch models.EmailBatch) {
+	if batch.ReportTo == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.running[batch.Id]; ok {
+		return
+	}
+
+	stop := make(chan struct{})
+	r.running[batch.Id] = stop
+
+	go r.tick(context.WithoutCancel(ctx), batch, stop)
+}
+
+// stop ends the progress timer of a batch
+func (r *reporter) stop(batchId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stop, ok := r.running[batchId]; ok {
+		close(stop)
+		delete(r.running, batchId)
+	}
+}
+
+// tick sends a progress report every interval while the batch is sending, until it finishes
+// a paused batch or one waiting for a quota window skips its reports
+func (r *reporter) tick(ctx context.Context, batch models.EmailBatch, stop chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := r.repository.ReadBatch(ctx, r.db, batch.WorkspaceId, batch.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.stop(batch.Id)
+			return
+		}
+		if err != nil {
+			log.Printf("fail to read batch: %s, err: %s", batch.Id, err.Error())
+			continue
+		}
+
+		switch current.Status {
+		case models.BatchStatusSending:
+		case models.BatchStatusCompleted, models.BatchStatusCanceled:
+			// the summary is sent by BatchCompleted
+			r.stop(batch.Id)
+			return
+		default:
+			continue
+		}
+
+		// the counts of the batch are only refreshed on the progress ticks of the worker
+		totals, err := r.repository.ReadBatchTotals(ctx, r.db, current.WorkspaceId, current.Id)
+		if err != nil {
+			log.Printf("fail to read batch totals: %s, err: %s", batch.Id, err.Error())
+			continue
+		}
+
+		current.SuccessCount = totals.Sent
+		current.FailCount = totals.Failed
+		current.PendingCount = totals.Pending
+		current.SuppressedCount = totals.Suppressed
+		current.CanceledCount = totals.Canceled
+
+		r.report(ctx, current, false)
+	}
+}
+
+// report sends the report of a batch, a report that cannot be sent is only logged
+func (r *reporter) report(ctx context.Context, batch models.EmailBatch, final bool) {
+	if err := r.send(ctx, batch, final); err != nil {
+		log.Printf("fail to send report of batch: %s, err: %s", batch.Id, err.Error())
+	}
+}
+
+// send builds the report of a batch with its failed recipients attached
+func (r *reporter) send(ctx context.Context, batch models.EmailBatch, final bool) error {
+	reasons, err := r.repository.ReadFailureReasons(ctx, r.db, batch.WorkspaceId, batch.Id, reportFailureReasons)
+	if err != nil {
+		return err
+	}
+
+	content, err := r.failedRecipientsCsv(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	message := Email{
+		To:      batch.ReportTo,
+		Subject: reportSubject(batch, final),
+		Body:    reportBody(batch, reasons, content != nil),
+	}
+
+	if content != nil {
+		message.Attachments = []Attachment{{
+			Filename:    fmt.Sprintf("failed-recipients-%s.csv", batch.Id),
+			ContentType: "text/csv",
+			Content:     content,
+		}}
+	}
+
+	client, err := r.clients.get(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	return client.SendMail(message)
+}
+
+func reportSubject(batch models.EmailBatch, final bool) string {
+	if final {
+		return fmt.Sprintf("Batch %s %s: %d of %d sent", batch.Id, batch.Status, batch.SuccessCount, batch.EmailCount)
+	}
+
+	return fmt.Sprintf("Batch %s progress: %d of %d sent", batch.Id, batch.SuccessCount, batch.EmailCount)
+}
+
+func reportBody(batch models.EmailBatch, reasons []models.FailureReason, attached bool) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Batch: %s\n", batch.Id)
+	fmt.Fprintf(&b, "Subject: %s\n", batch.Subject)
+	fmt.Fprintf(&b, "Status: %s\n\n", batch.Status)
+
+	fmt.Fprintf(&b, "Recipients: %d\n", batch.EmailCount)
+	fmt.Fprintf(&b, "Sent: %d\n", batch.SuccessCount)
+	fmt.Fprintf(&b, "Failed: %d\n", batch.FailCount)
+	fmt.Fprintf(&b, "Pending: %d\n", batch.PendingCount)
+	fmt.Fprintf(&b, "Suppressed: %d\n", batch.SuppressedCount)
+	fmt.Fprintf(&b, "Canceled: %d\n", batch.CanceledCount)
+
+	if batch.StartAt != nil {
+		finishAt := time.Now()
+		if batch.FinishAt != nil {
+			finishAt = *batch.FinishAt
+		}
+
+		fmt.Fprintf(&b, "Duration: %s\n", finishAt.Sub(*batch.StartAt).Round(time.Second))
+	}
+
+	if len(reasons) > 0 {
+		b.WriteString("\nTop failure reasons:\n")
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "%6d  %s\n", reason.Count, reason.Reason)
+		}
+	}
+
+	if attached {
+		b.WriteString("\nThe failed recipients are attached.\n")
+	}
+
+	return b.String()
+}
+
+// failedRecipientsCsv streams the failed recipients of a batch into a csv, it is nil when none failed
+func (r *reporter) failedRecipientsCsv(ctx context.Context, batch models.EmailBatch) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	if err := writer.Write([]string{"email", "error"}); err != nil {
+		return nil, err
+	}
+
+	count := 0
+	err := r.repository.StreamEmails(ctx, r.db, batch.WorkspaceId, batch.Id, models.EmailStatusFailed, func(email models.Email) error {
+		count++
+
+		reason := ""
+		if email.Log != nil {
+			reason = *email.Log
+		}
+
+		return writer.Write([]string{email.Email, reason})
+	})
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
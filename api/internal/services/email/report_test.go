@@ -0,0 +1,100 @@
+package email
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// reportRepository serves one batch and its emails to the reporter
+type reportRepository struct {
+	EmailRepository
+
+	batch  models.EmailBatch
+	emails []models.Email
+}
+
+func (r reportRepository) ReadBatch(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.EmailBatch, error) {
+	return r.batch, nil
+}
+
+func (r reportRepository) StreamEmails(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string, status string, fn func(models.Email) error) error {
+	for _, email := range r.emails {
+		if status != "" && email.Status != status {
+			continue
+		}
+
+		if err := fn(email); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestFailedRecipientsCsv(t *testing.T) {
+	reason := "550 5.1.1 user unknown"
+	batch := models.EmailBatch{Id: "batch", WorkspaceId: "workspace"}
+
+	r := newReporter(nil, reportRepository{emails: []models.Email{
+		{Email: "sent@example.org", Status: models.EmailStatusSent},
+		{Email: "gone@example.org", Status: models.EmailStatusFailed, Log: &reason},
+	}}, nil, 0)
+
+	content, err := r.failedRecipientsCsv(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("failedRecipientsCsv() error = %v", err)
+	}
+
+	want := "email,error\ngone@example.org,550 5.1.1 user unknown\n"
+	if string(content) != want {
+		t.Errorf("failedRecipientsCsv() = %q, want %q", content, want)
+	}
+
+	r = newReporter(nil, reportRepository{emails: []models.Email{{Email: "sent@example.org", Status: models.EmailStatusSent}}}, nil, 0)
+
+	content, err = r.failedRecipientsCsv(context.Background(), batch)
+	if err != nil || content != nil {
+		t.Errorf("failedRecipientsCsv() without failures = %q, %v", content, err)
+	}
+}
+
+func TestReporterWatch(t *testing.T) {
+	batch := models.EmailBatch{Id: "batch", WorkspaceId: "workspace", ReportTo: "ops@example.org", Status: models.BatchStatusSending}
+
+	completed := batch
+	completed.Status = models.BatchStatusCompleted
+
+	r := newReporter(nil, reportRepository{batch: completed}, nil, 5*time.Millisecond)
+
+	r.BatchStarted(context.Background(), models.EmailBatch{Id: "unreported"})
+	r.BatchStarted(context.Background(), batch)
+	r.BatchProgress(context.Background(), batch)
+
+	r.mu.Lock()
+	running := len(r.running)
+	r.mu.Unlock()
+
+	if running != 1 {
+		t.Fatalf("%d timers running, want 1", running)
+	}
+
+	// the timer stops once it reads the batch finished
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		running = len(r.running)
+		r.mu.Unlock()
+
+		if running == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the timer of a finished batch kept running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
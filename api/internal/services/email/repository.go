@@ -2,9 +2,28 @@ package email
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/abbyfakhri/toa-api/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	batchColumns = `id, workspace_id, status, parent_id, api_key_id, requested_by, sender_id, "from", subject, body, template, email_count, success_count, fail_count, suppressed_count, canceled_count, pending_count, bounce_count,
+		track_opens, track_clicks, utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+		webhook_url, webhook_secret, report_to, metadata_columns, send_at, time_zone, created_at, start_at, finish_at`
+	templateColumns = `id, workspace_id, name, subject, body, template, created_at, updated_at`
+	emailColumns    = `id, batch_id, workspace_id, email, status, is_sent, sent_at, log, message_id, bounce_type, bounced_at, open_count, first_opened_at, last_opened_at, click_count, first_clicked_at, metadata, quota_at`
+	senderColumns   = `id, workspace_id, name, email_from, email_alias, reply_to, smtp_host, smtp_port, smtp_username, smtp_password,
+		dkim_selector, dkim_domain, dkim_private_key, created_at, updated_at, transport, api_url, api_key, api_secret, api_region,
+		requires_approval`
+	relayColumns = `id, workspace_id, name, smtp_host, smtp_port, smtp_username, smtp_password, priority, weight, recipient_domains, sender_ids,
+		created_at, updated_at`
+	approvalColumns = `id, batch_id, workspace_id, api_key_id, decision, comment, created_at`
+
+	idempotencyColumns = `workspace_id, idempotency_key, request_hash, batch_id, created_at, expires_at`
 )
 
 type Repository struct {
@@ -12,42 +31,620 @@ type Repository struct {
 
 // CreateBatch implements EmailRepository.
 func (r Repository) CreateBatch(ctx context.Context, tx *sqlx.Tx, param models.EmailBatch) error {
-	panic("unimplemented")
+	query := `
+		INSERT INTO email_batches (` + batchColumns + `)
+		VALUES (:id, :workspace_id, :status, :parent_id, :api_key_id, :requested_by, :sender_id, :from, :subject, :body, :template, :email_count, :success_count, :fail_count, :suppressed_count, :canceled_count, :pending_count, :bounce_count,
+			:track_opens, :track_clicks, :utm_source, :utm_medium, :utm_campaign, :utm_term, :utm_content,
+			:webhook_url, :webhook_secret, :report_to, :metadata_columns, :send_at, :time_zone, :created_at, :start_at, :finish_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
 }
 
 // CreateEmail implements EmailRepository.
-func (r Repository) CreateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) error {
-	panic("unimplemented")
+func (r Repository) CreateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) (int, error) {
+	var id int
+
+	query := `
+		INSERT INTO emails (batch_id, workspace_id, email, status, is_sent, sent_at, log, metadata, quota_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	err := tx.QueryRowxContext(ctx, query,
+		param.BatchId, param.WorkspaceId, param.Email, param.Status, param.IsSent, param.SentAt, param.Log, param.Metadata, param.QuotaAt,
+	).Scan(&id)
+	return id, err
 }
 
 // DeleteBatch implements EmailRepository.
-func (r Repository) DeleteBatch(ctx context.Context, tx *sqlx.Tx, batchId string) error {
-	panic("unimplemented")
+func (r Repository) DeleteBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM email_batches WHERE id = $1 AND workspace_id = $2`, batchId, workspaceId)
+	return err
 }
 
 // DeleteEmail implements EmailRepository.
-func (r Repository) DeleteEmail(ctx context.Context, tx *sqlx.Tx, emailId string) error {
-	panic("unimplemented")
+func (r Repository) DeleteEmail(ctx context.Context, tx *sqlx.Tx, workspaceId string, emailId string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM emails WHERE id = $1 AND workspace_id = $2`, emailId, workspaceId)
+	return err
 }
 
 // ReadBatch implements EmailRepository.
-func (r Repository) ReadBatch(ctx context.Context, db *sqlx.DB, batchId string) (models.EmailBatch, error) {
-	panic("unimplemented")
+func (r Repository) ReadBatch(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.EmailBatch, error) {
+	var result models.EmailBatch
+
+	query := `SELECT ` + batchColumns + ` FROM email_batches WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, batchId, workspaceId)
+
+	return result, err
+}
+
+// ReadBatches implements EmailRepository.
+// an empty workspace reads the batches of every workspace, which only the background jobs do
+func (r Repository) ReadBatches(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetBatchRequest) ([]models.EmailBatch, error) {
+	results := []models.EmailBatch{}
+
+	query := `
+		SELECT ` + batchColumns + ` FROM email_batches
+		WHERE ($1 = '' OR workspace_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY COALESCE(send_at, created_at), created_at
+		LIMIT $3 OFFSET $4`
+	err := db.SelectContext(ctx, &results, query, workspaceId, param.Status, param.Limit, param.Offset)
+
+	return results, err
+}
+
+// ReadDueBatches implements EmailRepository.
+// it reads every workspace for the scheduler, deferred batches are due at their send_at too
+func (r Repository) ReadDueBatches(ctx context.Context, db *sqlx.DB, now time.Time) ([]models.EmailBatch, error) {
+	results := []models.EmailBatch{}
+
+	query := `
+		SELECT ` + batchColumns + ` FROM email_batches
+		WHERE status = ANY($1) AND send_at <= $2
+		ORDER BY send_at`
+	err := db.SelectContext(ctx, &results, query, pq.Array([]string{models.BatchStatusScheduled, models.BatchStatusDeferred}), now)
+
+	return results, err
 }
 
 // ReadEmail implements EmailRepository.
-func (r Repository) ReadEmail(ctx context.Context, db *sqlx.DB, param models.GetEmailRequest) ([]models.Email, error) {
-	panic("unimplemented")
+func (r Repository) ReadEmail(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetEmailRequest) ([]models.Email, error) {
+	results := []models.Email{}
+
+	query := `
+		SELECT ` + emailColumns + ` FROM emails
+		WHERE ($1 = '' OR batch_id = $1) AND ($2 = '' OR email = $2) AND ($3 = '' OR status = $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR quota_at IS NULL OR quota_at <= $4) AND workspace_id = $5
+		ORDER BY id`
+	err := db.SelectContext(ctx, &results, query, param.BatchId, param.Email, param.Status, param.DueBy, workspaceId)
+
+	return results, err
 }
 
 // UpdateBatch implements EmailRepository.
+// the counters are left out on purpose, they only move through UpdateBatchCounts and IncrementBatchBounceCount
 func (r Repository) UpdateBatch(ctx context.Context, tx *sqlx.Tx, param models.EmailBatch) error {
-	panic("unimplemented")
+	query := `
+		UPDATE email_batches
+		SET status = :status, email_count = :email_count, suppressed_count = :suppressed_count,
+			send_at = :send_at, time_zone = :time_zone, start_at = :start_at, finish_at = :finish_at
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// StartBatch implements EmailRepository.
+// only the start time is written, a pause or cancel that landed since the batch was claimed keeps its status
+func (r Repository) StartBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, startAt time.Time) error {
+	query := `UPDATE email_batches SET start_at = $3 WHERE id = $1 AND workspace_id = $2 AND start_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, batchId, workspaceId, startAt)
+	return err
+}
+
+// UpdateBatchStatus implements EmailRepository.
+// it only moves a batch that is currently in one of from, sql.ErrNoRows tells the caller it was not
+func (r Repository) UpdateBatchStatus(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, from []string, to string) error {
+	query := `UPDATE email_batches SET status = $3 WHERE id = $1 AND status = ANY($2) AND workspace_id = $4`
+
+	res, err := tx.ExecContext(ctx, query, batchId, pq.Array(from), to, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateBatchCounts implements EmailRepository.
+// counts are derived from the email records so a batch resumed after a restart still adds up
+func (r Repository) UpdateBatchCounts(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error {
+	query := `
+		UPDATE email_batches b
+		SET success_count = c.success_count, fail_count = c.fail_count, suppressed_count = c.suppressed_count,
+			canceled_count = c.canceled_count, pending_count = c.pending_count
+		FROM (
+			SELECT
+				COUNT(*) FILTER (WHERE is_sent) AS success_count,
+				COUNT(*) FILTER (WHERE status = $2) AS fail_count,
+				COUNT(*) FILTER (WHERE status = $3) AS suppressed_count,
+				COUNT(*) FILTER (WHERE status = $4) AS canceled_count,
+				COUNT(*) FILTER (WHERE status = $5) AS pending_count
+			FROM emails WHERE batch_id = $1 AND workspace_id = $6
+		) c
+		WHERE b.id = $1 AND b.workspace_id = $6`
+
+	_, err := tx.ExecContext(ctx, query, batchId,
+		models.EmailStatusFailed, models.EmailStatusSuppressed, models.EmailStatusCanceled, models.EmailStatusPending, workspaceId,
+	)
+	return err
+}
+
+// FinishBatch implements EmailRepository.
+// it only stamps a batch that has reached a final status and was not stamped before
+func (r Repository) FinishBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, finishAt time.Time) error {
+	query := `UPDATE email_batches SET finish_at = $2 WHERE id = $1 AND finish_at IS NULL AND status = ANY($3) AND workspace_id = $4`
+
+	_, err := tx.ExecContext(ctx, query, batchId, finishAt,
+		pq.Array([]string{models.BatchStatusCompleted, models.BatchStatusCanceled}), workspaceId,
+	)
+	return err
+}
+
+// CreateBatchApproval implements EmailRepository.
+func (r Repository) CreateBatchApproval(ctx context.Context, tx *sqlx.Tx, param models.BatchApproval) error {
+	query := `
+		INSERT INTO batch_approvals (` + approvalColumns + `)
+		VALUES (:id, :batch_id, :workspace_id, :api_key_id, :decision, :comment, :created_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadBatchApprovals implements EmailRepository.
+func (r Repository) ReadBatchApprovals(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) ([]models.BatchApproval, error) {
+	results := []models.BatchApproval{}
+
+	query := `SELECT ` + approvalColumns + ` FROM batch_approvals WHERE batch_id = $1 AND workspace_id = $2 ORDER BY created_at`
+	err := db.SelectContext(ctx, &results, query, batchId, workspaceId)
+
+	return results, err
+}
+
+// CreateIdempotencyKey implements EmailRepository.
+func (r Repository) CreateIdempotencyKey(ctx context.Context, tx *sqlx.Tx, param models.IdempotencyKey) error {
+	query := `
+		INSERT INTO idempotency_keys (` + idempotencyColumns + `)
+		VALUES (:workspace_id, :idempotency_key, :request_hash, :batch_id, :created_at, :expires_at)
+		ON CONFLICT (workspace_id, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, batch_id = EXCLUDED.batch_id, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// ReadIdempotencyKey implements EmailRepository.
+func (r Repository) ReadIdempotencyKey(ctx context.Context, db *sqlx.DB, workspaceId string, key string, now time.Time) (models.IdempotencyKey, error) {
+	var result models.IdempotencyKey
+
+	query := `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE workspace_id = $1 AND idempotency_key = $2 AND expires_at > $3`
+	err := db.GetContext(ctx, &result, query, workspaceId, key, now)
+
+	return result, err
+}
+
+// DeleteExpiredIdempotencyKeys implements EmailRepository.
+func (r Repository) DeleteExpiredIdempotencyKeys(ctx context.Context, tx *sqlx.Tx, now time.Time) (int64, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeferBatch implements EmailRepository.
+// it only moves a batch that is still sending, sql.ErrNoRows tells the caller it was not
+func (r Repository) DeferBatch(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, sendAt time.Time) error {
+	query := `UPDATE email_batches SET status = $2, send_at = $3 WHERE id = $1 AND status = $4 AND workspace_id = $5`
+
+	res, err := tx.ExecContext(ctx, query, batchId, models.BatchStatusDeferred, sendAt, models.BatchStatusSending, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// ReadNextQuotaAt implements EmailRepository.
+func (r Repository) ReadNextQuotaAt(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (*time.Time, error) {
+	var result *time.Time
+
+	query := `SELECT MIN(quota_at) FROM emails WHERE batch_id = $1 AND status = $2 AND workspace_id = $3`
+	err := db.GetContext(ctx, &result, query, batchId, models.EmailStatusPending, workspaceId)
+
+	return result, err
 }
 
 // UpdateEmail implements EmailRepository.
 func (r Repository) UpdateEmail(ctx context.Context, tx *sqlx.Tx, param models.Email) error {
-	panic("unimplemented")
+	query := `
+		UPDATE emails
+		SET status = $2, is_sent = $3, sent_at = $4, log = $5, message_id = $6, bounce_type = $7, bounced_at = $8
+		WHERE id = $1 AND workspace_id = $9`
+
+	_, err := tx.ExecContext(ctx, query,
+		param.Id, param.Status, param.IsSent, param.SentAt, param.Log, param.MessageId, param.BounceType, param.BouncedAt, param.WorkspaceId,
+	)
+	return err
+}
+
+// UpdateEmailsStatus implements EmailRepository.
+func (r Repository) UpdateEmailsStatus(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string, from string, to string) error {
+	query := `UPDATE emails SET status = $3 WHERE batch_id = $1 AND status = $2 AND workspace_id = $4`
+
+	_, err := tx.ExecContext(ctx, query, batchId, from, to, workspaceId)
+	return err
+}
+
+// ClearEmailsQuotaAt implements EmailRepository.
+func (r Repository) ClearEmailsQuotaAt(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) ([]int64, error) {
+	results := []int64{}
+
+	query := `
+		WITH cleared AS (
+			UPDATE emails SET quota_at = NULL WHERE batch_id = $1 AND status = $2 AND workspace_id = $3 RETURNING id
+		)
+		SELECT id FROM cleared ORDER BY id`
+	err := tx.SelectContext(ctx, &results, query, batchId, models.EmailStatusPending, workspaceId)
+
+	return results, err
+}
+
+// UpdateEmailsQuotaAt implements EmailRepository.
+func (r Repository) UpdateEmailsQuotaAt(ctx context.Context, tx *sqlx.Tx, workspaceId string, emailIds []int64, quotaAt time.Time) error {
+	query := `UPDATE emails SET quota_at = $2 WHERE id = ANY($1) AND workspace_id = $3`
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(emailIds), quotaAt, workspaceId)
+	return err
+}
+
+// StreamEmails implements EmailRepository.
+// rows are handed to fn one at a time so a large batch is never loaded at once, an empty status streams them all
+func (r Repository) StreamEmails(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string, status string, fn func(models.Email) error) error {
+	query := `SELECT ` + emailColumns + ` FROM emails WHERE batch_id = $1 AND workspace_id = $2 AND ($3 = '' OR status = $3) ORDER BY id`
+
+	rows, err := db.QueryxContext(ctx, query, batchId, workspaceId, status)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email models.Email
+		if err := rows.StructScan(&email); err != nil {
+			return err
+		}
+
+		if err := fn(email); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ReadEmailById implements EmailRepository.
+func (r Repository) ReadEmailById(ctx context.Context, db *sqlx.DB, workspaceId string, emailId int) (models.Email, error) {
+	var result models.Email
+
+	query := `SELECT ` + emailColumns + ` FROM emails WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, emailId, workspaceId)
+
+	return result, err
+}
+
+// ReadEmailByMessageId implements EmailRepository.
+func (r Repository) ReadEmailByMessageId(ctx context.Context, db *sqlx.DB, workspaceId string, messageId string) (models.Email, error) {
+	var result models.Email
+
+	query := `SELECT ` + emailColumns + ` FROM emails WHERE message_id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, messageId, workspaceId)
+
+	return result, err
+}
+
+// ReadAnyEmailById implements EmailRepository.
+// it reads every workspace for the bounce processor
+func (r Repository) ReadAnyEmailById(ctx context.Context, db *sqlx.DB, emailId int) (models.Email, error) {
+	var result models.Email
+
+	query := `SELECT ` + emailColumns + ` FROM emails WHERE id = $1`
+	err := db.GetContext(ctx, &result, query, emailId)
+
+	return result, err
+}
+
+// ReadAnyEmailByMessageId implements EmailRepository.
+// it reads every workspace for the bounce processor
+func (r Repository) ReadAnyEmailByMessageId(ctx context.Context, db *sqlx.DB, messageId string) (models.Email, error) {
+	var result models.Email
+
+	query := `SELECT ` + emailColumns + ` FROM emails WHERE message_id = $1`
+	err := db.GetContext(ctx, &result, query, messageId)
+
+	return result, err
+}
+
+// IncrementBatchBounceCount implements EmailRepository.
+// bounces arrive while the batch may still be sending, so the counter is bumped in place
+func (r Repository) IncrementBatchBounceCount(ctx context.Context, tx *sqlx.Tx, workspaceId string, batchId string) error {
+	query := `UPDATE email_batches SET bounce_count = bounce_count + 1 WHERE id = $1 AND workspace_id = $2`
+
+	_, err := tx.ExecContext(ctx, query, batchId, workspaceId)
+	return err
+}
+
+// ReadBatchTotals implements EmailRepository.
+func (r Repository) ReadBatchTotals(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.BatchTotals, error) {
+	var result models.BatchTotals
+
+	query := `
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE is_sent) AS sent,
+			COUNT(*) FILTER (WHERE status = $2) AS failed,
+			COUNT(*) FILTER (WHERE status = $3) AS pending,
+			COUNT(*) FILTER (WHERE status = $4) AS suppressed,
+			COUNT(*) FILTER (WHERE status = $5) AS canceled
+		FROM emails WHERE batch_id = $1 AND workspace_id = $6`
+	err := db.GetContext(ctx, &result, query, batchId,
+		models.EmailStatusFailed, models.EmailStatusPending, models.EmailStatusSuppressed, models.EmailStatusCanceled, workspaceId,
+	)
+
+	return result, err
+}
+
+// ReadFailureReasons implements EmailRepository.
+func (r Repository) ReadFailureReasons(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string, limit int) ([]models.FailureReason, error) {
+	results := []models.FailureReason{}
+
+	query := `
+		SELECT COALESCE(log, '') AS reason, COUNT(*) AS count
+		FROM emails WHERE batch_id = $1 AND status = $2 AND workspace_id = $4
+		GROUP BY reason
+		ORDER BY count DESC, reason
+		LIMIT $3`
+	err := db.SelectContext(ctx, &results, query, batchId, models.EmailStatusFailed, limit, workspaceId)
+
+	return results, err
+}
+
+// ReadBatchStats implements EmailRepository.
+func (r Repository) ReadBatchStats(ctx context.Context, db *sqlx.DB, workspaceId string, batchId string) (models.BatchStats, error) {
+	var result models.BatchStats
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE open_count > 0) AS unique_opens, COALESCE(SUM(open_count), 0) AS total_opens,
+			COUNT(*) FILTER (WHERE click_count > 0) AS unique_clicks, COALESCE(SUM(click_count), 0) AS total_clicks
+		FROM emails WHERE batch_id = $1 AND workspace_id = $2`
+	if err := db.GetContext(ctx, &result, query, batchId, workspaceId); err != nil {
+		return result, err
+	}
+
+	result.Links = []models.LinkStats{}
+
+	// events carry no workspace, they belong to the one of their batch
+	query = `
+		SELECT e.url, COUNT(DISTINCT e.email_id) AS unique_clicks, COUNT(*) AS total_clicks
+		FROM email_events e JOIN email_batches b ON b.id = e.batch_id
+		WHERE e.batch_id = $1 AND e.type = $2 AND b.workspace_id = $3
+		GROUP BY e.url
+		ORDER BY total_clicks DESC`
+	err := db.SelectContext(ctx, &result.Links, query, batchId, models.EmailEventClick, workspaceId)
+
+	return result, err
+}
+
+// CreateTemplate implements EmailRepository.
+func (r Repository) CreateTemplate(ctx context.Context, tx *sqlx.Tx, param models.Template) error {
+	query := `
+		INSERT INTO templates (` + templateColumns + `)
+		VALUES (:id, :workspace_id, :name, :subject, :body, :template, :created_at, :updated_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadTemplate implements EmailRepository.
+func (r Repository) ReadTemplate(ctx context.Context, db *sqlx.DB, workspaceId string, templateId string) (models.Template, error) {
+	var result models.Template
+
+	query := `SELECT ` + templateColumns + ` FROM templates WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, templateId, workspaceId)
+
+	return result, err
+}
+
+// ReadTemplates implements EmailRepository.
+func (r Repository) ReadTemplates(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Template, error) {
+	results := []models.Template{}
+
+	query := `SELECT ` + templateColumns + ` FROM templates WHERE workspace_id = $1 ORDER BY name`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// UpdateTemplate implements EmailRepository.
+func (r Repository) UpdateTemplate(ctx context.Context, tx *sqlx.Tx, param models.Template) error {
+	query := `
+		UPDATE templates
+		SET name = :name, subject = :subject, body = :body, template = :template, updated_at = :updated_at
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteTemplate implements EmailRepository.
+func (r Repository) DeleteTemplate(ctx context.Context, tx *sqlx.Tx, workspaceId string, templateId string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM templates WHERE id = $1 AND workspace_id = $2`, templateId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// CreateSender implements EmailRepository.
+func (r Repository) CreateSender(ctx context.Context, tx *sqlx.Tx, param models.Sender) error {
+	query := `
+		INSERT INTO senders (` + senderColumns + `)
+		VALUES (:id, :workspace_id, :name, :email_from, :email_alias, :reply_to, :smtp_host, :smtp_port, :smtp_username, :smtp_password,
+			:dkim_selector, :dkim_domain, :dkim_private_key, :created_at, :updated_at, :transport, :api_url, :api_key, :api_secret, :api_region,
+			:requires_approval)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadSender implements EmailRepository.
+func (r Repository) ReadSender(ctx context.Context, db *sqlx.DB, workspaceId string, senderId string) (models.Sender, error) {
+	var result models.Sender
+
+	query := `SELECT ` + senderColumns + ` FROM senders WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, senderId, workspaceId)
+
+	return result, err
+}
+
+// ReadSenders implements EmailRepository.
+func (r Repository) ReadSenders(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Sender, error) {
+	results := []models.Sender{}
+
+	query := `SELECT ` + senderColumns + ` FROM senders WHERE workspace_id = $1 ORDER BY name`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// UpdateSender implements EmailRepository.
+func (r Repository) UpdateSender(ctx context.Context, tx *sqlx.Tx, param models.Sender) error {
+	query := `
+		UPDATE senders
+		SET name = :name, email_from = :email_from, email_alias = :email_alias, reply_to = :reply_to,
+			smtp_host = :smtp_host, smtp_port = :smtp_port, smtp_username = :smtp_username, smtp_password = :smtp_password,
+			dkim_selector = :dkim_selector, dkim_domain = :dkim_domain, dkim_private_key = :dkim_private_key, updated_at = :updated_at,
+			transport = :transport, api_url = :api_url, api_key = :api_key, api_secret = :api_secret, api_region = :api_region,
+			requires_approval = :requires_approval
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteSender implements EmailRepository.
+func (r Repository) DeleteSender(ctx context.Context, tx *sqlx.Tx, workspaceId string, senderId string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM senders WHERE id = $1 AND workspace_id = $2`, senderId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// CreateRelay implements EmailRepository.
+func (r Repository) CreateRelay(ctx context.Context, tx *sqlx.Tx, param models.Relay) error {
+	query := `
+		INSERT INTO relays (` + relayColumns + `)
+		VALUES (:id, :workspace_id, :name, :smtp_host, :smtp_port, :smtp_username, :smtp_password, :priority, :weight, :recipient_domains, :sender_ids,
+			:created_at, :updated_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadRelay implements EmailRepository.
+func (r Repository) ReadRelay(ctx context.Context, db *sqlx.DB, workspaceId string, relayId string) (models.Relay, error) {
+	var result models.Relay
+
+	query := `SELECT ` + relayColumns + ` FROM relays WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, relayId, workspaceId)
+
+	return result, err
+}
+
+// ReadRelays implements EmailRepository.
+func (r Repository) ReadRelays(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Relay, error) {
+	results := []models.Relay{}
+
+	query := `SELECT ` + relayColumns + ` FROM relays WHERE workspace_id = $1 ORDER BY priority, name`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// UpdateRelay implements EmailRepository.
+func (r Repository) UpdateRelay(ctx context.Context, tx *sqlx.Tx, param models.Relay) error {
+	query := `
+		UPDATE relays
+		SET name = :name, smtp_host = :smtp_host, smtp_port = :smtp_port, smtp_username = :smtp_username, smtp_password = :smtp_password,
+			priority = :priority, weight = :weight, recipient_domains = :recipient_domains, sender_ids = :sender_ids, updated_at = :updated_at
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteRelay implements EmailRepository.
+func (r Repository) DeleteRelay(ctx context.Context, tx *sqlx.Tx, workspaceId string, relayId string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM relays WHERE id = $1 AND workspace_id = $2`, relayId, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func requireAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
 func NewRepository() EmailRepository {
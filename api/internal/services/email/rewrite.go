@@ -0,0 +1,141 @@
+package email
+
+import (
+	"html"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// notrackAttribute opts a link out of click tracking, e.g. <a href="..." data-notrack>
+const notrackAttribute = "data-notrack"
+
+// linkRewriter returns the new href for a link, or false to leave the tag untouched
+type linkRewriter func(href string, attributes []xhtml.Attribute) (string, bool)
+
+// rewriteLinks passes the href of every <a> tag in content through rewrite
+// everything else is copied byte for byte, so conditional comments and odd markup survive
+func rewriteLinks(content string, rewrite linkRewriter) (string, error) {
+	var result strings.Builder
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(content))
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == xhtml.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				return result.String(), nil
+			}
+			return "", tokenizer.Err()
+		}
+
+		raw := tokenizer.Raw()
+
+		if tokenType != xhtml.StartTagToken && tokenType != xhtml.SelfClosingTagToken {
+			result.Write(raw)
+			continue
+		}
+
+		token := tokenizer.Token()
+		if token.Data != "a" {
+			result.Write(raw)
+			continue
+		}
+
+		hrefIndex := -1
+		for index, attribute := range token.Attr {
+			if attribute.Key == "href" {
+				hrefIndex = index
+			}
+		}
+
+		if hrefIndex < 0 {
+			result.Write(raw)
+			continue
+		}
+
+		href, ok := rewrite(token.Attr[hrefIndex].Val, token.Attr)
+		if !ok {
+			result.Write(raw)
+			continue
+		}
+
+		token.Attr[hrefIndex].Val = href
+		result.WriteString(renderTag(token))
+	}
+}
+
+// renderTag writes a start tag back out, quoting every attribute
+func renderTag(token xhtml.Token) string {
+	var tag strings.Builder
+
+	tag.WriteString("<" + token.Data)
+	for _, attribute := range token.Attr {
+		tag.WriteString(" " + attribute.Key)
+		if attribute.Val != "" || attribute.Key == "href" {
+			tag.WriteString(`="` + html.EscapeString(attribute.Val) + `"`)
+		}
+	}
+
+	if token.Type == xhtml.SelfClosingTagToken {
+		tag.WriteString(" /")
+	}
+	tag.WriteString(">")
+
+	return tag.String()
+}
+
+// isWebLink reports whether href points to a http(s) page
+// mailto:, tel:, in page anchors and unrendered template variables are left alone
+func isWebLink(href string) bool {
+	href = strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")
+}
+
+func hasAttribute(attributes []xhtml.Attribute, key string) bool {
+	for _, attribute := range attributes {
+		if attribute.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendQuery adds params to href, parameters the link already has keep their value
+// the existing query is kept as is so signed or order sensitive urls still work
+func appendQuery(href string, params map[string]string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	existing := parsed.Query()
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !existing.Has(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return "", false
+	}
+
+	sort.Strings(keys)
+
+	added := make([]string, 0, len(keys))
+	for _, key := range keys {
+		added = append(added, url.QueryEscape(key)+"="+url.QueryEscape(params[key]))
+	}
+
+	if parsed.RawQuery != "" {
+		parsed.RawQuery += "&"
+	}
+	parsed.RawQuery += strings.Join(added, "&")
+
+	return parsed.String(), true
+}
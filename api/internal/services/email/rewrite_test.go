@@ -0,0 +1,56 @@
+package email
+
+import "testing"
+
+func TestAppendQuery(t *testing.T) {
+	params := map[string]string{"utm_source": "toa", "utm_campaign": "spring sale"}
+
+	tests := []struct {
+		name  string
+		href  string
+		want  string
+		added bool
+	}{
+		{
+			name:  "no query",
+			href:  "https://example.org/shop",
+			want:  "https://example.org/shop?utm_campaign=spring+sale&utm_source=toa",
+			added: true,
+		},
+		{
+			name:  "the existing query is kept as is",
+			href:  "https://example.org/shop?b=2&a=1&sig=x%2Fy",
+			want:  "https://example.org/shop?b=2&a=1&sig=x%2Fy&utm_campaign=spring+sale&utm_source=toa",
+			added: true,
+		},
+		{
+			name:  "a parameter the link has keeps its value",
+			href:  "https://example.org/?utm_source=newsletter",
+			want:  "https://example.org/?utm_source=newsletter&utm_campaign=spring+sale",
+			added: true,
+		},
+		{
+			name:  "the fragment stays last",
+			href:  " https://example.org/page#top ",
+			want:  "https://example.org/page?utm_campaign=spring+sale&utm_source=toa#top",
+			added: true,
+		},
+		{
+			name: "nothing to add",
+			href: "https://example.org/?utm_source=a&utm_campaign=b",
+		},
+		{
+			name: "unparsable link",
+			href: "https://exa mple.org/%zz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, added := appendQuery(test.href, params)
+			if got != test.want || added != test.added {
+				t.Errorf("appendQuery(%q) = %q, %v, want %q, %v", test.href, got, added, test.want, test.added)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+)
+
+const (
+	defaultRelayFailureThreshold = 3
+	defaultRelayCooldown         = time.Minute
+)
+
+// router sends each message through the relays of a workspace
+// the routing rules pick the relays a message may use, they are tried by priority and by weight within a priority,
+// and a message moves on to the next relay when one cannot take it right now
+type router struct {
+	pool     *ClientPool
+	relays   []models.Relay
+	identity composer
+	senderId string
+	// carries the mail no relay is open to, connected on first use
+	home func() (Sender, error)
+}
+
+// SendMail implements Sender.
+func (r *router) SendMail(param Email) error {
+	_, domain := utils.SplitAddress(param.To)
+
+	candidates := routeRelays(r.relays, domain, r.senderId)
+	if len(candidates) == 0 {
+		client, err := r.home()
+		if err != nil {
+			return err
+		}
+
+		return client.SendMail(param)
+	}
+
+	var lastErr error
+	for _, relay := range orderRelays(candidates) {
+		if !r.pool.breakers.allow(relay.Id, time.Now()) {
+			continue
+		}
+
+		client, err := r.pool.relay(relay, r.identity)
+		if err == nil {
+			err = client.SendMail(param)
+		}
+
+		if err == nil || !failover(err) {
+			// the relay answered, a rejected message is not its fault
+			// and a message that cannot be built or signed fails on every relay alike
+			if err == nil || answered(err) {
+				r.pool.breakers.success(relay.Id)
+			}
+			return err
+		}
+
+		log.Printf("relay: %s failed, trying the next one, err: %s", relay.Name, err.Error())
+
+		r.pool.breakers.failure(relay.Id, time.Now())
+		r.pool.disconnect("relay:" + relay.Id)
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no healthy relay to send to: %s", param.To)
+	}
+
+	return lastErr
+}
+
+// routeRelays returns the relays a message may use
+// relays whose rules match take the message, otherwise it goes to the relays without rules
+func routeRelays(relays []models.Relay, domain string, senderId string) []models.Relay {
+	matched := []models.Relay{}
+	open := []models.Relay{}
+
+	for _, relay := range relays {
+		if !relay.HasRules() {
+			open = append(open, relay)
+			continue
+		}
+
+		if relay.Matches(domain, senderId) {
+			matched = append(matched, relay)
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched
+	}
+
+	return open
+}
+
+// orderRelays returns the relays in the order they are tried, by priority and then at random by weight
+func orderRelays(relays []models.Relay) []models.Relay {
+	remaining := append([]models.Relay{}, relays...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].Priority < remaining[j].Priority
+	})
+
+	ordered := make([]models.Relay, 0, len(remaining))
+	for len(remaining) > 0 {
+		// the relays sharing the lowest priority left
+		end := 1
+		for end < len(remaining) && remaining[end].Priority == remaining[0].Priority {
+			end++
+		}
+
+		total := 0
+		for _, relay := range remaining[:end] {
+			total += max(relay.Weight, 0)
+		}
+
+		picked := 0
+		if total > 0 {
+			n := rand.Intn(total)
+			for i, relay := range remaining[:end] {
+				n -= max(relay.Weight, 0)
+				if n < 0 {
+					picked = i
+					break
+				}
+			}
+		}
+
+		ordered = append(ordered, remaining[picked])
+		remaining = append(remaining[:picked], remaining[picked+1:]...)
+	}
+
+	return ordered
+}
+
+// failover reports whether a send error is worth trying on another relay
+// network, dial and tls failures, temporary replies, rejected logins and quota rejections are,
+// a rejected message is not and neither is an error building the message
+func failover(err error) bool {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return unreachable(err)
+	}
+
+	if reply.Code >= 400 && reply.Code < 500 {
+		return true
+	}
+
+	switch reply.Code {
+	case 530, 534, 535:
+		return true
+	}
+
+	// 5.4.5 is the enhanced status of an exceeded sending quota
+	return strings.HasPrefix(reply.Msg, "5.4.5")
+}
+
+// answered reports whether the error is a reply of the relay
+func answered(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply)
+}
+
+// unreachable reports whether the error is a failure to reach the relay or to keep talking to it
+func unreachable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// the relay hung up in the middle of a reply
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &authorityErr) || errors.As(err, &invalidErr)
+}
+
+// breakers keeps a circuit breaker per relay, shared by every batch of this process
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	state map[string]*breaker
+}
+
+type breaker struct {
+	// consecutive failures
+	failures  int
+	openUntil time.Time
+}
+
+func newBreakers(cfg config.RelayConfig) *breakers {
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultRelayFailureThreshold
+	}
+
+	cooldown := cfg.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultRelayCooldown
+	}
+
+	return &breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     map[string]*breaker{},
+	}
+}
+
+// allow reports whether the relay is in rotation, an open breaker lets a message through again once it cools down
+func (b *breakers) allow(relayId string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.state[relayId]
+	return !ok || !now.Before(state.openUntil)
+}
+
+func (b *breakers) success(relayId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.state, relayId)
+}
+
+// failure opens the breaker once the relay fails threshold times in a row, a failure after the cooldown opens it again
+func (b *breakers) failure(relayId string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.state[relayId]
+	if !ok {
+		state = &breaker{}
+		b.state[relayId] = state
+	}
+
+	state.failures++
+	if state.failures >= b.threshold {
+		state.openUntil = now.Add(b.cooldown)
+		log.Printf("relay: %s out of rotation until %s", relayId, state.openUntil.Format(time.RFC3339))
+	}
+}
+
+// openUntil returns when the relay is back in rotation, nil when it is in rotation
+func (b *breakers) openUntil(relayId string, now time.Time) *time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.state[relayId]
+	if !ok || !now.Before(state.openUntil) {
+		return nil
+	}
+
+	openUntil := state.openUntil
+	return &openUntil
+}
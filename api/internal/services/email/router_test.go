@@ -0,0 +1,164 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+func TestOrderRelays(t *testing.T) {
+	relays := []models.Relay{
+		{Id: "backup", Priority: 2, Weight: 1},
+		{Id: "heavy", Priority: 1, Weight: 3},
+		{Id: "light", Priority: 1, Weight: 1},
+		{Id: "off", Priority: 1, Weight: 0},
+	}
+
+	first := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		ordered := orderRelays(relays)
+		if len(ordered) != len(relays) {
+			t.Fatalf("orderRelays() = %+v", ordered)
+		}
+
+		// a lower priority is always tried after every relay of a higher one
+		if ordered[3].Id != "backup" {
+			t.Fatalf("orderRelays() tried %s last, want backup", ordered[3].Id)
+		}
+
+		first[ordered[0].Id]++
+	}
+
+	if first["off"] != 0 {
+		t.Errorf("a relay without weight was tried first %d times", first["off"])
+	}
+
+	// 3 to 1 by weight, with plenty of room for chance
+	if first["heavy"] < 2500 || first["heavy"] > 3500 {
+		t.Errorf("heavy was tried first %d of 4000 times, want about 3000", first["heavy"])
+	}
+
+	if unchanged := relays[0].Id; unchanged != "backup" {
+		t.Errorf("orderRelays() reordered its argument")
+	}
+}
+
+func TestRouteRelays(t *testing.T) {
+	relays := []models.Relay{
+		{Id: "open"},
+		{Id: "gmail", RecipientDomains: []string{"gmail.com"}},
+		{Id: "sender", SenderIds: []string{"sender-1"}},
+	}
+
+	tests := []struct {
+		domain   string
+		senderId string
+		want     []string
+	}{
+		{"gmail.com", "", []string{"gmail"}},
+		{"example.org", "sender-1", []string{"sender"}},
+		{"example.org", "", []string{"open"}},
+	}
+
+	for _, test := range tests {
+		routed := routeRelays(relays, test.domain, test.senderId)
+
+		ids := []string{}
+		for _, relay := range routed {
+			ids = append(ids, relay.Id)
+		}
+
+		if fmt.Sprint(ids) != fmt.Sprint(test.want) {
+			t.Errorf("routeRelays(%s, %s) = %v, want %v", test.domain, test.senderId, ids, test.want)
+		}
+	}
+}
+
+func TestBreakers(t *testing.T) {
+	now := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	b := newBreakers(config.RelayConfig{FailureThreshold: 2, Cooldown: time.Minute})
+
+	steps := []struct {
+		name    string
+		at      time.Duration
+		fail    bool
+		succeed bool
+		allowed bool
+	}{
+		{name: "closed at first", allowed: true},
+		{name: "one failure keeps it closed", fail: true, allowed: true},
+		{name: "a success resets the count", succeed: true, allowed: true},
+		{name: "one failure again", fail: true, allowed: true},
+		{name: "the threshold opens it", fail: true, allowed: false},
+		{name: "still cooling down", at: 59 * time.Second, allowed: false},
+		{name: "let through after the cooldown", at: time.Minute, allowed: true},
+		{name: "a failure after the cooldown opens it again", at: time.Minute, fail: true, allowed: false},
+		{name: "a success closes it", at: time.Minute, succeed: true, allowed: true},
+	}
+
+	for _, step := range steps {
+		at := now.Add(step.at)
+
+		if step.fail {
+			b.failure("relay", at)
+		}
+		if step.succeed {
+			b.success("relay")
+		}
+
+		if allowed := b.allow("relay", at); allowed != step.allowed {
+			t.Fatalf("%s: allow() = %v, want %v", step.name, allowed, step.allowed)
+		}
+
+		if open := b.openUntil("relay", at); (open == nil) != step.allowed {
+			t.Fatalf("%s: openUntil() = %v", step.name, open)
+		}
+	}
+
+	if !b.allow("other", now) {
+		t.Errorf("a breaker opened for another relay")
+	}
+}
+
+func TestNewBreakersDefaults(t *testing.T) {
+	b := newBreakers(config.RelayConfig{})
+	if b.threshold != defaultRelayFailureThreshold || b.cooldown != defaultRelayCooldown {
+		t.Errorf("newBreakers() = %d, %s", b.threshold, b.cooldown)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial failure", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"timeout", fmt.Errorf("wrapped: %w", &net.DNSError{Err: "timeout", IsTimeout: true}), true},
+		{"hung up", io.EOF, true},
+		{"unknown authority", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, true},
+		{"tls alert", tls.AlertError(40), true},
+		{"temporary reply", &textproto.Error{Code: 421, Msg: "4.7.0 try again later"}, true},
+		{"rejected login", &textproto.Error{Code: 535, Msg: "5.7.8 authentication failed"}, true},
+		{"quota exceeded", &textproto.Error{Code: 554, Msg: "5.4.5 daily sending quota exceeded"}, true},
+		{"rejected recipient", &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}, false},
+		{"rejected message", &textproto.Error{Code: 554, Msg: "5.7.1 message refused"}, false},
+		{"signing failure", fmt.Errorf("unable to sign message, err: bad key"), false},
+		{"header injection", errors.New("smtp: A line must not contain CR or LF"), false},
+	}
+
+	for _, test := range tests {
+		if got := failover(test.err); got != test.want {
+			t.Errorf("%s: failover() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -1,10 +1,43 @@
 package email
 
 import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
 	"github.com/labstack/echo/v4"
 )
 
-func NewRoutes(e *echo.Echo, handler EmailHandler) {
-	e.POST("/email", handler.PostEmail)
-	e.POST("/email/csv", handler.PostEmailWithCsv)
+func NewRoutes(e *echo.Echo, handler EmailHandler, authorize apikey.Authorizer) {
+	e.POST("/email", handler.PostEmail, authorize(models.ScopeEmailSend))
+	e.POST("/email/csv", handler.PostEmailWithCsv, authorize(models.ScopeEmailSend))
+	e.POST("/email/dkim/verify", handler.PostDkimVerify, authorize(models.ScopeEmailManage))
+	e.GET("/email/batch/scheduled", handler.GetScheduledBatches, authorize(models.ScopeEmailRead))
+	e.GET("/email/batch/pending-approval", handler.GetPendingApprovalBatches, authorize(models.ScopeEmailRead))
+	e.GET("/email/batch/:id", handler.GetBatch, authorize(models.ScopeEmailRead))
+	e.GET("/email/batch/:id/events", handler.GetBatchEvents, authorize(models.ScopeEmailRead))
+	e.POST("/email/batch/:id/events/token", handler.PostBatchEventsToken, authorize(models.ScopeEmailRead))
+	e.GET("/email/batch/:id/export", handler.GetBatchExport, authorize(models.ScopeEmailRead))
+	e.PUT("/email/batch/:id/schedule", handler.PutBatchSchedule, authorize(models.ScopeEmailManage))
+	e.POST("/email/batch/:id/pause", handler.PostBatchPause, authorize(models.ScopeEmailManage))
+	e.POST("/email/batch/:id/resume", handler.PostBatchResume, authorize(models.ScopeEmailManage))
+	e.POST("/email/batch/:id/cancel", handler.PostBatchCancel, authorize(models.ScopeEmailManage))
+	e.POST("/email/batch/:id/retry-failed", handler.PostBatchRetryFailed, authorize(models.ScopeEmailSend))
+	e.POST("/email/batch/:id/clone", handler.PostBatchClone, authorize(models.ScopeEmailSend))
+	e.GET("/email/batch/:id/approval", handler.GetBatchApproval, authorize(models.ScopeEmailRead))
+	e.POST("/email/batch/:id/approve", handler.PostBatchApprove, authorize(models.ScopeEmailApprove))
+	e.POST("/email/batch/:id/reject", handler.PostBatchReject, authorize(models.ScopeEmailApprove))
+	e.GET("/email/template", handler.GetTemplates, authorize(models.ScopeEmailRead))
+	e.POST("/email/template", handler.PostTemplate, authorize(models.ScopeTemplateManage))
+	e.GET("/email/template/:id", handler.GetTemplate, authorize(models.ScopeEmailRead))
+	e.PUT("/email/template/:id", handler.PutTemplate, authorize(models.ScopeTemplateManage))
+	e.DELETE("/email/template/:id", handler.DeleteTemplate, authorize(models.ScopeTemplateManage))
+	e.GET("/email/sender", handler.GetSenders, authorize(models.ScopeEmailRead))
+	e.POST("/email/sender", handler.PostSender, authorize(models.ScopeAdmin))
+	e.GET("/email/sender/:id", handler.GetSender, authorize(models.ScopeEmailRead))
+	e.PUT("/email/sender/:id", handler.PutSender, authorize(models.ScopeAdmin))
+	e.DELETE("/email/sender/:id", handler.DeleteSender, authorize(models.ScopeAdmin))
+	e.GET("/email/relay", handler.GetRelays, authorize(models.ScopeAdmin))
+	e.POST("/email/relay", handler.PostRelay, authorize(models.ScopeAdmin))
+	e.GET("/email/relay/:id", handler.GetRelay, authorize(models.ScopeAdmin))
+	e.PUT("/email/relay/:id", handler.PutRelay, authorize(models.ScopeAdmin))
+	e.DELETE("/email/relay/:id", handler.DeleteRelay, authorize(models.ScopeAdmin))
 }
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultScheduleInterval = time.Minute
+
+// layouts accepted for a send time without an offset, it is read in the time zone of the request
+var localSendAtLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseSendAt reads the send time of a request, an empty value means send now
+// an explicit offset wins over the time zone, which is still stored so the batch can be shown in it
+func parseSendAt(sendAt string, timeZone string) (*time.Time, string, error) {
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid time zone: %s", timeZone)
+	}
+
+	if sendAt == "" {
+		return nil, timeZone, nil
+	}
+
+	if value, err := time.Parse(time.RFC3339, sendAt); err == nil {
+		value = value.UTC()
+		return &value, timeZone, nil
+	}
+
+	for _, layout := range localSendAtLayouts {
+		if value, err := time.ParseInLocation(layout, sendAt, location); err == nil {
+			value = value.UTC()
+			return &value, timeZone, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("invalid send time: %s, expected RFC 3339 or YYYY-MM-DDTHH:MM", sendAt)
+}
+
+// Schedule picks up batches interrupted by a restart, then releases scheduled batches on every tick until ctx is done
+func Schedule(ctx context.Context, usecase EmailUsecase, interval time.Duration) {
+	resumed, err := usecase.ResumeInterruptedBatches(ctx)
+	if err != nil {
+		log.Printf("fail to resume batches: %s", err.Error())
+	} else if resumed > 0 {
+		log.Printf("resumed %d batches", resumed)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		released, err := usecase.ReleaseScheduledBatches(ctx)
+		if err != nil {
+			log.Printf("fail to release scheduled batches: %s", err.Error())
+		} else if released > 0 {
+			log.Printf("released %d scheduled batches", released)
+		}
+
+		if _, err := usecase.PruneIdempotencyKeys(ctx); err != nil {
+			log.Printf("fail to prune idempotency keys: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSendAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		sendAt   string
+		timeZone string
+		want     *time.Time
+		wantZone string
+		wantErr  bool
+	}{
+		{name: "empty sends now", wantZone: "UTC"},
+		{name: "empty keeps the time zone", timeZone: "Asia/Jakarta", wantZone: "Asia/Jakarta"},
+		{
+			name:     "an offset wins over the time zone",
+			sendAt:   "2024-03-10T09:00:00+02:00",
+			timeZone: "Asia/Jakarta",
+			want:     ptrTime(time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)),
+			wantZone: "Asia/Jakarta",
+		},
+		{
+			name:     "a local time is read in the time zone",
+			sendAt:   "2024-03-10T09:00",
+			timeZone: "Asia/Jakarta",
+			want:     ptrTime(time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)),
+			wantZone: "Asia/Jakarta",
+		},
+		{
+			name:     "a local time with seconds and a space",
+			sendAt:   "2024-03-10 09:00:30",
+			want:     ptrTime(time.Date(2024, 3, 10, 9, 0, 30, 0, time.UTC)),
+			wantZone: "UTC",
+		},
+		{
+			name:     "daylight saving is applied",
+			sendAt:   "2024-07-01T12:00",
+			timeZone: "Europe/Amsterdam",
+			want:     ptrTime(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)),
+			wantZone: "Europe/Amsterdam",
+		},
+		{name: "unknown time zone", sendAt: "2024-03-10T09:00", timeZone: "Mars/Olympus", wantErr: true},
+		{name: "unknown layout", sendAt: "10/03/2024 09:00", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, zone, err := parseSendAt(test.sendAt, test.timeZone)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseSendAt() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if zone != test.wantZone {
+				t.Errorf("parseSendAt() zone = %q, want %q", zone, test.wantZone)
+			}
+
+			if (got == nil) != (test.want == nil) || got != nil && !got.Equal(*test.want) {
+				t.Errorf("parseSendAt() = %v, want %v", got, test.want)
+			}
+
+			if got != nil && got.Location() != time.UTC {
+				t.Errorf("parseSendAt() is in %s, want UTC", got.Location())
+			}
+		})
+	}
+}
+
+func ptrTime(value time.Time) *time.Time {
+	return &value
+}
@@ -0,0 +1,318 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/webhook"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+)
+
+const defaultApiTimeout = 30 * time.Second
+
+// Sender delivers one message, the smtp client is one and the http api transports of the sender profiles are the others
+type Sender interface {
+	SendMail(param Email) error
+}
+
+// newApiSender returns the http api transport of a sender, sending as identity
+func newApiSender(sender models.Sender, identity composer, client *http.Client) (Sender, error) {
+	switch sender.Transport {
+	case models.SenderTransportWebhook:
+		return webhookSender{
+			composer: identity,
+			client:   client,
+			url:      sender.ApiUrl,
+			secret:   sender.ApiSecret,
+		}, nil
+	case models.SenderTransportSendgrid:
+		return sendgridSender{
+			composer: identity,
+			client:   client,
+			baseUrl:  apiUrl(sender.ApiUrl, "https://api.sendgrid.com"),
+			apiKey:   sender.ApiKey,
+		}, nil
+	case models.SenderTransportMailgun:
+		_, domain := utils.SplitAddress(sender.EmailFrom)
+		return mailgunSender{
+			composer: identity,
+			client:   client,
+			baseUrl:  apiUrl(sender.ApiUrl, "https://api.mailgun.net"),
+			domain:   domain,
+			apiKey:   sender.ApiKey,
+		}, nil
+	case models.SenderTransportSes:
+		return sesSender{
+			composer:        identity,
+			client:          client,
+			baseUrl:         apiUrl(sender.ApiUrl, "https://email."+sender.ApiRegion+".amazonaws.com"),
+			region:          sender.ApiRegion,
+			accessKeyId:     sender.ApiKey,
+			secretAccessKey: sender.ApiSecret,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown sender transport: %s", sender.Transport)
+}
+
+// checkApiSender reports the settings a transport is missing
+// an api url is held to the same rules as a webhook url, messages must not be posted to private addresses
+func checkApiSender(sender models.Sender, allowPrivate bool) error {
+	if sender.ApiUrl != "" {
+		if err := webhook.ValidateUrl(sender.ApiUrl, allowPrivate); err != nil {
+			return fmt.Errorf("invalid api url: %w", err)
+		}
+	}
+
+	switch sender.Transport {
+	case models.SenderTransportWebhook:
+		if sender.ApiUrl == "" {
+			return fmt.Errorf("api url is required for the webhook transport")
+		}
+	case models.SenderTransportSendgrid, models.SenderTransportMailgun:
+		if sender.ApiKey == "" {
+			return fmt.Errorf("api key is required for the %s transport", sender.Transport)
+		}
+	case models.SenderTransportSes:
+		if sender.ApiKey == "" || sender.ApiSecret == "" || sender.ApiRegion == "" {
+			return fmt.Errorf("api key, api secret and api region are required for the ses transport")
+		}
+	}
+
+	return nil
+}
+
+func apiUrl(configured string, fallback string) string {
+	if configured == "" {
+		return fallback
+	}
+
+	return strings.TrimSuffix(configured, "/")
+}
+
+// doRequest sends req, anything but a 2xx response is an error carrying the start of the response body
+func doRequest(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s, body: %s", res.Status, strings.TrimSpace(string(body[:min(len(body), 512)])))
+	}
+
+	return nil
+}
+
+// webhookSender posts every message as json to a url of the workspace's choice, signed like the batch webhooks
+type webhookSender struct {
+	composer
+	client *http.Client
+	url    string
+	secret string
+}
+
+type webhookMessage struct {
+	From     string            `json:"from"`
+	FromName string            `json:"fromName"`
+	ReplyTo  string            `json:"replyTo,omitempty"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Text     string            `json:"text,omitempty"`
+	Html     string            `json:"html,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// envelope sender, set when VERP is enabled
+	ReturnPath string `json:"returnPath,omitempty"`
+	// the full message as it would go out over smtp, dkim signed when signing is configured
+	Raw string `json:"raw"`
+}
+
+// SendMail implements Sender.
+func (s webhookSender) SendMail(param Email) error {
+	raw, err := s.buildMessage(param)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookMessage{
+		From:       s.config.EmailFrom,
+		FromName:   s.config.EmailAlias,
+		ReplyTo:    s.config.ReplyTo,
+		To:         param.To,
+		Subject:    param.Subject,
+		Text:       param.Body,
+		Html:       param.Template,
+		Headers:    param.Headers,
+		ReturnPath: param.ReturnPath,
+		Raw:        base64.StdEncoding.EncodeToString(raw),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "toa-sender")
+	if s.secret != "" {
+		req.Header.Set(webhook.SignatureHeader, webhook.Signature(s.secret, time.Now().Unix(), body))
+	}
+
+	return doRequest(s.client, req)
+}
+
+// sendgridSender sends through the v3 mail send api, sendgrid builds and signs the message itself
+type sendgridSender struct {
+	composer
+	client  *http.Client
+	baseUrl string
+	apiKey  string
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendgridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendgridAttachment struct {
+	Content     string `json:"content"`
+	Filename    string `json:"filename"`
+	Type        string `json:"type,omitempty"`
+	Disposition string `json:"disposition"`
+}
+
+type sendgridPersonalization struct {
+	To []sendgridAddress `json:"to"`
+}
+
+type sendgridMessage struct {
+	Personalizations []sendgridPersonalization `json:"personalizations"`
+	From             sendgridAddress           `json:"from"`
+	ReplyTo          *sendgridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendgridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Attachments      []sendgridAttachment      `json:"attachments,omitempty"`
+}
+
+// SendMail implements Sender.
+func (s sendgridSender) SendMail(param Email) error {
+	message := sendgridMessage{
+		Personalizations: []sendgridPersonalization{{
+			To: []sendgridAddress{{Email: param.To}},
+		}},
+		From:    sendgridAddress{Email: s.config.EmailFrom, Name: s.config.EmailAlias},
+		Subject: param.Subject,
+		Headers: map[string]string{},
+	}
+
+	if s.config.ReplyTo != "" {
+		message.ReplyTo = &sendgridAddress{Email: s.config.ReplyTo}
+	}
+
+	if param.Template != "" {
+		message.Content = []sendgridContent{{Type: "text/html", Value: param.Template}}
+	} else {
+		message.Content = []sendgridContent{{Type: "text/plain", Value: param.Body}}
+	}
+
+	for key, value := range param.Headers {
+		// sendgrid refuses it as a custom header
+		if strings.EqualFold(key, "Reply-To") {
+			message.ReplyTo = &sendgridAddress{Email: value}
+			continue
+		}
+		message.Headers[key] = value
+	}
+
+	for _, attachment := range param.Attachments {
+		message.Attachments = append(message.Attachments, sendgridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			Filename:    attachment.Filename,
+			Type:        attachment.ContentType,
+			Disposition: "attachment",
+		})
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.baseUrl+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	return doRequest(s.client, req)
+}
+
+// mailgunSender hands the full message to the mime api of the sender's domain
+type mailgunSender struct {
+	composer
+	client  *http.Client
+	baseUrl string
+	domain  string
+	apiKey  string
+}
+
+// SendMail implements Sender.
+func (s mailgunSender) SendMail(param Email) error {
+	raw, err := s.buildMessage(param)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("to", param.To); err != nil {
+		return err
+	}
+
+	part, err := writer.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return err
+	}
+
+	if _, err := part.Write(raw); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.baseUrl+"/v3/"+url.PathEscape(s.domain)+"/messages.mime", &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.SetBasicAuth("api", s.apiKey)
+
+	return doRequest(s.client, req)
+}
@@ -0,0 +1,350 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/webhook"
+)
+
+var testIdentity = composer{config: EmailConfig{
+	EmailFrom:  "news@toa.example.com",
+	EmailAlias: "Toa News",
+	ReplyTo:    "support@toa.example.com",
+}}
+
+var testEmail = Email{
+	To:         "someone@example.org",
+	Subject:    "hello",
+	Body:       "hi there",
+	Headers:    map[string]string{"List-Unsubscribe": "<https://toa.example.com/u/token>"},
+	ReturnPath: "bounces+42@toa.example.com",
+}
+
+// received is a request the stand-in api got, read before the handler returns
+type received struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+	host   string
+}
+
+// standInApi answers every request with status and body and hands the requests it got to the test
+func standInApi(t *testing.T, status int, body string) (*httptest.Server, <-chan received) {
+	t.Helper()
+
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		requests <- received{method: r.Method, path: r.URL.Path, header: r.Header, body: data, host: r.Host}
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func newTestSender(t *testing.T, sender models.Sender) Sender {
+	t.Helper()
+
+	if err := checkApiSender(sender, true); err != nil {
+		t.Fatalf("checkApiSender() error = %v", err)
+	}
+
+	result, err := newApiSender(sender, testIdentity, &http.Client{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("newApiSender() error = %v", err)
+	}
+
+	return result
+}
+
+func TestWebhookSender(t *testing.T) {
+	server, requests := standInApi(t, http.StatusNoContent, "")
+
+	sender := newTestSender(t, models.Sender{
+		Transport: models.SenderTransportWebhook,
+		ApiUrl:    server.URL + "/deliver",
+		ApiSecret: "whsec_test",
+	})
+
+	if err := sender.SendMail(testEmail); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/deliver" || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s %s %s", req.method, req.path, req.header.Get("Content-Type"))
+	}
+
+	signature := req.header.Get(webhook.SignatureHeader)
+	timestamp, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature != webhook.Signature("whsec_test", unix, req.body) {
+		t.Errorf("signature = %q does not sign the body", signature)
+	}
+
+	var message webhookMessage
+	if err := json.Unmarshal(req.body, &message); err != nil {
+		t.Fatalf("body is not json: %v", err)
+	}
+
+	if message.From != "news@toa.example.com" || message.FromName != "Toa News" || message.To != testEmail.To ||
+		message.Subject != testEmail.Subject || message.Text != testEmail.Body || message.ReturnPath != testEmail.ReturnPath {
+		t.Errorf("message = %+v", message)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(message.Raw)
+	if err != nil || !bytes.Contains(raw, []byte("List-Unsubscribe: <https://toa.example.com/u/token>\r\n")) {
+		t.Errorf("raw message = %q, err: %v", raw, err)
+	}
+}
+
+func TestWebhookSenderUnsigned(t *testing.T) {
+	server, requests := standInApi(t, http.StatusOK, "")
+
+	sender := newTestSender(t, models.Sender{Transport: models.SenderTransportWebhook, ApiUrl: server.URL})
+	if err := sender.SendMail(testEmail); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if req := <-requests; req.header.Get(webhook.SignatureHeader) != "" {
+		t.Errorf("request without a secret is signed")
+	}
+}
+
+func TestSendgridSender(t *testing.T) {
+	server, requests := standInApi(t, http.StatusAccepted, "")
+
+	sender := newTestSender(t, models.Sender{
+		Transport: models.SenderTransportSendgrid,
+		ApiUrl:    server.URL + "/",
+		ApiKey:    "SG.test",
+	})
+
+	email := testEmail
+	email.Template = "<p>hi there</p>"
+	email.Headers = map[string]string{"Reply-To": "desk@toa.example.com", "X-Campaign": "spring"}
+	email.Attachments = []Attachment{{Filename: "a.txt", ContentType: "text/plain", Content: []byte("attached")}}
+
+	if err := sender.SendMail(email); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/v3/mail/send" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+
+	if auth := req.header.Get("Authorization"); auth != "Bearer SG.test" {
+		t.Errorf("authorization = %q", auth)
+	}
+
+	var message sendgridMessage
+	if err := json.Unmarshal(req.body, &message); err != nil {
+		t.Fatalf("body is not json: %v", err)
+	}
+
+	if len(message.Personalizations) != 1 || len(message.Personalizations[0].To) != 1 || message.Personalizations[0].To[0].Email != email.To {
+		t.Errorf("personalizations = %+v", message.Personalizations)
+	}
+
+	if message.From.Email != "news@toa.example.com" || message.From.Name != "Toa News" {
+		t.Errorf("from = %+v", message.From)
+	}
+
+	// the header of the message wins over the reply to of the sender, and never goes out as a custom header
+	if message.ReplyTo == nil || message.ReplyTo.Email != "desk@toa.example.com" {
+		t.Errorf("reply to = %+v", message.ReplyTo)
+	}
+
+	if _, ok := message.Headers["Reply-To"]; ok || message.Headers["X-Campaign"] != "spring" {
+		t.Errorf("headers = %v", message.Headers)
+	}
+
+	if len(message.Content) != 1 || message.Content[0].Type != "text/html" || message.Content[0].Value != email.Template {
+		t.Errorf("content = %+v", message.Content)
+	}
+
+	if len(message.Attachments) != 1 || message.Attachments[0].Content != base64.StdEncoding.EncodeToString([]byte("attached")) {
+		t.Errorf("attachments = %+v", message.Attachments)
+	}
+}
+
+func TestMailgunSender(t *testing.T) {
+	server, requests := standInApi(t, http.StatusOK, `{"message":"Queued. Thank you."}`)
+
+	sender := newTestSender(t, models.Sender{
+		Transport: models.SenderTransportMailgun,
+		ApiUrl:    server.URL,
+		ApiKey:    "key-test",
+		EmailFrom: "news@mg.toa.example.com",
+	})
+
+	if err := sender.SendMail(testEmail); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/v3/mg.toa.example.com/messages.mime" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+
+	httpReq, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(req.body))
+	httpReq.Header = req.header
+
+	if username, password, ok := httpReq.BasicAuth(); !ok || username != "api" || password != "key-test" {
+		t.Errorf("basic auth = %s:%s", username, password)
+	}
+
+	if err := httpReq.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("body is not a multipart form: %v", err)
+	}
+
+	if to := httpReq.FormValue("to"); to != testEmail.To {
+		t.Errorf("to = %q", to)
+	}
+
+	file, _, err := httpReq.FormFile("message")
+	if err != nil {
+		t.Fatalf("message file: %v", err)
+	}
+	defer file.Close()
+
+	raw, _ := io.ReadAll(file)
+	if !bytes.Contains(raw, []byte("To: someone@example.org\r\n")) || !bytes.Contains(raw, []byte("Subject: hello\r\n")) {
+		t.Errorf("message = %q", raw)
+	}
+}
+
+func TestSesSender(t *testing.T) {
+	server, requests := standInApi(t, http.StatusOK, `{"MessageId":"abc"}`)
+
+	sender := newTestSender(t, models.Sender{
+		Transport: models.SenderTransportSes,
+		ApiUrl:    server.URL,
+		ApiKey:    "AKIDEXAMPLE",
+		ApiSecret: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		ApiRegion: "eu-west-1",
+	})
+
+	if err := sender.SendMail(testEmail); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != sesOutboundPath {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+
+	// the signature covers what arrived, so nothing was changed on the way
+	signedAt, err := time.Parse("20060102T150405Z", req.header.Get("X-Amz-Date"))
+	if err != nil {
+		t.Fatalf("x-amz-date = %q", req.header.Get("X-Amz-Date"))
+	}
+
+	check, _ := http.NewRequest(http.MethodPost, "http://"+req.host+req.path, nil)
+	check.Header.Set("Content-Type", req.header.Get("Content-Type"))
+	sender.(sesSender).sign(check, req.body, signedAt)
+
+	auth := req.header.Get("Authorization")
+	if auth != check.Header.Get("Authorization") {
+		t.Errorf("authorization = %q, want %q", auth, check.Header.Get("Authorization"))
+	}
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"+signedAt.Format("20060102")+"/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=") {
+		t.Errorf("authorization = %q", auth)
+	}
+
+	var message sesMessage
+	if err := json.Unmarshal(req.body, &message); err != nil {
+		t.Fatalf("body is not json: %v", err)
+	}
+
+	if message.FromEmailAddress != "news@toa.example.com" || len(message.Destination.ToAddresses) != 1 ||
+		message.Destination.ToAddresses[0] != testEmail.To || message.FeedbackForwardingEmailAddress != testEmail.ReturnPath {
+		t.Errorf("message = %+v", message)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(message.Content.Raw.Data)
+	if err != nil || !bytes.Contains(raw, []byte("From: \"Toa News\" <news@toa.example.com>\r\n")) {
+		t.Errorf("raw message = %q, err: %v", raw, err)
+	}
+}
+
+func TestApiSenderErrors(t *testing.T) {
+	transports := []models.Sender{
+		{Transport: models.SenderTransportWebhook},
+		{Transport: models.SenderTransportSendgrid, ApiKey: "key"},
+		{Transport: models.SenderTransportMailgun, ApiKey: "key", EmailFrom: "news@toa.example.com"},
+		{Transport: models.SenderTransportSes, ApiKey: "key", ApiSecret: "secret", ApiRegion: "eu-west-1"},
+	}
+
+	statuses := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusUnauthorized, `{"errors":[{"message":"bad key"}]}`},
+		{http.StatusBadRequest, "invalid recipient"},
+		{http.StatusTooManyRequests, "slow down"},
+		{http.StatusBadGateway, strings.Repeat("x", 2000)},
+	}
+
+	for _, transport := range transports {
+		for _, status := range statuses {
+			server, _ := standInApi(t, status.status, status.body)
+
+			transport.ApiUrl = server.URL
+			err := newTestSender(t, transport).SendMail(testEmail)
+			if err == nil {
+				t.Errorf("%s: SendMail() succeeded on a %d response", transport.Transport, status.status)
+				continue
+			}
+
+			message := err.Error()
+			if !strings.Contains(message, http.StatusText(status.status)) || !strings.Contains(message, status.body[:min(len(status.body), 512)]) {
+				t.Errorf("%s: error = %q", transport.Transport, message)
+			}
+
+			// only the start of a long body is kept
+			if len(message) > 600 {
+				t.Errorf("%s: error is %d bytes long", transport.Transport, len(message))
+			}
+		}
+	}
+}
+
+func TestCheckApiSender(t *testing.T) {
+	tests := []struct {
+		sender  models.Sender
+		wantErr bool
+	}{
+		{models.Sender{Transport: models.SenderTransportWebhook}, true},
+		{models.Sender{Transport: models.SenderTransportWebhook, ApiUrl: "https://hooks.example.org"}, false},
+		{models.Sender{Transport: models.SenderTransportSendgrid}, true},
+		{models.Sender{Transport: models.SenderTransportMailgun, ApiKey: "key"}, false},
+		{models.Sender{Transport: models.SenderTransportSes, ApiKey: "key", ApiSecret: "secret"}, true},
+		{models.Sender{Transport: models.SenderTransportSes, ApiKey: "key", ApiSecret: "secret", ApiRegion: "eu-west-1"}, false},
+		{models.Sender{Transport: models.SenderTransportWebhook, ApiUrl: "http://127.0.0.1:8080/deliver"}, true},
+		{models.Sender{Transport: models.SenderTransportWebhook, ApiUrl: "http://169.254.169.254/latest"}, true},
+		{models.Sender{Transport: models.SenderTransportSendgrid, ApiKey: "key", ApiUrl: "file:///etc/passwd"}, true},
+	}
+
+	for _, test := range tests {
+		if err := checkApiSender(test.sender, false); (err != nil) != test.wantErr {
+			t.Errorf("checkApiSender(%+v) error = %v, wantErr %v", test.sender, err, test.wantErr)
+		}
+	}
+}
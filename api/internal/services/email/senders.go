@@ -0,0 +1,230 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetSenders implements EmailUsecase.
+func (u Usecase) GetSenders(ctx context.Context) ([]models.Sender, int, error) {
+	results, err := u.repository.ReadSenders(ctx, u.db, apikey.WorkspaceFromContext(ctx))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetSender implements EmailUsecase.
+func (u Usecase) GetSender(ctx context.Context, senderId string) (models.Sender, int, error) {
+	result, err := u.repository.ReadSender(ctx, u.db, apikey.WorkspaceFromContext(ctx), senderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Sender{}, http.StatusNotFound, fmt.Errorf("sender: %s not found", senderId)
+	}
+	if err != nil {
+		return models.Sender{}, http.StatusInternalServerError, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// CreateSender implements EmailUsecase.
+func (u Usecase) CreateSender(ctx context.Context, param models.PostSenderRequest) (models.Sender, int, error) {
+	now := time.Now()
+	sender := models.Sender{
+		Id:             uuid.NewV4().String(),
+		WorkspaceId:    apikey.WorkspaceFromContext(ctx),
+		Name:           param.Name,
+		EmailFrom:      param.EmailFrom,
+		EmailAlias:     param.EmailAlias,
+		ReplyTo:        param.ReplyTo,
+		SmtpHost:       param.SmtpHost,
+		SmtpPort:       param.SmtpPort,
+		SmtpUsername:   param.SmtpUsername,
+		SmtpPassword:   param.SmtpPassword,
+		DkimSelector:   param.DkimSelector,
+		DkimDomain:     param.DkimDomain,
+		DkimPrivateKey: param.DkimPrivateKey,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Transport:      senderTransport(param.Transport),
+		ApiUrl:         param.ApiUrl,
+		ApiKey:         param.ApiKey,
+		ApiSecret:      param.ApiSecret,
+		ApiRegion:      param.ApiRegion,
+
+		RequiresApproval: param.RequiresApproval,
+	}
+
+	if err := checkSenderKey(sender); err != nil {
+		return models.Sender{}, http.StatusBadRequest, err
+	}
+
+	if err := checkApiSender(sender, u.cfg.Webhook.AllowPrivate); err != nil {
+		return models.Sender{}, http.StatusBadRequest, err
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateSender(ctx, tx, sender); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceSender, sender.Id, nil, sender)
+	})
+	if utils.IsUniqueViolation(err) {
+		return models.Sender{}, http.StatusConflict, fmt.Errorf("sender: %s already exists", sender.Name)
+	}
+	if err != nil {
+		return models.Sender{}, http.StatusInternalServerError, err
+	}
+
+	return sender, http.StatusCreated, nil
+}
+
+// UpdateSender implements EmailUsecase.
+// an empty password or key keeps the stored one
+func (u Usecase) UpdateSender(ctx context.Context, senderId string, param models.PutSenderRequest) (models.Sender, int, error) {
+	sender, statusCode, err := u.GetSender(ctx, senderId)
+	if err != nil {
+		return models.Sender{}, statusCode, err
+	}
+
+	before := sender
+	sender.Name = param.Name
+	sender.EmailFrom = param.EmailFrom
+	sender.EmailAlias = param.EmailAlias
+	sender.ReplyTo = param.ReplyTo
+	sender.SmtpHost = param.SmtpHost
+	sender.SmtpPort = param.SmtpPort
+	sender.SmtpUsername = param.SmtpUsername
+	if param.SmtpPassword != "" {
+		sender.SmtpPassword = param.SmtpPassword
+	}
+	sender.DkimSelector = param.DkimSelector
+	sender.DkimDomain = param.DkimDomain
+	if param.DkimPrivateKey != "" {
+		sender.DkimPrivateKey = param.DkimPrivateKey
+	}
+	sender.Transport = senderTransport(param.Transport)
+	sender.ApiUrl = param.ApiUrl
+	if param.ApiKey != "" {
+		sender.ApiKey = param.ApiKey
+	}
+	if param.ApiSecret != "" {
+		sender.ApiSecret = param.ApiSecret
+	}
+	sender.ApiRegion = param.ApiRegion
+	sender.RequiresApproval = param.RequiresApproval
+	sender.UpdatedAt = time.Now()
+
+	if sender.DkimSelector != "" && sender.DkimPrivateKey == "" {
+		return models.Sender{}, http.StatusBadRequest, fmt.Errorf("dkim private key is required with a dkim selector")
+	}
+
+	if err := checkSenderKey(sender); err != nil {
+		return models.Sender{}, http.StatusBadRequest, err
+	}
+
+	if err := checkApiSender(sender, u.cfg.Webhook.AllowPrivate); err != nil {
+		return models.Sender{}, http.StatusBadRequest, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateSender(ctx, tx, sender); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceSender, sender.Id, before, sender)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Sender{}, http.StatusNotFound, fmt.Errorf("sender: %s not found", senderId)
+	}
+	if utils.IsUniqueViolation(err) {
+		return models.Sender{}, http.StatusConflict, fmt.Errorf("sender: %s already exists", sender.Name)
+	}
+	if err != nil {
+		return models.Sender{}, http.StatusInternalServerError, err
+	}
+
+	return sender, http.StatusOK, nil
+}
+
+// DeleteSender implements EmailUsecase.
+// batches sent as the sender fall back to the sender of their workspace
+func (u Usecase) DeleteSender(ctx context.Context, senderId string) (int, error) {
+	before, statusCode, err := u.GetSender(ctx, senderId)
+	if err != nil {
+		return statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteSender(ctx, tx, apikey.WorkspaceFromContext(ctx), senderId); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceSender, senderId, before, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("sender: %s not found", senderId)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// checkSender rejects a batch sent as a sender outside the caller's workspace or its api key
+// a key restricted to some senders has to name one of them
+func (u Usecase) checkSender(ctx context.Context, senderId *string) (int, error) {
+	apiKey, hasKey := apikey.FromContext(ctx)
+
+	if senderId == nil {
+		if hasKey && len(apiKey.SenderIds) > 0 {
+			return http.StatusForbidden, fmt.Errorf("api key can only send as one of its senders")
+		}
+		return http.StatusOK, nil
+	}
+
+	_, statusCode, err := u.GetSender(ctx, *senderId)
+	if err != nil {
+		if statusCode == http.StatusNotFound {
+			statusCode = http.StatusBadRequest
+		}
+		return statusCode, err
+	}
+
+	if hasKey && !apiKey.CanUseSender(*senderId) {
+		return http.StatusForbidden, fmt.Errorf("api key cannot send as sender: %s", *senderId)
+	}
+
+	return http.StatusOK, nil
+}
+
+// checkSenderKey parses the signing key of a sender so a bad key is refused before any batch uses it
+func checkSenderKey(sender models.Sender) error {
+	_, err := newClientSigner(senderConfig(sender))
+	if err != nil {
+		return fmt.Errorf("invalid dkim private key: %s", err.Error())
+	}
+
+	return nil
+}
+
+func senderTransport(transport string) string {
+	if transport == "" {
+		return models.SenderTransportSmtp
+	}
+
+	return transport
+}
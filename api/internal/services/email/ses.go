@@ -0,0 +1,123 @@
+package email
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sesOutboundPath = "/v2/email/outbound-emails"
+
+// sesSender hands the full message to the SES v2 send email api, requests are signed with AWS signature version 4
+type sesSender struct {
+	composer
+	client          *http.Client
+	baseUrl         string
+	region          string
+	accessKeyId     string
+	secretAccessKey string
+}
+
+type sesMessage struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Raw struct {
+			Data string `json:"Data"`
+		} `json:"Raw"`
+	} `json:"Content"`
+	// bounces go to the envelope sender when VERP is enabled
+	FeedbackForwardingEmailAddress string `json:"FeedbackForwardingEmailAddress,omitempty"`
+}
+
+// SendMail implements Sender.
+func (s sesSender) SendMail(param Email) error {
+	raw, err := s.buildMessage(param)
+	if err != nil {
+		return err
+	}
+
+	var message sesMessage
+	message.FromEmailAddress = s.config.EmailFrom
+	message.Destination.ToAddresses = []string{param.To}
+	message.Content.Raw.Data = base64.StdEncoding.EncodeToString(raw)
+	message.FeedbackForwardingEmailAddress = param.ReturnPath
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.baseUrl+sesOutboundPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	s.sign(req, body, time.Now().UTC())
+
+	return doRequest(s.client, req)
+}
+
+// sign adds the signature version 4 headers for the ses service
+func (s sesSender) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.region + "/ses/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSha256(key, s.region)
+	key = hmacSha256(key, "ses")
+	key = hmacSha256(key, "aws4_request")
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKeyId+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+hex.EncodeToString(hmacSha256(key, stringToSign)))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,28 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// a comment line is sent this often so idle connections are not dropped
+const streamKeepAlive = 15 * time.Second
+
+// writeEvent writes one server-sent event, named after its type so clients can listen per type
+func writeEvent(res *echo.Response, event models.BatchStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+
+	res.Flush()
+	return nil
+}
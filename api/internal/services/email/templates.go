@@ -0,0 +1,167 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetTemplates implements EmailUsecase.
+func (u Usecase) GetTemplates(ctx context.Context) ([]models.Template, int, error) {
+	results, err := u.repository.ReadTemplates(ctx, u.db, apikey.WorkspaceFromContext(ctx))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetTemplate implements EmailUsecase.
+func (u Usecase) GetTemplate(ctx context.Context, templateId string) (models.Template, int, error) {
+	result, err := u.repository.ReadTemplate(ctx, u.db, apikey.WorkspaceFromContext(ctx), templateId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Template{}, http.StatusNotFound, fmt.Errorf("template: %s not found", templateId)
+	}
+	if err != nil {
+		return models.Template{}, http.StatusInternalServerError, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// CreateTemplate implements EmailUsecase.
+func (u Usecase) CreateTemplate(ctx context.Context, param models.PostTemplateRequest) (models.Template, int, error) {
+	now := time.Now()
+	template := models.Template{
+		Id:          uuid.NewV4().String(),
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+		Name:        param.Name,
+		Subject:     param.Subject,
+		Body:        param.Body,
+		Template:    param.Template,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := compileContent(Email{
+		Subject:  template.Subject,
+		Body:     template.Body,
+		Template: template.Template,
+	}); err != nil {
+		return models.Template{}, http.StatusBadRequest, err
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateTemplate(ctx, tx, template); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceTemplate, template.Id, nil, template)
+	})
+	if utils.IsUniqueViolation(err) {
+		return models.Template{}, http.StatusConflict, fmt.Errorf("template: %s already exists", template.Name)
+	}
+	if err != nil {
+		return models.Template{}, http.StatusInternalServerError, err
+	}
+
+	return template, http.StatusCreated, nil
+}
+
+// UpdateTemplate implements EmailUsecase.
+// batches already sent with the template keep their own copy of the content
+func (u Usecase) UpdateTemplate(ctx context.Context, templateId string, param models.PutTemplateRequest) (models.Template, int, error) {
+	template, statusCode, err := u.GetTemplate(ctx, templateId)
+	if err != nil {
+		return models.Template{}, statusCode, err
+	}
+
+	before := template
+	template.Name = param.Name
+	template.Subject = param.Subject
+	template.Body = param.Body
+	template.Template = param.Template
+	template.UpdatedAt = time.Now()
+
+	if _, err := compileContent(Email{
+		Subject:  template.Subject,
+		Body:     template.Body,
+		Template: template.Template,
+	}); err != nil {
+		return models.Template{}, http.StatusBadRequest, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateTemplate(ctx, tx, template); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceTemplate, template.Id, before, template)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Template{}, http.StatusNotFound, fmt.Errorf("template: %s not found", templateId)
+	}
+	if utils.IsUniqueViolation(err) {
+		return models.Template{}, http.StatusConflict, fmt.Errorf("template: %s already exists", template.Name)
+	}
+	if err != nil {
+		return models.Template{}, http.StatusInternalServerError, err
+	}
+
+	return template, http.StatusOK, nil
+}
+
+// DeleteTemplate implements EmailUsecase.
+func (u Usecase) DeleteTemplate(ctx context.Context, templateId string) (int, error) {
+	before, statusCode, err := u.GetTemplate(ctx, templateId)
+	if err != nil {
+		return statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteTemplate(ctx, tx, apikey.WorkspaceFromContext(ctx), templateId); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceTemplate, templateId, before, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("template: %s not found", templateId)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// withTemplate fills the content of batch from a stored template of the caller's workspace
+// the batch is returned as is when no template is given
+func (u Usecase) withTemplate(ctx context.Context, batch models.EmailBatch, templateId string) (models.EmailBatch, int, error) {
+	if templateId == "" {
+		return batch, http.StatusOK, nil
+	}
+
+	template, statusCode, err := u.GetTemplate(ctx, templateId)
+	if err != nil {
+		if statusCode == http.StatusNotFound {
+			statusCode = http.StatusBadRequest
+		}
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	batch.Subject = template.Subject
+	batch.Body = template.Body
+	batch.Template = template.Template
+
+	return batch, http.StatusOK, nil
+}
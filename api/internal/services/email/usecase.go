@@ -1,86 +1,979 @@
 package email
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/abbyfakhri/toa-api/internal/config"
 	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/services/quota"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/abbyfakhri/toa-api/internal/services/tracking"
+	"github.com/abbyfakhri/toa-api/internal/services/webhook"
 	"github.com/abbyfakhri/toa-api/internal/utils"
 	"github.com/jmoiron/sqlx"
 	uuid "github.com/satori/go.uuid"
+	xhtml "golang.org/x/net/html"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
 )
 
 type Usecase struct {
-	db          *sqlx.DB
-	repository  EmailRepository
-	emailClient EmailClient
+	db                    *sqlx.DB
+	repository            EmailRepository
+	suppressionRepository suppression.SuppressionRepository
+	planner               quota.Planner
+	recorder              audit.Recorder
+	clients               *ClientPool
+	cfg                   config.Config
+	workers               *workers
+	observers             []BatchObserver
+	hub                   *hub
 }
 
 // SendEmailsWithCsv implements EmailUsecase.
-func (u Usecase) SendEmailsWithCsv(ctx context.Context, param models.PostEmailRequestCsv, r io.Reader) (batchId string, statusCode int, err error) {
-	batchId = uuid.NewV4().String()
-
+// a retry with the idempotency key of an accepted request gets its batch back, the file is part of the request
+func (u Usecase) SendEmailsWithCsv(ctx context.Context, param models.PostEmailRequestCsv, r io.Reader) (models.PostEmailResponse, int, error) {
 	// read file
-	emails, err := utils.ReadCsv(r, param.TargetColumn)
+	file, err := io.ReadAll(r)
 	if err != nil {
-		return "", http.StatusBadRequest, err
+		return models.PostEmailResponse{}, http.StatusBadRequest, err
 	}
 
-	if len(emails) == 0 {
-		return "", http.StatusBadRequest, fmt.Errorf("email destination cannot be empty")
+	columns, records, err := utils.ReadCsvRecords(bytes.NewReader(file), param.TargetColumn)
+	if err != nil {
+		return models.PostEmailResponse{}, http.StatusBadRequest, err
+	}
+
+	if len(records) == 0 {
+		return models.PostEmailResponse{}, http.StatusBadRequest, fmt.Errorf("email destination cannot be empty")
+	}
+
+	// the rest of each row is kept with the recipient for exports
+	emails := make([]string, 0, len(records))
+	metadata := make([]models.Metadata, 0, len(records))
+	for _, record := range records {
+		emails = append(emails, record.Value)
+		metadata = append(metadata, record.Columns)
+	}
+
+	batch, statusCode, err := u.withTemplate(ctx, models.EmailBatch{
+		Subject:         param.Subject,
+		Body:            param.Body,
+		Template:        param.Template,
+		TrackOpens:      param.TrackOpens,
+		TrackClicks:     param.TrackClicks,
+		UtmParams:       param.Utm,
+		WebhookUrl:      param.WebhookUrl,
+		WebhookSecret:   param.WebhookSecret,
+		ReportTo:        param.ReportTo,
+		MetadataColumns: columns,
+	}, param.TemplateId)
+	if err != nil {
+		return models.PostEmailResponse{}, statusCode, err
+	}
+
+	if param.SenderId != "" {
+		batch.SenderId = &param.SenderId
+	}
+
+	hash, err := requestHash(param, file)
+	if err != nil {
+		return models.PostEmailResponse{}, http.StatusInternalServerError, err
+	}
+
+	return u.acceptOnce(ctx, param.IdempotencyKey, hash, func(key *models.IdempotencyKey) (models.EmailBatch, int, error) {
+		return u.acceptBatch(ctx, batch, emails, metadata, param.SendAt, param.TimeZone, key)
+	})
+}
+
+// SendEmails implements EmailUsecase.
+// a retry with the idempotency key of an accepted request gets its batch back
+func (u Usecase) SendEmails(ctx context.Context, param models.PostEmailRequest) (models.PostEmailResponse, int, error) {
+	if len(param.Destinations) == 0 {
+		return models.PostEmailResponse{}, http.StatusBadRequest, fmt.Errorf("email destination cannot be empty")
+	}
+
+	batch, statusCode, err := u.withTemplate(ctx, models.EmailBatch{
+		Subject:       param.Subject,
+		Body:          param.Body,
+		Template:      param.Template,
+		TrackOpens:    param.TrackOpens,
+		TrackClicks:   param.TrackClicks,
+		UtmParams:     param.Utm,
+		WebhookUrl:    param.WebhookUrl,
+		WebhookSecret: param.WebhookSecret,
+		ReportTo:      param.ReportTo,
+	}, param.TemplateId)
+	if err != nil {
+		return models.PostEmailResponse{}, statusCode, err
+	}
+
+	if param.SenderId != "" {
+		batch.SenderId = &param.SenderId
+	}
+
+	hash, err := requestHash(param, nil)
+	if err != nil {
+		return models.PostEmailResponse{}, http.StatusInternalServerError, err
+	}
+
+	return u.acceptOnce(ctx, param.IdempotencyKey, hash, func(key *models.IdempotencyKey) (models.EmailBatch, int, error) {
+		return u.acceptBatch(ctx, batch, param.Destinations, nil, param.SendAt, param.TimeZone, key)
+	})
+}
+
+// GetBatch implements EmailUsecase.
+func (u Usecase) GetBatch(ctx context.Context, batchId string) (models.GetBatchResponse, int, error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.GetBatchResponse{}, statusCode, err
+	}
+
+	stats, err := u.repository.ReadBatchStats(ctx, u.db, batch.WorkspaceId, batchId)
+	if err != nil {
+		return models.GetBatchResponse{}, http.StatusInternalServerError, err
+	}
+
+	if batch.SuccessCount > 0 {
+		stats.OpenRate = float64(stats.UniqueOpens) / float64(batch.SuccessCount)
+		stats.ClickRate = float64(stats.UniqueClicks) / float64(batch.SuccessCount)
+	}
+
+	return models.GetBatchResponse{
+		EmailBatch: batch,
+		Stats:      stats,
+	}, http.StatusOK, nil
+}
+
+// StreamBatch implements EmailUsecase.
+// sent and failed events only carry the email, the totals are kept here from the state the client started with
+func (u Usecase) StreamBatch(ctx context.Context, batchId string) (models.BatchStreamEvent, <-chan models.BatchStreamEvent, int, error) {
+	// follow first so nothing between reading the state and following is missed
+	events, unsubscribe := u.hub.subscribe(batchId)
+
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		unsubscribe()
+		return models.BatchStreamEvent{}, nil, statusCode, err
+	}
+
+	totals, err := u.repository.ReadBatchTotals(ctx, u.db, batch.WorkspaceId, batchId)
+	if err != nil {
+		unsubscribe()
+		return models.BatchStreamEvent{}, nil, http.StatusInternalServerError, err
 	}
 
+	state := models.BatchStreamEvent{
+		Type:    models.BatchEventState,
+		BatchId: batch.Id,
+		Status:  batch.Status,
+		Totals:  totals,
+	}
+
+	if batch.Status == models.BatchStatusCompleted || batch.Status == models.BatchStatusCanceled {
+		unsubscribe()
+		return state, nil, http.StatusOK, nil
+	}
+
+	out := make(chan models.BatchStreamEvent)
+
 	go func() {
-		for _, email := range emails {
-			err := u.emailClient.SendMail(Email{
-				To:       email,
-				Subject:  param.Subject,
-				Body:     param.Body,
-				Template: param.Template,
-			})
+		<-ctx.Done()
+		unsubscribe()
+	}()
 
-			if err != nil {
-				log.Printf("fail to send email: %s", err.Error())
-				// TODO: LOG THIS TO DATABASE
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		for event := range events {
+			switch event.Type {
+			case models.BatchEventSent:
+				totals.Sent++
+				totals.Pending--
+			case models.BatchEventFailed:
+				totals.Failed++
+				totals.Pending--
+			default:
+				totals = event.Totals
+			}
+			event.Totals = totals
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+
+			if event.Type == models.BatchEventCompleted {
+				return
 			}
 		}
 	}()
-	return batchId, http.StatusAccepted, nil
+
+	return state, out, http.StatusOK, nil
 }
 
-// SendEmails implements EmailUsecase.
-func (u Usecase) SendEmails(ctx context.Context, param models.PostEmailRequest) (batchId string, statusCode int, err error) {
-	batchId = uuid.NewV4().String()
+// IssueStreamToken implements EmailUsecase.
+func (u Usecase) IssueStreamToken(ctx context.Context, batchId string) (models.BatchStreamToken, int, error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.BatchStreamToken{}, statusCode, err
+	}
+
+	apiKey, ok := apikey.FromContext(ctx)
+	if !ok {
+		return models.BatchStreamToken{}, http.StatusForbidden, fmt.Errorf("stream tokens are only issued to api keys")
+	}
+
+	if u.cfg.TokenSecret == "" {
+		return models.BatchStreamToken{}, http.StatusInternalServerError, fmt.Errorf("token secret is not configured")
+	}
+
+	path := "/email/batch/" + batch.Id + "/events"
+	expiresAt := time.Now().Add(apikey.StreamTokenTTL).Truncate(time.Second)
+	token := apikey.SignStreamToken(u.cfg.TokenSecret, apiKey.Id, path, expiresAt)
+
+	return models.BatchStreamToken{
+		Token:     token,
+		Url:       path + "?" + url.Values{apikey.StreamTokenParam: {token}}.Encode(),
+		ExpiresAt: expiresAt,
+	}, http.StatusCreated, nil
+}
+
+// GetScheduledBatches implements EmailUsecase.
+func (u Usecase) GetScheduledBatches(ctx context.Context, param models.GetBatchRequest) ([]models.EmailBatch, int, error) {
+	return u.listBatches(ctx, models.BatchStatusScheduled, param)
+}
 
+// listBatches pages through the batches of the caller's workspace in status
+func (u Usecase) listBatches(ctx context.Context, status string, param models.GetBatchRequest) ([]models.EmailBatch, int, error) {
+	param.Status = status
+	if param.Limit <= 0 {
+		param.Limit = defaultListLimit
+	}
+	if param.Limit > maxListLimit {
+		param.Limit = maxListLimit
+	}
+	if param.Offset < 0 {
+		param.Offset = 0
+	}
+
+	results, err := u.repository.ReadBatches(ctx, u.db, apikey.WorkspaceFromContext(ctx), param)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// RescheduleBatch implements EmailUsecase.
+// a send time that has already passed releases the batch right away
+func (u Usecase) RescheduleBatch(ctx context.Context, batchId string, param models.PutBatchScheduleRequest) (models.EmailBatch, int, error) {
+	sendAt, timeZone, err := parseSendAt(param.SendAt, param.TimeZone)
+	if err != nil {
+		return models.EmailBatch{}, http.StatusBadRequest, err
+	}
+
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	before := batch
+	batch.SendAt = sendAt
+	batch.TimeZone = timeZone
+	batch.Status = models.BatchStatusScheduled
+	if !sendAt.After(time.Now()) {
+		batch.Status = models.BatchStatusQueued
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		// guards against the scheduler releasing the batch in between
+		if err := u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batchId, []string{models.BatchStatusScheduled}, batch.Status); err != nil {
+			return err
+		}
+
+		if err := u.replanBatch(ctx, tx, batch); err != nil {
+			return err
+		}
+
+		if err := u.repository.UpdateBatch(ctx, tx, batch); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceBatch, batchId, before, batch)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusConflict, fmt.Errorf("batch: %s is not scheduled", batchId)
+	}
+	if errors.As(err, &quota.ExceededError{}) || errors.Is(err, quota.ErrTooLarge) {
+		return models.EmailBatch{}, http.StatusTooManyRequests, err
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	if batch.Status == models.BatchStatusQueued {
+		go u.runBatch(batch)
+	}
+
+	return batch, http.StatusOK, nil
+}
+
+// PauseBatch implements EmailUsecase.
+// a running batch stops after the email it is sending, the rest stay pending until it is resumed
+func (u Usecase) PauseBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error) {
+	from := []string{models.BatchStatusQueued, models.BatchStatusSending, models.BatchStatusDeferred}
+	return u.moveBatch(ctx, batchId, models.AuditActionPause, from, models.BatchStatusPaused)
+}
+
+// ResumeBatch implements EmailUsecase.
+func (u Usecase) ResumeBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error) {
+	batch, statusCode, err := u.moveBatch(ctx, batchId, models.AuditActionResume, []string{models.BatchStatusPaused}, models.BatchStatusQueued)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	go u.runBatch(batch)
+
+	return batch, statusCode, nil
+}
+
+// CancelBatch implements EmailUsecase.
+// the pending emails of the batch are never sent, a running batch stops after the email it is sending
+func (u Usecase) CancelBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error) {
+	before, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		from := []string{
+			models.BatchStatusPendingApproval, models.BatchStatusScheduled, models.BatchStatusQueued, models.BatchStatusSending, models.BatchStatusDeferred,
+			models.BatchStatusPaused,
+		}
+		if err := u.repository.UpdateBatchStatus(ctx, tx, before.WorkspaceId, batchId, from, models.BatchStatusCanceled); err != nil {
+			return err
+		}
+
+		if err := u.repository.UpdateEmailsStatus(ctx, tx, before.WorkspaceId, batchId, models.EmailStatusPending, models.EmailStatusCanceled); err != nil {
+			return err
+		}
+
+		if err := u.repository.UpdateBatchCounts(ctx, tx, before.WorkspaceId, batchId); err != nil {
+			return err
+		}
+
+		if err := u.repository.FinishBatch(ctx, tx, before.WorkspaceId, batchId, time.Now()); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCancel, models.AuditResourceBatch, batchId, batchStatus(before.Status), batchStatus(models.BatchStatusCanceled))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusConflict, fmt.Errorf("batch: %s has already finished", batchId)
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	// a running worker stops without completing the batch, so this is the only place it finishes
+	u.notify(batchId, func(observer BatchObserver) error {
+		return observer.BatchCompleted(ctx, batch)
+	})
+
+	return batch, http.StatusOK, nil
+}
+
+// RetryFailed implements EmailUsecase.
+// the failed and bounced recipients of a finished batch are sent again in a child batch with the same content
+func (u Usecase) RetryFailed(ctx context.Context, batchId string, param models.PostBatchRetryRequest) (newBatchId string, statusCode int, err error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return "", statusCode, err
+	}
+
+	// batches sent before the content was stored cannot be copied
+	if batch.Subject == "" {
+		return "", http.StatusConflict, fmt.Errorf("batch: %s has no stored content", batchId)
+	}
+
+	if batch.Status != models.BatchStatusCompleted && batch.Status != models.BatchStatusCanceled {
+		return "", http.StatusConflict, fmt.Errorf("batch: %s is %s, it can only be retried once finished", batchId, batch.Status)
+	}
+
+	addresses := []string{}
+	metadata := []models.Metadata{}
+	for _, status := range []string{models.EmailStatusFailed, models.EmailStatusBounced} {
+		recipients, err := u.repository.ReadEmail(ctx, u.db, batch.WorkspaceId, models.GetEmailRequest{
+			BatchId: batchId,
+			Status:  status,
+		})
+		if err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+
+		for _, recipient := range recipients {
+			addresses = append(addresses, recipient.Email)
+			metadata = append(metadata, recipient.Metadata)
+		}
+	}
+
+	if len(addresses) == 0 {
+		return "", http.StatusConflict, fmt.Errorf("batch: %s has no failed recipients", batchId)
+	}
+
+	// hard bounces are on the suppression list by now, they end up suppressed in the child batch
+	child := copyBatch(batch)
+	child.ParentId = &batch.Id
+	child.MetadataColumns = batch.MetadataColumns
+
+	child, statusCode, err = u.acceptBatch(ctx, child, addresses, metadata, param.SendAt, param.TimeZone, nil)
+
+	return child.Id, statusCode, err
+}
+
+// CloneBatch implements EmailUsecase.
+func (u Usecase) CloneBatch(ctx context.Context, batchId string, param models.PostBatchCloneRequest) (newBatchId string, statusCode int, err error) {
 	if len(param.Destinations) == 0 {
 		return "", http.StatusBadRequest, fmt.Errorf("email destination cannot be empty")
 	}
-	go func() {
-		for _, address := range param.Destinations {
-			err := u.emailClient.SendMail(Email{
-				To:       address,
-				Subject:  param.Subject,
-				Body:     param.Body,
-				Template: param.Template,
+
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return "", statusCode, err
+	}
+
+	// batches sent before the content was stored cannot be copied
+	if batch.Subject == "" {
+		return "", http.StatusConflict, fmt.Errorf("batch: %s has no stored content", batchId)
+	}
+
+	clone, statusCode, err := u.acceptBatch(ctx, copyBatch(batch), param.Destinations, nil, param.SendAt, param.TimeZone, nil)
+
+	return clone.Id, statusCode, err
+}
+
+// ReleaseScheduledBatches implements EmailUsecase.
+func (u Usecase) ReleaseScheduledBatches(ctx context.Context) (released int, err error) {
+	batches, err := u.repository.ReadDueBatches(ctx, u.db, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, batch := range batches {
+		err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+			return u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batch.Id, []string{batch.Status}, models.BatchStatusQueued)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// canceled or rescheduled since it was read
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+
+		go u.runBatch(batch)
+		released++
+	}
+
+	return released, nil
+}
+
+// ResumeInterruptedBatches implements EmailUsecase.
+// an email that was being sent when the server stopped is still pending and is sent again
+func (u Usecase) ResumeInterruptedBatches(ctx context.Context) (resumed int, err error) {
+	// collect first, the workers move the batches back to sending as soon as they start
+	interrupted := []models.EmailBatch{}
+	for _, status := range []string{models.BatchStatusSending, models.BatchStatusQueued} {
+		for offset := 0; ; offset += maxListLimit {
+			batches, err := u.repository.ReadBatches(ctx, u.db, "", models.GetBatchRequest{
+				Status: status,
+				Limit:  maxListLimit,
+				Offset: offset,
 			})
+			if err != nil {
+				return 0, err
+			}
+
+			interrupted = append(interrupted, batches...)
+			if len(batches) < maxListLimit {
+				break
+			}
+		}
+	}
+
+	for _, batch := range interrupted {
+		err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+			return u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batch.Id, []string{batch.Status}, models.BatchStatusQueued)
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return resumed, err
+		}
+
+		go u.runBatch(batch)
+		resumed++
+	}
+
+	return resumed, nil
+}
+
+// VerifyDkim implements EmailUsecase.
+// without a message it signs a sample with the key the workspace or the sender sends with,
+// which checks the key pair and the published record match
+func (u Usecase) VerifyDkim(ctx context.Context, param models.PostDkimVerifyRequest) (models.DkimVerifyResponse, int, error) {
+	publicKey, err := parseDkimPublicKey(param.PublicKey)
+	if err != nil {
+		return models.DkimVerifyResponse{}, http.StatusBadRequest, err
+	}
+
+	batch := models.EmailBatch{WorkspaceId: apikey.WorkspaceFromContext(ctx)}
+	if param.SenderId != "" {
+		batch.SenderId = &param.SenderId
+	}
+
+	if statusCode, err := u.checkSender(ctx, batch.SenderId); err != nil {
+		return models.DkimVerifyResponse{}, statusCode, err
+	}
+
+	emailClient, err := u.clients.signing(ctx, batch)
+	if err != nil {
+		return models.DkimVerifyResponse{}, http.StatusInternalServerError, err
+	}
+
+	var result models.DkimVerifyResponse
+	var message []byte
+
+	if emailClient.dkim != nil {
+		record, err := emailClient.dkim.dnsRecord()
+		if err != nil {
+			return models.DkimVerifyResponse{}, http.StatusInternalServerError, err
+		}
+		result.ExpectedRecord = record
+	}
+
+	if param.Message != "" {
+		message = []byte(strings.ReplaceAll(strings.ReplaceAll(param.Message, "\r\n", "\n"), "\n", "\r\n"))
+	} else {
+		if emailClient.dkim == nil {
+			return models.DkimVerifyResponse{}, http.StatusBadRequest, fmt.Errorf("dkim signing is not configured")
+		}
+
+		message, err = emailClient.buildMessage(Email{
+			To:      emailClient.config.EmailFrom,
+			Subject: "DKIM self-test",
+			Body:    "This message was signed to verify the DKIM configuration.",
+		})
+		if err != nil {
+			return models.DkimVerifyResponse{}, http.StatusInternalServerError, err
+		}
+	}
+
+	headers, _ := splitMessage(message)
+	if index, ok := findHeader(headers, "DKIM-Signature", map[int]bool{}); ok {
+		result.Signature = strings.TrimSpace(headers[index])
+	}
+
+	if err := verifyDkim(message, publicKey); err != nil {
+		result.Error = err.Error()
+		return result, http.StatusOK, nil
+	}
+
+	result.Valid = true
+	return result, http.StatusOK, nil
+}
+
+// acceptBatch validates the content and send time of a request, stores the batch and hands it to a worker
+// unless it is scheduled for later, key is stored with the batch when the request has one
+func (u Usecase) acceptBatch(ctx context.Context, batch models.EmailBatch, addresses []string, metadata []models.Metadata, sendAt string, timeZone string, key *models.IdempotencyKey) (models.EmailBatch, int, error) {
+	if _, err := compileContent(Email{
+		Subject:  batch.Subject,
+		Body:     batch.Body,
+		Template: batch.Template,
+	}); err != nil {
+		return models.EmailBatch{}, http.StatusBadRequest, err
+	}
+
+	if batch.WebhookUrl != "" && batch.WebhookSecret == "" {
+		return models.EmailBatch{}, http.StatusBadRequest, fmt.Errorf("webhook secret is required with a webhook url")
+	}
+
+	if batch.WebhookUrl != "" {
+		if err := webhook.ValidateUrl(batch.WebhookUrl, u.cfg.Webhook.AllowPrivate); err != nil {
+			return models.EmailBatch{}, http.StatusBadRequest, err
+		}
+	}
+
+	var err error
+	batch.SendAt, batch.TimeZone, err = parseSendAt(sendAt, timeZone)
+	if err != nil {
+		return models.EmailBatch{}, http.StatusBadRequest, err
+	}
+
+	batch.WorkspaceId = apikey.WorkspaceFromContext(ctx)
+	if apiKey, ok := apikey.FromContext(ctx); ok {
+		batch.ApiKeyId = &apiKey.Id
+		batch.RequestedBy = apiKey.Owner
+	}
+
+	if statusCode, err := u.checkSender(ctx, batch.SenderId); err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	batch.Status = models.BatchStatusQueued
+	if batch.SendAt != nil && batch.SendAt.After(time.Now()) {
+		batch.Status = models.BatchStatusScheduled
+	}
+
+	approval, err := u.needsApproval(ctx, batch, len(addresses))
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+	if approval {
+		batch.Status = models.BatchStatusPendingApproval
+	}
+
+	batch, err = u.enqueueBatch(ctx, batch, addresses, metadata, key)
+	if errors.As(err, &quota.ExceededError{}) || errors.Is(err, quota.ErrTooLarge) {
+		return models.EmailBatch{}, http.StatusTooManyRequests, err
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	if batch.Status == models.BatchStatusQueued {
+		go u.runBatch(batch)
+	}
+
+	return batch, http.StatusAccepted, nil
+}
+
+// enqueueBatch stores the batch with one record per recipient, batch carries the content and options of the request
+// suppressed addresses are kept in the batch but marked so they are never sent
+// metadata is either nil or holds the metadata of each address, key is nil when the request has none
+func (u Usecase) enqueueBatch(ctx context.Context, batch models.EmailBatch, addresses []string, metadata []models.Metadata, key *models.IdempotencyKey) (models.EmailBatch, error) {
+	normalized := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		normalized = append(normalized, utils.NormalizeEmail(address))
+	}
+
+	suppressions, err := u.suppressionRepository.ReadSuppressionsByEmails(ctx, u.db, batch.WorkspaceId, normalized)
+	if err != nil {
+		return models.EmailBatch{}, fmt.Errorf("unable to check suppression list, err: %s", err.Error())
+	}
+
+	suppressed := map[string]models.Suppression{}
+	for _, item := range suppressions {
+		suppressed[item.Email] = item
+	}
+
+	sendable := 0
+	for _, address := range normalized {
+		if _, ok := suppressed[address]; !ok {
+			sendable++
+		}
+	}
 
+	from, err := u.clients.from(ctx, batch)
+	if err != nil {
+		return models.EmailBatch{}, fmt.Errorf("unable to read sender of batch, err: %s", err.Error())
+	}
+
+	batch.Id = uuid.NewV4().String()
+	batch.From = from
+	batch.EmailCount = len(addresses)
+	batch.CreatedAt = time.Now()
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		// the quotas stay locked until the emails charged to them are stored
+		slots, err := u.planBatch(ctx, tx, batch, sendable)
+		if err != nil {
+			return err
+		}
+
+		if err := u.repository.CreateBatch(ctx, tx, batch); err != nil {
+			return err
+		}
+
+		if key != nil {
+			// a concurrent request with the same key waits here until the other one commits
+			stored := *key
+			stored.BatchId = batch.Id
+			err := u.repository.CreateIdempotencyKey(ctx, tx, stored)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errIdempotencyKeyTaken
+			}
 			if err != nil {
-				log.Printf("fail to send email: %s", err.Error())
-				// TODO: LOG THIS TO DATABASE
+				return err
 			}
 		}
-	}()
 
-	return batchId, http.StatusAccepted, nil
+		// position in the quota windows, the recipients fill them in order
+		slot, planned := 0, 0
+
+		for index, address := range addresses {
+			recipient := models.Email{
+				BatchId:     batch.Id,
+				WorkspaceId: batch.WorkspaceId,
+				Email:       address,
+				Status:      models.EmailStatusPending,
+			}
+
+			if metadata != nil {
+				recipient.Metadata = metadata[index]
+			}
+
+			if item, ok := suppressed[normalized[index]]; ok {
+				reason := fmt.Sprintf("suppressed: %s", item.Reason)
+				recipient.Status = models.EmailStatusSuppressed
+				recipient.Log = &reason
+				batch.SuppressedCount++
+			} else {
+				for planned == slots[slot].Count {
+					slot, planned = slot+1, 0
+				}
+
+				recipient.QuotaAt = &slots[slot].At
+				planned++
+			}
+
+			if _, err := u.repository.CreateEmail(ctx, tx, recipient); err != nil {
+				return err
+			}
+		}
+
+		if err := u.repository.UpdateBatch(ctx, tx, batch); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceBatch, batch.Id, nil, batch)
+	})
+	if err != nil {
+		return models.EmailBatch{}, err
+	}
+
+	return batch, nil
+}
+
+// planBatch charges the emails of a batch that will be sent to its sending quotas, the quotas are locked in tx
+// the first window is the one the batch starts in, the rest wait for the worker to reach them
+func (u Usecase) planBatch(ctx context.Context, tx *sqlx.Tx, batch models.EmailBatch, count int) ([]quota.Slot, error) {
+	start := time.Now()
+	if batch.SendAt != nil && batch.SendAt.After(start) {
+		start = *batch.SendAt
+	}
+
+	var apiKeyId, senderId string
+	if batch.ApiKeyId != nil {
+		apiKeyId = *batch.ApiKeyId
+	}
+	if batch.SenderId != nil {
+		senderId = *batch.SenderId
+	}
+
+	return u.planner.Plan(ctx, tx, batch.WorkspaceId, apiKeyId, senderId, start, count)
 }
 
-func NewUsecase(db *sqlx.DB, repository EmailRepository, emailClient EmailClient) EmailUsecase {
+// replanBatch charges the pending emails of a batch to the quota windows from its new send time
+// their old windows are given back first so the batch is not charged twice
+func (u Usecase) replanBatch(ctx context.Context, tx *sqlx.Tx, batch models.EmailBatch) error {
+	emailIds, err := u.repository.ClearEmailsQuotaAt(ctx, tx, batch.WorkspaceId, batch.Id)
+	if err != nil {
+		return err
+	}
+
+	slots, err := u.planBatch(ctx, tx, batch, len(emailIds))
+	if err != nil {
+		return err
+	}
+
+	// the recipients fill the windows in order
+	start := 0
+	for _, slot := range slots {
+		end := min(start+slot.Count, len(emailIds))
+		if err := u.repository.UpdateEmailsQuotaAt(ctx, tx, batch.WorkspaceId, emailIds[start:end], slot.At); err != nil {
+			return err
+		}
+		start = end
+	}
+
+	return nil
+}
+
+// copyBatch keeps the content and options of a batch, everything about how it was sent is left out
+func copyBatch(batch models.EmailBatch) models.EmailBatch {
+	return models.EmailBatch{
+		Subject:       batch.Subject,
+		Body:          batch.Body,
+		Template:      batch.Template,
+		TrackOpens:    batch.TrackOpens,
+		TrackClicks:   batch.TrackClicks,
+		UtmParams:     batch.UtmParams,
+		WebhookUrl:    batch.WebhookUrl,
+		WebhookSecret: batch.WebhookSecret,
+		ReportTo:      batch.ReportTo,
+		SenderId:      batch.SenderId,
+	}
+}
+
+// moveBatch changes the status of a batch, conflict when it is not in one of from
+// the change is audited as action
+func (u Usecase) moveBatch(ctx context.Context, batchId string, action string, from []string, to string) (models.EmailBatch, int, error) {
+	batch, statusCode, err := u.readBatch(ctx, batchId)
+	if err != nil {
+		return models.EmailBatch{}, statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batchId, from, to); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, action, models.AuditResourceBatch, batchId, batchStatus(batch.Status), batchStatus(to))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusConflict, fmt.Errorf("batch: %s is %s", batchId, batch.Status)
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	return u.readBatch(ctx, batchId)
+}
+
+// batchStatus is the audited state of a batch whose status alone changes
+func batchStatus(status string) map[string]any {
+	return map[string]any{"status": status}
+}
+
+// readBatch reads a batch of the caller's workspace and maps a missing one to not found
+func (u Usecase) readBatch(ctx context.Context, batchId string) (models.EmailBatch, int, error) {
+	batch, err := u.repository.ReadBatch(ctx, u.db, apikey.WorkspaceFromContext(ctx), batchId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailBatch{}, http.StatusNotFound, fmt.Errorf("batch: %s not found", batchId)
+	}
+	if err != nil {
+		return models.EmailBatch{}, http.StatusInternalServerError, err
+	}
+
+	return batch, http.StatusOK, nil
+}
+
+// sendRecipient renders the content for a single recipient and sends it
+func (u Usecase) sendRecipient(client Sender, batch models.EmailBatch, recipient models.Email, content compiledContent) error {
+	unsubscribeURL := suppression.UnsubscribeURL(u.cfg, batch.Id, recipient.Email)
+
+	message, err := content.render(TemplateData{
+		UnsubscribeURL: unsubscribeURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	if utm := batch.UtmParams.Values(); len(utm) > 0 && message.Template != "" {
+		message.Template, err = rewriteLinks(message.Template, func(href string, attributes []xhtml.Attribute) (string, bool) {
+			if !isWebLink(href) || href == unsubscribeURL {
+				return "", false
+			}
+
+			return appendQuery(href, utm)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if batch.TrackClicks && message.Template != "" {
+		message.Template, err = rewriteLinks(message.Template, func(href string, attributes []xhtml.Attribute) (string, bool) {
+			// the unsubscribe link must keep working on its own and is not a click we want to count
+			if !isWebLink(href) || href == unsubscribeURL || hasAttribute(attributes, notrackAttribute) {
+				return "", false
+			}
+
+			clickURL := tracking.ClickURL(u.cfg, recipient.Id, strings.TrimSpace(href))
+			return clickURL, clickURL != ""
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if batch.TrackOpens && message.Template != "" {
+		if pixelURL := tracking.OpenPixelURL(u.cfg, recipient.Id); pixelURL != "" {
+			message.Template = injectOpenPixel(message.Template, pixelURL)
+		}
+	}
+
+	message.To = recipient.Email
+	message.ReturnPath = u.returnPath(recipient.Id)
+	message.Headers = map[string]string{}
+
+	// bounces quote the original Message-ID, which is how they are matched back to this email
+	if recipient.MessageId != nil {
+		message.Headers["Message-ID"] = *recipient.MessageId
+	}
+
+	// one click unsubscribe as required by RFC 8058 for bulk senders
+	if unsubscribeURL != "" {
+		message.Headers["List-Unsubscribe"] = "<" + unsubscribeURL + ">"
+		message.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	return client.SendMail(message)
+}
+
+// newMessageId builds a globally unique Message-ID on the sender domain of the batch
+func (u Usecase) newMessageId(batch models.EmailBatch, emailId int) string {
+	_, domain := utils.SplitAddress(batch.From)
+
+	return fmt.Sprintf("<%s.%d@%s>", batch.Id, emailId, domain)
+}
+
+// returnPath is the envelope sender for an email, it is empty unless VERP is enabled
+// without a token secret the address cannot be signed, bounces then fall back to the Message-ID
+func (u Usecase) returnPath(emailId int) string {
+	if !u.cfg.Verp.Enabled || u.cfg.TokenSecret == "" {
+		return ""
+	}
+
+	return utils.VerpAddress(u.cfg.TokenSecret, u.cfg.Verp.Prefix, u.cfg.Verp.Domain, emailId)
+}
+
+func NewUsecase(db *sqlx.DB, repository EmailRepository, suppressionRepository suppression.SuppressionRepository, planner quota.Planner, recorder audit.Recorder, clients *ClientPool, cfg config.Config, observers ...BatchObserver) EmailUsecase {
+	// the hub follows the batches for the event streams
+	hub := newHub()
+	observers = append(observers, hub)
+
 	return Usecase{
-		db:          db,
-		repository:  repository,
-		emailClient: emailClient,
+		db:                    db,
+		repository:            repository,
+		suppressionRepository: suppressionRepository,
+		planner:               planner,
+		recorder:              recorder,
+		clients:               clients,
+		cfg:                   cfg,
+		workers:               newWorkers(),
+		observers:             observers,
+		hub:                   hub,
 	}
 }
@@ -0,0 +1,353 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+const defaultProgressInterval = time.Minute
+
+// workers keeps one worker per batch in this process
+// a batch resumed while its previous worker is still winding down is handed to that worker instead of a second one
+type workers struct {
+	mu      sync.Mutex
+	running map[string]bool
+	again   map[string]bool
+}
+
+func newWorkers() *workers {
+	return &workers{
+		running: map[string]bool{},
+		again:   map[string]bool{},
+	}
+}
+
+// start reports whether the caller should run the batch
+func (w *workers) start(batchId string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running[batchId] {
+		w.again[batchId] = true
+		return false
+	}
+
+	w.running[batchId] = true
+	return true
+}
+
+// done reports whether the batch was queued again while it ran
+func (w *workers) done(batchId string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.again[batchId] {
+		delete(w.again, batchId)
+		return false
+	}
+
+	delete(w.running, batchId)
+	return true
+}
+
+// runBatch sends the pending emails of a queued batch, it runs in the background so it uses its own context
+func (u Usecase) runBatch(batch models.EmailBatch) {
+	if !u.workers.start(batch.Id) {
+		return
+	}
+
+	for {
+		u.sendBatch(batch.WorkspaceId, batch.Id)
+
+		if u.workers.done(batch.Id) {
+			return
+		}
+	}
+}
+
+// sendBatch claims a queued batch and sends its pending emails
+// the status is checked between recipients so a pause or cancel takes effect after the email being sent
+func (u Usecase) sendBatch(workspaceId string, batchId string) {
+	ctx := context.Background()
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		return u.repository.UpdateBatchStatus(ctx, tx, workspaceId, batchId, []string{models.BatchStatusQueued}, models.BatchStatusSending)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("fail to claim batch: %s, err: %s", batchId, err.Error())
+		return
+	}
+
+	batch, err := u.repository.ReadBatch(ctx, u.db, workspaceId, batchId)
+	if err != nil {
+		log.Printf("fail to read batch: %s, err: %s", batchId, err.Error())
+		return
+	}
+
+	// a resumed batch has already started
+	if batch.StartAt == nil {
+		startAt := time.Now()
+		batch.StartAt = &startAt
+
+		err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+			return u.repository.StartBatch(ctx, tx, batch.WorkspaceId, batch.Id, startAt)
+		})
+		if err != nil {
+			log.Printf("fail to update batch: %s, err: %s", batch.Id, err.Error())
+		}
+
+		u.notify(batch.Id, func(observer BatchObserver) error {
+			return observer.BatchStarted(ctx, batch)
+		})
+	}
+
+	// the emails a sending quota holds back wait for a later run
+	dueBy := time.Now()
+	recipients, err := u.repository.ReadEmail(ctx, u.db, batch.WorkspaceId, models.GetEmailRequest{
+		BatchId: batch.Id,
+		Status:  models.EmailStatusPending,
+		DueBy:   &dueBy,
+	})
+	if err != nil {
+		log.Printf("fail to read emails of batch: %s, err: %s", batch.Id, err.Error())
+		return
+	}
+
+	// an address suppressed after the batch was accepted is not mailed either
+	recipients, err = u.dropSuppressed(ctx, batch, recipients)
+	if err != nil {
+		log.Printf("fail to check suppression list of batch: %s, err: %s", batch.Id, err.Error())
+		return
+	}
+
+	// the content was checked when the batch was accepted
+	content, compileErr := compileContent(Email{
+		Subject:  batch.Subject,
+		Body:     batch.Body,
+		Template: batch.Template,
+	})
+
+	// recipients fail with the reason when the batch cannot connect, as they would on a send error
+	client, clientErr := u.clients.get(ctx, batch)
+	if clientErr != nil {
+		log.Printf("fail to connect smtp client of batch: %s, err: %s", batch.Id, clientErr.Error())
+	}
+
+	// left sending when the status cannot be read, the batch is resumed on the next start
+	complete := true
+	lastProgress := time.Now()
+
+	for _, recipient := range recipients {
+		current, err := u.repository.ReadBatch(ctx, u.db, batch.WorkspaceId, batch.Id)
+		if err != nil {
+			log.Printf("fail to read batch: %s, err: %s", batch.Id, err.Error())
+			complete = false
+			break
+		}
+
+		if current.Status != models.BatchStatusSending {
+			log.Printf("batch: %s is %s, stopping", batch.Id, current.Status)
+			break
+		}
+
+		if len(u.observers) > 0 && time.Since(lastProgress) >= u.progressInterval() {
+			lastProgress = time.Now()
+			u.notifyProgress(ctx, batch)
+		}
+
+		messageId := u.newMessageId(batch, recipient.Id)
+		recipient.MessageId = &messageId
+
+		err = compileErr
+		if err == nil {
+			err = clientErr
+		}
+		if err == nil {
+			err = u.sendRecipient(client, batch, recipient, content)
+		}
+
+		now := time.Now()
+		if err != nil {
+			log.Printf("fail to send email: %s", err.Error())
+
+			message := err.Error()
+			recipient.Status = models.EmailStatusFailed
+			recipient.Log = &message
+
+			u.notify(batch.Id, func(observer BatchObserver) error {
+				return observer.EmailFailed(ctx, batch, recipient)
+			})
+		} else {
+			recipient.Status = models.EmailStatusSent
+			recipient.IsSent = true
+			recipient.SentAt = &now
+		}
+
+		err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+			return u.repository.UpdateEmail(ctx, tx, recipient)
+		})
+		if err != nil {
+			log.Printf("fail to update email: %d, err: %s", recipient.Id, err.Error())
+		}
+
+		if recipient.IsSent {
+			u.notify(batch.Id, func(observer BatchObserver) error {
+				return observer.EmailSent(ctx, batch, recipient)
+			})
+		}
+	}
+
+	var deferUntil *time.Time
+	if complete {
+		deferUntil, err = u.repository.ReadNextQuotaAt(ctx, u.db, batch.WorkspaceId, batch.Id)
+		if err != nil {
+			log.Printf("fail to read quota window of batch: %s, err: %s", batch.Id, err.Error())
+			complete = false
+		}
+	}
+
+	completed := false
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateBatchCounts(ctx, tx, batch.WorkspaceId, batch.Id); err != nil {
+			return err
+		}
+
+		if !complete {
+			return nil
+		}
+
+		// the rest is sent once the scheduler releases the batch in the next window
+		if deferUntil != nil {
+			err := u.repository.DeferBatch(ctx, tx, batch.WorkspaceId, batch.Id, *deferUntil)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		// a paused or canceled batch keeps its status
+		err := u.repository.UpdateBatchStatus(ctx, tx, batch.WorkspaceId, batch.Id, []string{models.BatchStatusSending}, models.BatchStatusCompleted)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		completed = true
+		return u.repository.FinishBatch(ctx, tx, batch.WorkspaceId, batch.Id, time.Now())
+	})
+	if err != nil {
+		log.Printf("fail to update batch: %s, err: %s", batch.Id, err.Error())
+		return
+	}
+
+	if completed && len(u.observers) > 0 {
+		batch, err := u.repository.ReadBatch(ctx, u.db, batch.WorkspaceId, batch.Id)
+		if err != nil {
+			log.Printf("fail to read batch: %s, err: %s", batch.Id, err.Error())
+			return
+		}
+
+		u.notify(batch.Id, func(observer BatchObserver) error {
+			return observer.BatchCompleted(ctx, batch)
+		})
+	}
+}
+
+// dropSuppressed marks the recipients on the suppression list of the workspace as suppressed and returns the others
+// the counts of the batch are refreshed when the run ends
+func (u Usecase) dropSuppressed(ctx context.Context, batch models.EmailBatch, recipients []models.Email) ([]models.Email, error) {
+	if len(recipients) == 0 {
+		return recipients, nil
+	}
+
+	normalized := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		normalized = append(normalized, utils.NormalizeEmail(recipient.Email))
+	}
+
+	suppressions, err := u.suppressionRepository.ReadSuppressionsByEmails(ctx, u.db, batch.WorkspaceId, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if len(suppressions) == 0 {
+		return recipients, nil
+	}
+
+	suppressed := map[string]models.Suppression{}
+	for _, item := range suppressions {
+		suppressed[item.Email] = item
+	}
+
+	sendable := make([]models.Email, 0, len(recipients))
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		sendable = sendable[:0]
+
+		for index, recipient := range recipients {
+			item, ok := suppressed[normalized[index]]
+			if !ok {
+				sendable = append(sendable, recipient)
+				continue
+			}
+
+			reason := fmt.Sprintf("suppressed: %s", item.Reason)
+			recipient.Status = models.EmailStatusSuppressed
+			recipient.Log = &reason
+
+			if err := u.repository.UpdateEmail(ctx, tx, recipient); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sendable, nil
+}
+
+// notifyProgress refreshes the counts of a sending batch and hands it to the observers
+func (u Usecase) notifyProgress(ctx context.Context, batch models.EmailBatch) {
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		return u.repository.UpdateBatchCounts(ctx, tx, batch.WorkspaceId, batch.Id)
+	})
+	if err != nil {
+		log.Printf("fail to update batch: %s, err: %s", batch.Id, err.Error())
+		return
+	}
+
+	batch, err = u.repository.ReadBatch(ctx, u.db, batch.WorkspaceId, batch.Id)
+	if err != nil {
+		log.Printf("fail to read batch: %s, err: %s", batch.Id, err.Error())
+		return
+	}
+
+	u.notify(batch.Id, func(observer BatchObserver) error {
+		return observer.BatchProgress(ctx, batch)
+	})
+}
+
+func (u Usecase) progressInterval() time.Duration {
+	if u.cfg.Webhook.ProgressInterval > 0 {
+		return u.cfg.Webhook.ProgressInterval
+	}
+
+	return defaultProgressInterval
+}
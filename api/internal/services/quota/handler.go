@@ -0,0 +1,128 @@
+package quota
+
+import (
+	"net/http"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase QuotaUsecase
+}
+
+// GetQuotas implements QuotaHandler.
+func (h Handler) GetQuotas(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetQuotas(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// GetQuota implements QuotaHandler.
+func (h Handler) GetQuota(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetQuota(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostQuota implements QuotaHandler.
+func (h Handler) PostQuota(e echo.Context) error {
+	var request models.PostQuotaRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateQuota(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PutQuota implements QuotaHandler.
+func (h Handler) PutQuota(e echo.Context) error {
+	var request models.PutQuotaRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.UpdateQuota(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// DeleteQuota implements QuotaHandler.
+func (h Handler) DeleteQuota(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.DeleteQuota(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+func NewHandler(usecase QuotaUsecase) QuotaHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
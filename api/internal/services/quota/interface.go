@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// caps on how many emails an api key, a workspace or a sender may send per hour, day or month
+// the email service charges a batch to the quotas when it is accepted, this service only manages them
+
+type QuotaHandler interface {
+	GetQuotas(e echo.Context) error
+	GetQuota(e echo.Context) error
+	PostQuota(e echo.Context) error
+	PutQuota(e echo.Context) error
+	DeleteQuota(e echo.Context) error
+}
+
+type QuotaUsecase interface {
+	GetQuotas(ctx context.Context) ([]models.Quota, int, error)
+	GetQuota(ctx context.Context, id string) (models.Quota, int, error)
+	CreateQuota(ctx context.Context, param models.PostQuotaRequest) (models.Quota, int, error)
+	UpdateQuota(ctx context.Context, id string, param models.PutQuotaRequest) (models.Quota, int, error)
+	DeleteQuota(ctx context.Context, id string) (int, error)
+}
+
+type QuotaRepository interface {
+	CreateQuota(ctx context.Context, tx *sqlx.Tx, param models.Quota) error
+	ReadQuota(ctx context.Context, db *sqlx.DB, workspaceId string, id string) (models.Quota, error)
+	ReadQuotas(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Quota, error)
+	// LockBatchQuotas returns the quotas a batch of the workspace sent with the api key and sender is charged to, either may be empty
+	// they stay locked until tx ends, so batches charged to the same quota are planned one after the other
+	LockBatchQuotas(ctx context.Context, tx *sqlx.Tx, workspaceId string, apiKeyId string, senderId string) ([]models.Quota, error)
+	// ReadUsage counts the emails charged to the quota in [start, end), db is the transaction the quota is locked in when planning
+	ReadUsage(ctx context.Context, db sqlx.QueryerContext, quota models.Quota, start time.Time, end time.Time) (int, error)
+	// ScopeExists reports whether the api key or sender a quota names belongs to the workspace
+	ScopeExists(ctx context.Context, db *sqlx.DB, workspaceId string, scope string, scopeId string) (bool, error)
+	UpdateQuota(ctx context.Context, tx *sqlx.Tx, param models.Quota) error
+	DeleteQuota(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string) error
+}
@@ -0,0 +1,24 @@
+package quota
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+func Load(e *echo.Echo, db *sqlx.DB, authorize apikey.Authorizer) {
+
+	// init repository
+	repository := NewRepository()
+	recorder := audit.NewRecorder(audit.NewRepository())
+
+	// init usecase
+	usecase := NewUsecase(db, repository, recorder)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler, authorize)
+}
@@ -0,0 +1,163 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// a batch spread further than this is refused, a monthly quota of one email would otherwise plan for centuries
+const maxPlannedWindows = 10000
+
+// ErrTooLarge is returned for a batch that would take too many windows to send
+var ErrTooLarge = errors.New("batch does not fit the sending quotas")
+
+// Slot is the share of a batch planned in a window, the emails are not sent before At
+type Slot struct {
+	At    time.Time
+	Count int
+}
+
+// ExceededError is returned when a batch cannot start because a quota is used up
+type ExceededError struct {
+	// the quotas that have nothing left, with their allowance filled
+	Quotas []models.Quota
+}
+
+func (e ExceededError) Error() string {
+	parts := make([]string, 0, len(e.Quotas))
+	for _, quota := range e.Quotas {
+		parts = append(parts, fmt.Sprintf("%s quota: %d of %d emails per %s left, resets at %s",
+			quota.Scope, *quota.Remaining, quota.Limit, quota.Period, quota.ResetAt.Format(time.RFC3339)))
+	}
+
+	return "sending quota exceeded, " + strings.Join(parts, ", ")
+}
+
+// Planner spreads batches over the windows of their quotas
+type Planner struct {
+	db         *sqlx.DB
+	repository QuotaRepository
+}
+
+func NewPlanner(db *sqlx.DB, repository QuotaRepository) Planner {
+	return Planner{
+		db:         db,
+		repository: repository,
+	}
+}
+
+// Plan returns the windows count emails of a batch starting at start are sent in
+// a batch larger than what is left now gets what is left, the rest moves on to the following windows
+// ExceededError when a quota has nothing left at start
+// the quotas are locked in tx, the emails have to be stored in it with the windows they were planned in
+func (p Planner) Plan(ctx context.Context, tx *sqlx.Tx, workspaceId string, apiKeyId string, senderId string, start time.Time, count int) ([]Slot, error) {
+	quotas, err := p.repository.LockBatchQuotas(ctx, tx, workspaceId, apiKeyId, senderId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(quotas) == 0 || count == 0 {
+		return []Slot{{At: start, Count: count}}, nil
+	}
+
+	// emails charged to each window, planned ones included
+	usage := map[string]int{}
+	used := func(quota models.Quota, at time.Time) (int, time.Time, error) {
+		windowStart, windowEnd := quota.Window(at)
+
+		key := quota.Id + windowStart.Format(time.RFC3339)
+		if _, ok := usage[key]; !ok {
+			charged, err := p.repository.ReadUsage(ctx, tx, quota, windowStart, windowEnd)
+			if err != nil {
+				return 0, time.Time{}, err
+			}
+			usage[key] = charged
+		}
+
+		return usage[key], windowEnd, nil
+	}
+
+	slots := []Slot{}
+	left := count
+	at := start
+
+	for window := 0; left > 0; window++ {
+		if window == maxPlannedWindows {
+			return nil, fmt.Errorf("%w, %d emails would take more than %d windows", ErrTooLarge, count, maxPlannedWindows)
+		}
+
+		allowed := left
+		remaining := make([]int, len(quotas))
+		ends := make([]time.Time, len(quotas))
+		for i, quota := range quotas {
+			charged, end, err := used(quota, at)
+			if err != nil {
+				return nil, err
+			}
+
+			remaining[i] = max(quota.Limit-charged, 0)
+			ends[i] = end
+			allowed = min(allowed, remaining[i])
+		}
+
+		if window == 0 && allowed == 0 {
+			exceeded := ExceededError{}
+			for i, quota := range quotas {
+				if remaining[i] == 0 {
+					quota.Remaining = &remaining[i]
+					quota.ResetAt = &ends[i]
+					exceeded.Quotas = append(exceeded.Quotas, quota)
+				}
+			}
+
+			return nil, exceeded
+		}
+
+		if allowed > 0 {
+			slots = append(slots, Slot{At: at, Count: allowed})
+			left -= allowed
+
+			for _, quota := range quotas {
+				windowStart, _ := quota.Window(at)
+				usage[quota.Id+windowStart.Format(time.RFC3339)] += allowed
+			}
+		}
+
+		// on to the earliest window that frees up one of the quotas that ran out
+		var next time.Time
+		for i := range quotas {
+			if remaining[i] > allowed {
+				continue
+			}
+
+			if next.IsZero() || ends[i].Before(next) {
+				next = ends[i]
+			}
+		}
+		at = next
+	}
+
+	return slots, nil
+}
+
+// Allowance fills what is left of the quota in the current window
+func (p Planner) Allowance(ctx context.Context, quota models.Quota, now time.Time) (models.Quota, error) {
+	start, end := quota.Window(now)
+
+	used, err := p.repository.ReadUsage(ctx, p.db, quota, start, end)
+	if err != nil {
+		return models.Quota{}, err
+	}
+
+	remaining := max(quota.Limit-used, 0)
+	quota.Remaining = &remaining
+	quota.ResetAt = &end
+
+	return quota, nil
+}
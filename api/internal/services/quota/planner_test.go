@@ -0,0 +1,162 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeRepository serves the quotas of a batch and the emails already charged to each window
+type fakeRepository struct {
+	QuotaRepository
+
+	quotas []models.Quota
+	// emails charged per quota id and window start
+	usage map[string]int
+}
+
+func (r fakeRepository) LockBatchQuotas(ctx context.Context, tx *sqlx.Tx, workspaceId string, apiKeyId string, senderId string) ([]models.Quota, error) {
+	return r.quotas, nil
+}
+
+func (r fakeRepository) ReadUsage(ctx context.Context, db sqlx.QueryerContext, quota models.Quota, start time.Time, end time.Time) (int, error) {
+	return r.usage[quota.Id+start.Format(time.RFC3339)], nil
+}
+
+func TestPlan(t *testing.T) {
+	start := time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC)
+	hour := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	hourly := models.Quota{Id: "hourly", Scope: models.QuotaScopeApiKey, Period: models.QuotaPeriodHour, Limit: 100}
+	daily := models.Quota{Id: "daily", Scope: models.QuotaScopeWorkspace, Period: models.QuotaPeriodDay, Limit: 250}
+	monthly := models.Quota{Id: "monthly", Scope: models.QuotaScopeSender, Period: models.QuotaPeriodMonth, Limit: 1}
+
+	tests := []struct {
+		name     string
+		quotas   []models.Quota
+		usage    map[string]int
+		count    int
+		want     []Slot
+		exceeded []string
+		tooLarge bool
+	}{
+		{
+			name:  "no quota sends everything now",
+			count: 500,
+			want:  []Slot{{At: start, Count: 500}},
+		},
+		{
+			name:   "a batch that fits is sent now",
+			quotas: []models.Quota{hourly},
+			count:  40,
+			want:   []Slot{{At: start, Count: 40}},
+		},
+		{
+			name:   "the rest moves on to the following windows",
+			quotas: []models.Quota{hourly},
+			usage:  map[string]int{"hourly" + hour.Format(time.RFC3339): 60},
+			count:  180,
+			want: []Slot{
+				{At: start, Count: 40},
+				{At: hour.Add(time.Hour), Count: 100},
+				{At: hour.Add(2 * time.Hour), Count: 40},
+			},
+		},
+		{
+			name:   "the tightest quota decides each window",
+			quotas: []models.Quota{hourly, daily},
+			usage:  map[string]int{"daily" + day.Format(time.RFC3339): 200},
+			count:  120,
+			want: []Slot{
+				{At: start, Count: 50},
+				{At: day.AddDate(0, 0, 1), Count: 70},
+			},
+		},
+		{
+			name:     "a used up quota refuses the batch",
+			quotas:   []models.Quota{hourly, daily},
+			usage:    map[string]int{"hourly" + hour.Format(time.RFC3339): 100},
+			count:    10,
+			exceeded: []string{"hourly"},
+		},
+		{
+			name:     "a batch spread over too many windows is refused",
+			quotas:   []models.Quota{monthly},
+			count:    maxPlannedWindows + 1,
+			tooLarge: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			planner := NewPlanner(nil, fakeRepository{quotas: test.quotas, usage: test.usage})
+
+			slots, err := planner.Plan(context.Background(), nil, "workspace", "key", "sender", start, test.count)
+
+			if test.tooLarge {
+				if !errors.Is(err, ErrTooLarge) {
+					t.Fatalf("Plan() error = %v, want ErrTooLarge", err)
+				}
+				return
+			}
+
+			if test.exceeded != nil {
+				var exceeded ExceededError
+				if !errors.As(err, &exceeded) {
+					t.Fatalf("Plan() error = %v, want ExceededError", err)
+				}
+
+				if len(exceeded.Quotas) != len(test.exceeded) {
+					t.Fatalf("exceeded quotas = %+v, want %v", exceeded.Quotas, test.exceeded)
+				}
+
+				for i, quota := range exceeded.Quotas {
+					if quota.Id != test.exceeded[i] || quota.Remaining == nil || *quota.Remaining != 0 || quota.ResetAt == nil {
+						t.Errorf("exceeded quota %d = %+v", i, quota)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+
+			if len(slots) != len(test.want) {
+				t.Fatalf("Plan() = %+v, want %+v", slots, test.want)
+			}
+
+			for i, want := range test.want {
+				if !slots[i].At.Equal(want.At) || slots[i].Count != want.Count {
+					t.Errorf("slot %d = %+v, want %+v", i, slots[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	at := time.Date(2024, 2, 29, 23, 45, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	tests := []struct {
+		period string
+		start  time.Time
+		end    time.Time
+	}{
+		{models.QuotaPeriodHour, time.Date(2024, 2, 29, 21, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 22, 0, 0, 0, time.UTC)},
+		{models.QuotaPeriodDay, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{models.QuotaPeriodMonth, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		start, end := models.Quota{Period: test.period}.Window(at)
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("%s window = [%s, %s), want [%s, %s)", test.period, start, end, test.start, test.end)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const quotaColumns = `id, workspace_id, scope, scope_id, period, max_emails, created_at, updated_at`
+
+type Repository struct {
+}
+
+// CreateQuota implements QuotaRepository.
+func (r Repository) CreateQuota(ctx context.Context, tx *sqlx.Tx, param models.Quota) error {
+	query := `
+		INSERT INTO quotas (` + quotaColumns + `)
+		VALUES (:id, :workspace_id, :scope, :scope_id, :period, :max_emails, :created_at, :updated_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadQuota implements QuotaRepository.
+func (r Repository) ReadQuota(ctx context.Context, db *sqlx.DB, workspaceId string, id string) (models.Quota, error) {
+	var result models.Quota
+
+	query := `SELECT ` + quotaColumns + ` FROM quotas WHERE id = $1 AND workspace_id = $2`
+	err := db.GetContext(ctx, &result, query, id, workspaceId)
+
+	return result, err
+}
+
+// ReadQuotas implements QuotaRepository.
+func (r Repository) ReadQuotas(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.Quota, error) {
+	results := []models.Quota{}
+
+	query := `SELECT ` + quotaColumns + ` FROM quotas WHERE workspace_id = $1 ORDER BY scope, scope_id, period`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// LockBatchQuotas implements QuotaRepository.
+// the rows are locked in id order so two batches sharing quotas cannot deadlock
+func (r Repository) LockBatchQuotas(ctx context.Context, tx *sqlx.Tx, workspaceId string, apiKeyId string, senderId string) ([]models.Quota, error) {
+	results := []models.Quota{}
+
+	query := `
+		SELECT ` + quotaColumns + ` FROM quotas
+		WHERE workspace_id = $1 AND (
+			(scope = $2 AND scope_id = $1)
+			OR ($4 <> '' AND scope = $3 AND scope_id = $4)
+			OR ($6 <> '' AND scope = $5 AND scope_id = $6)
+		)
+		ORDER BY id
+		FOR UPDATE`
+	err := tx.SelectContext(ctx, &results, query, workspaceId,
+		models.QuotaScopeWorkspace, models.QuotaScopeApiKey, apiKeyId, models.QuotaScopeSender, senderId)
+
+	return results, err
+}
+
+// ReadUsage implements QuotaRepository.
+// suppressed and canceled emails are never sent and are not charged
+func (r Repository) ReadUsage(ctx context.Context, db sqlx.QueryerContext, quota models.Quota, start time.Time, end time.Time) (int, error) {
+	var scope string
+	switch quota.Scope {
+	case models.QuotaScopeWorkspace:
+		scope = `e.workspace_id = $1`
+	case models.QuotaScopeApiKey:
+		scope = `b.api_key_id = $1`
+	case models.QuotaScopeSender:
+		scope = `b.sender_id = $1`
+	default:
+		return 0, fmt.Errorf("unknown quota scope: %s", quota.Scope)
+	}
+
+	var count int
+
+	query := `
+		SELECT COUNT(*) FROM emails e
+		JOIN email_batches b ON b.id = e.batch_id
+		WHERE ` + scope + ` AND e.workspace_id = $2 AND e.quota_at >= $3 AND e.quota_at < $4 AND e.status <> ALL($5)`
+	err := sqlx.GetContext(ctx, db, &count, query, quota.ScopeId, quota.WorkspaceId, start, end,
+		pq.Array([]string{models.EmailStatusSuppressed, models.EmailStatusCanceled}))
+
+	return count, err
+}
+
+// ScopeExists implements QuotaRepository.
+func (r Repository) ScopeExists(ctx context.Context, db *sqlx.DB, workspaceId string, scope string, scopeId string) (bool, error) {
+	var query string
+	switch scope {
+	case models.QuotaScopeApiKey:
+		query = `SELECT EXISTS (SELECT 1 FROM api_keys WHERE id = $1 AND workspace_id = $2)`
+	case models.QuotaScopeSender:
+		query = `SELECT EXISTS (SELECT 1 FROM senders WHERE id = $1 AND workspace_id = $2)`
+	default:
+		return scopeId == workspaceId, nil
+	}
+
+	var exists bool
+	err := db.GetContext(ctx, &exists, query, scopeId, workspaceId)
+
+	return exists, err
+}
+
+// UpdateQuota implements QuotaRepository.
+func (r Repository) UpdateQuota(ctx context.Context, tx *sqlx.Tx, param models.Quota) error {
+	query := `
+		UPDATE quotas
+		SET max_emails = :max_emails, updated_at = :updated_at
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteQuota implements QuotaRepository.
+func (r Repository) DeleteQuota(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM quotas WHERE id = $1 AND workspace_id = $2`, id, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func requireAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func NewRepository() QuotaRepository {
+	return Repository{}
+}
@@ -0,0 +1,15 @@
+package quota
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler QuotaHandler, authorize apikey.Authorizer) {
+	e.GET("/quota", handler.GetQuotas, authorize(models.ScopeAdmin))
+	e.POST("/quota", handler.PostQuota, authorize(models.ScopeAdmin))
+	e.GET("/quota/:id", handler.GetQuota, authorize(models.ScopeAdmin))
+	e.PUT("/quota/:id", handler.PutQuota, authorize(models.ScopeAdmin))
+	e.DELETE("/quota/:id", handler.DeleteQuota, authorize(models.ScopeAdmin))
+}
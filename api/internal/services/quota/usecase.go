@@ -0,0 +1,187 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository QuotaRepository
+	planner    Planner
+	recorder   audit.Recorder
+}
+
+// GetQuotas implements QuotaUsecase.
+func (u Usecase) GetQuotas(ctx context.Context) ([]models.Quota, int, error) {
+	results, err := u.repository.ReadQuotas(ctx, u.db, apikey.WorkspaceFromContext(ctx))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	now := time.Now()
+	for i, result := range results {
+		results[i], err = u.planner.Allowance(ctx, result, now)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetQuota implements QuotaUsecase.
+func (u Usecase) GetQuota(ctx context.Context, id string) (models.Quota, int, error) {
+	result, err := u.repository.ReadQuota(ctx, u.db, apikey.WorkspaceFromContext(ctx), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Quota{}, http.StatusNotFound, fmt.Errorf("quota: %s not found", id)
+	}
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	result, err = u.planner.Allowance(ctx, result, time.Now())
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// CreateQuota implements QuotaUsecase.
+func (u Usecase) CreateQuota(ctx context.Context, param models.PostQuotaRequest) (models.Quota, int, error) {
+	workspaceId := apikey.WorkspaceFromContext(ctx)
+
+	scopeId := param.ScopeId
+	if param.Scope == models.QuotaScopeWorkspace {
+		scopeId = workspaceId
+	}
+
+	if scopeId == "" {
+		return models.Quota{}, http.StatusBadRequest, fmt.Errorf("scope id is required for a %s quota", param.Scope)
+	}
+
+	exists, err := u.repository.ScopeExists(ctx, u.db, workspaceId, param.Scope, scopeId)
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return models.Quota{}, http.StatusBadRequest, fmt.Errorf("%s: %s not found", param.Scope, scopeId)
+	}
+
+	now := time.Now()
+	quota := models.Quota{
+		Id:          uuid.NewV4().String(),
+		WorkspaceId: workspaceId,
+		Scope:       param.Scope,
+		ScopeId:     scopeId,
+		Period:      param.Period,
+		Limit:       param.Limit,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateQuota(ctx, tx, quota); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceQuota, quota.Id, nil, quota)
+	})
+	if utils.IsUniqueViolation(err) {
+		return models.Quota{}, http.StatusConflict, fmt.Errorf("%s: %s already has a %s quota", quota.Scope, quota.ScopeId, quota.Period)
+	}
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	quota, err = u.planner.Allowance(ctx, quota, now)
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	return quota, http.StatusCreated, nil
+}
+
+// UpdateQuota implements QuotaUsecase.
+// a lower limit only applies to batches accepted from now on, the ones already planned keep their windows
+func (u Usecase) UpdateQuota(ctx context.Context, id string, param models.PutQuotaRequest) (models.Quota, int, error) {
+	quota, statusCode, err := u.GetQuota(ctx, id)
+	if err != nil {
+		return models.Quota{}, statusCode, err
+	}
+
+	before := quota
+	quota.Limit = param.Limit
+	quota.UpdatedAt = time.Now()
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateQuota(ctx, tx, quota); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceQuota, quota.Id, before, quota)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Quota{}, http.StatusNotFound, fmt.Errorf("quota: %s not found", id)
+	}
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	quota, err = u.planner.Allowance(ctx, quota, quota.UpdatedAt)
+	if err != nil {
+		return models.Quota{}, http.StatusInternalServerError, err
+	}
+
+	return quota, http.StatusOK, nil
+}
+
+// DeleteQuota implements QuotaUsecase.
+func (u Usecase) DeleteQuota(ctx context.Context, id string) (int, error) {
+	workspaceId := apikey.WorkspaceFromContext(ctx)
+
+	before, err := u.repository.ReadQuota(ctx, u.db, workspaceId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("quota: %s not found", id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteQuota(ctx, tx, workspaceId, id); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceQuota, id, before, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("quota: %s not found", id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+func NewUsecase(db *sqlx.DB, repository QuotaRepository, recorder audit.Recorder) QuotaUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+		planner:    NewPlanner(db, repository),
+		recorder:   recorder,
+	}
+}
@@ -1,13 +1,30 @@
 package services
 
 import (
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/services/bounce"
 	"github.com/abbyfakhri/toa-api/internal/services/email"
+	"github.com/abbyfakhri/toa-api/internal/services/quota"
+	"github.com/abbyfakhri/toa-api/internal/services/suppression"
+	"github.com/abbyfakhri/toa-api/internal/services/tracking"
+	"github.com/abbyfakhri/toa-api/internal/services/webhook"
+	"github.com/abbyfakhri/toa-api/internal/services/workspace"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 )
 
-func LoadServices(e *echo.Echo, db *sqlx.DB, emailClient email.EmailClient) {
-	email.Load(e, db, emailClient)
+func LoadServices(e *echo.Echo, db *sqlx.DB, emailClient email.EmailClient, cfg config.Config) {
+	authorize := apikey.Load(e, db, cfg, audit.NewRecorder(audit.NewRepository()))
+	audit.Load(e, db, authorize)
+	workspace.Load(e, db, authorize)
+	webhooks := webhook.Load(e, db, cfg, authorize)
+	email.Load(e, db, emailClient, cfg, authorize, webhooks)
+	suppression.Load(e, db, cfg, authorize)
+	quota.Load(e, db, authorize)
+	tracking.Load(e, db, cfg)
+	bounce.Load(db, cfg.Bounce, cfg.Verp, cfg.TokenSecret)
 }
 
-type ServiceLoader func(e *echo.Echo, db *sqlx.DB, emailClient email.EmailClient)
+type ServiceLoader func(e *echo.Echo, db *sqlx.DB, emailClient email.EmailClient, cfg config.Config)
@@ -0,0 +1,240 @@
+package suppression
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase SuppressionUsecase
+}
+
+// GetSuppressions implements SuppressionHandler.
+func (h Handler) GetSuppressions(e echo.Context) error {
+	var request models.GetSuppressionRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	suppressions, statusCode, err := h.usecase.GetSuppressions(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": suppressions,
+	})
+}
+
+// GetSuppression implements SuppressionHandler.
+func (h Handler) GetSuppression(e echo.Context) error {
+	email, err := url.PathUnescape(e.Param("email"))
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	suppression, statusCode, err := h.usecase.GetSuppression(e.Request().Context(), email)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": suppression,
+	})
+}
+
+// PostSuppression implements SuppressionHandler.
+func (h Handler) PostSuppression(e echo.Context) error {
+	var request models.PostSuppressionRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	suppression, statusCode, err := h.usecase.CreateSuppression(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(http.StatusCreated, map[string]any{
+		"data": suppression,
+	})
+}
+
+// PutSuppression implements SuppressionHandler.
+func (h Handler) PutSuppression(e echo.Context) error {
+	var request models.PutSuppressionRequest
+
+	email, err := url.PathUnescape(e.Param("email"))
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	suppression, statusCode, err := h.usecase.UpdateSuppression(e.Request().Context(), email, request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": suppression,
+	})
+}
+
+// DeleteSuppression implements SuppressionHandler.
+func (h Handler) DeleteSuppression(e echo.Context) error {
+	email, err := url.PathUnescape(e.Param("email"))
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	statusCode, err := h.usecase.DeleteSuppression(e.Request().Context(), email)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+// PostSuppressionImport implements SuppressionHandler.
+func (h Handler) PostSuppressionImport(e echo.Context) error {
+	var request models.PostSuppressionImportRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// get file
+	file, err := e.FormFile("data")
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	defer src.Close()
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	imported, statusCode, err := h.usecase.ImportSuppressions(e.Request().Context(), request, src)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": map[string]int{
+			"imported": imported,
+		},
+	})
+}
+
+// GetUnsubscribe implements SuppressionHandler.
+// it only asks for confirmation, mail scanners follow links so a GET must never change anything
+func (h Handler) GetUnsubscribe(e echo.Context) error {
+	email, statusCode, err := h.usecase.CheckUnsubscribeToken(e.Request().Context(), e.Param("token"))
+	if err != nil {
+		return unsubscribePageResponse(e, statusCode, unsubscribePageData{Error: err.Error()})
+	}
+
+	return unsubscribePageResponse(e, statusCode, unsubscribePageData{Email: email})
+}
+
+// PostUnsubscribe implements SuppressionHandler.
+// it serves both the confirmation form and RFC 8058 one click requests from mailbox providers
+func (h Handler) PostUnsubscribe(e echo.Context) error {
+	email, statusCode, err := h.usecase.Unsubscribe(e.Request().Context(), e.Param("token"))
+	if err != nil {
+		return unsubscribePageResponse(e, statusCode, unsubscribePageData{Error: err.Error()})
+	}
+
+	return unsubscribePageResponse(e, statusCode, unsubscribePageData{Email: email, Confirmed: true})
+}
+
+func unsubscribePageResponse(e echo.Context, statusCode int, data unsubscribePageData) error {
+	page, err := renderUnsubscribePage(data)
+	if err != nil {
+		return e.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return e.HTML(statusCode, page)
+}
+
+func NewHandler(usecase SuppressionUsecase) SuppressionHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
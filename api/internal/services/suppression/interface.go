@@ -0,0 +1,46 @@
+package suppression
+
+import (
+	"context"
+	"io"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// list of addresses a workspace must never mail again
+// it is checked by the email service before a batch of the workspace is enqueued
+
+type SuppressionHandler interface {
+	GetSuppressions(e echo.Context) error
+	GetSuppression(e echo.Context) error
+	PostSuppression(e echo.Context) error
+	PutSuppression(e echo.Context) error
+	DeleteSuppression(e echo.Context) error
+	PostSuppressionImport(e echo.Context) error
+	GetUnsubscribe(e echo.Context) error
+	PostUnsubscribe(e echo.Context) error
+}
+
+type SuppressionUsecase interface {
+	GetSuppressions(ctx context.Context, param models.GetSuppressionRequest) ([]models.Suppression, int, error)
+	GetSuppression(ctx context.Context, email string) (models.Suppression, int, error)
+	CreateSuppression(ctx context.Context, param models.PostSuppressionRequest) (models.Suppression, int, error)
+	UpdateSuppression(ctx context.Context, email string, param models.PutSuppressionRequest) (models.Suppression, int, error)
+	DeleteSuppression(ctx context.Context, email string) (int, error)
+	ImportSuppressions(ctx context.Context, param models.PostSuppressionImportRequest, r io.Reader) (imported int, statusCode int, err error)
+	CheckUnsubscribeToken(ctx context.Context, token string) (email string, statusCode int, err error)
+	Unsubscribe(ctx context.Context, token string) (email string, statusCode int, err error)
+}
+
+type SuppressionRepository interface {
+	CreateSuppression(ctx context.Context, tx *sqlx.Tx, param models.Suppression) error
+	ReadSuppression(ctx context.Context, db *sqlx.DB, workspaceId string, email string) (models.Suppression, error)
+	ReadSuppressions(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetSuppressionRequest) ([]models.Suppression, error)
+	ReadSuppressionsByEmails(ctx context.Context, db *sqlx.DB, workspaceId string, emails []string) ([]models.Suppression, error)
+	UpdateSuppression(ctx context.Context, tx *sqlx.Tx, param models.Suppression) error
+	DeleteSuppression(ctx context.Context, tx *sqlx.Tx, workspaceId string, email string) error
+	// ReadBatchWorkspace tells which workspace an unsubscribe link was sent from
+	ReadBatchWorkspace(ctx context.Context, db *sqlx.DB, batchId string) (string, error)
+}
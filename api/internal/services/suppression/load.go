@@ -0,0 +1,25 @@
+package suppression
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+func Load(e *echo.Echo, db *sqlx.DB, cfg config.Config, authorize apikey.Authorizer) {
+
+	// init repository
+	repository := NewRepository()
+	recorder := audit.NewRecorder(audit.NewRepository())
+
+	// init usecase
+	usecase := NewUsecase(db, repository, recorder, cfg)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler, authorize)
+}
@@ -0,0 +1,54 @@
+package suppression
+
+import (
+	"html/template"
+	"strings"
+)
+
+type unsubscribePageData struct {
+	Email     string
+	Confirmed bool
+	Error     string
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!doctype html>
+<html>
+    <head>
+        <meta charset="UTF-8" />
+        <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+        <title>Unsubscribe</title>
+        <style>
+            body { margin: 0; padding: 48px 16px; background-color: #f4f4f4; font-family: Arial, sans-serif; color: #2d2d2d; }
+            .card { max-width: 480px; margin: 0 auto; padding: 32px; background-color: #ffffff; border-radius: 12px; text-align: center; }
+            button { margin-top: 16px; padding: 12px 32px; border: none; border-radius: 8px; background-color: #2d2d2d; color: #ffffff; font-size: 14px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="card">
+            {{if .Error}}
+            <h2>Something went wrong</h2>
+            <p>{{.Error}}</p>
+            {{else if .Confirmed}}
+            <h2>You have been unsubscribed</h2>
+            <p><strong>{{.Email}}</strong> will no longer receive emails from us.</p>
+            {{else}}
+            <h2>Unsubscribe</h2>
+            <p>Stop sending emails to <strong>{{.Email}}</strong>?</p>
+            <form method="post">
+                <button type="submit">Unsubscribe</button>
+            </form>
+            {{end}}
+        </div>
+    </body>
+</html>
+`))
+
+func renderUnsubscribePage(data unsubscribePageData) (string, error) {
+	var page strings.Builder
+
+	if err := unsubscribePage.Execute(&page, data); err != nil {
+		return "", err
+	}
+
+	return page.String(), nil
+}
@@ -0,0 +1,109 @@
+package suppression
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const suppressionColumns = `workspace_id, email, reason, source, created_at`
+
+type Repository struct {
+}
+
+// CreateSuppression implements SuppressionRepository.
+// suppressing an address twice keeps the original timestamp and takes the latest reason
+func (r Repository) CreateSuppression(ctx context.Context, tx *sqlx.Tx, param models.Suppression) error {
+	query := `
+		INSERT INTO suppressions (workspace_id, email, reason, source)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (workspace_id, email) DO UPDATE SET reason = EXCLUDED.reason, source = EXCLUDED.source`
+
+	_, err := tx.ExecContext(ctx, query, param.WorkspaceId, param.Email, param.Reason, param.Source)
+	return err
+}
+
+// ReadSuppression implements SuppressionRepository.
+func (r Repository) ReadSuppression(ctx context.Context, db *sqlx.DB, workspaceId string, email string) (models.Suppression, error) {
+	var result models.Suppression
+
+	query := `SELECT ` + suppressionColumns + ` FROM suppressions WHERE workspace_id = $1 AND email = $2`
+	err := db.GetContext(ctx, &result, query, workspaceId, email)
+
+	return result, err
+}
+
+// ReadSuppressions implements SuppressionRepository.
+func (r Repository) ReadSuppressions(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetSuppressionRequest) ([]models.Suppression, error) {
+	results := []models.Suppression{}
+
+	query := `
+		SELECT ` + suppressionColumns + ` FROM suppressions
+		WHERE workspace_id = $1 AND ($2 = '' OR reason = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`
+	err := db.SelectContext(ctx, &results, query, workspaceId, param.Reason, param.Limit, param.Offset)
+
+	return results, err
+}
+
+// ReadSuppressionsByEmails implements SuppressionRepository.
+func (r Repository) ReadSuppressionsByEmails(ctx context.Context, db *sqlx.DB, workspaceId string, emails []string) ([]models.Suppression, error) {
+	results := []models.Suppression{}
+
+	query := `SELECT ` + suppressionColumns + ` FROM suppressions WHERE workspace_id = $1 AND email = ANY($2)`
+	err := db.SelectContext(ctx, &results, query, workspaceId, pq.Array(emails))
+
+	return results, err
+}
+
+// UpdateSuppression implements SuppressionRepository.
+func (r Repository) UpdateSuppression(ctx context.Context, tx *sqlx.Tx, param models.Suppression) error {
+	query := `UPDATE suppressions SET reason = $3, source = $4 WHERE workspace_id = $1 AND email = $2`
+
+	res, err := tx.ExecContext(ctx, query, param.WorkspaceId, param.Email, param.Reason, param.Source)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteSuppression implements SuppressionRepository.
+func (r Repository) DeleteSuppression(ctx context.Context, tx *sqlx.Tx, workspaceId string, email string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM suppressions WHERE workspace_id = $1 AND email = $2`, workspaceId, email)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// ReadBatchWorkspace implements SuppressionRepository.
+func (r Repository) ReadBatchWorkspace(ctx context.Context, db *sqlx.DB, batchId string) (string, error) {
+	var result string
+
+	err := db.GetContext(ctx, &result, `SELECT workspace_id FROM email_batches WHERE id = $1`, batchId)
+
+	return result, err
+}
+
+func requireAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func NewRepository() SuppressionRepository {
+	return Repository{}
+}
@@ -0,0 +1,20 @@
+package suppression
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler SuppressionHandler, authorize apikey.Authorizer) {
+	e.GET("/suppression", handler.GetSuppressions, authorize(models.ScopeSuppressionRead))
+	e.POST("/suppression", handler.PostSuppression, authorize(models.ScopeSuppressionWrite))
+	e.POST("/suppression/import", handler.PostSuppressionImport, authorize(models.ScopeSuppressionWrite))
+	e.GET("/suppression/:email", handler.GetSuppression, authorize(models.ScopeSuppressionRead))
+	e.PUT("/suppression/:email", handler.PutSuppression, authorize(models.ScopeSuppressionWrite))
+	e.DELETE("/suppression/:email", handler.DeleteSuppression, authorize(models.ScopeSuppressionWrite))
+
+	// public links embedded in emails
+	e.GET("/unsubscribe/:token", handler.GetUnsubscribe)
+	e.POST("/unsubscribe/:token", handler.PostUnsubscribe)
+}
@@ -0,0 +1,36 @@
+package suppression
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+)
+
+const unsubscribePurpose = "unsubscribe"
+
+// UnsubscribeURL builds the per recipient link used in the List-Unsubscribe header and templates
+// it returns an empty string when the api has no public url or secret configured
+func UnsubscribeURL(cfg config.Config, batchId, address string) string {
+	if cfg.BaseURL == "" || cfg.TokenSecret == "" {
+		return ""
+	}
+
+	token := utils.SignToken(cfg.TokenSecret, unsubscribePurpose, batchId+"\n"+utils.NormalizeEmail(address))
+	return strings.TrimRight(cfg.BaseURL, "/") + "/unsubscribe/" + token
+}
+
+func parseUnsubscribeToken(secret, token string) (batchId string, address string, err error) {
+	payload, err := utils.VerifyToken(secret, unsubscribePurpose, token)
+	if err != nil {
+		return "", "", err
+	}
+
+	batchId, address, found := strings.Cut(payload, "\n")
+	if !found || address == "" {
+		return "", "", fmt.Errorf("malformed token")
+	}
+
+	return batchId, address, nil
+}
@@ -0,0 +1,263 @@
+package suppression
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository SuppressionRepository
+	recorder   audit.Recorder
+	cfg        config.Config
+}
+
+// GetSuppressions implements SuppressionUsecase.
+func (u Usecase) GetSuppressions(ctx context.Context, param models.GetSuppressionRequest) ([]models.Suppression, int, error) {
+	if param.Limit <= 0 {
+		param.Limit = defaultListLimit
+	}
+	if param.Limit > maxListLimit {
+		param.Limit = maxListLimit
+	}
+	if param.Offset < 0 {
+		param.Offset = 0
+	}
+
+	results, err := u.repository.ReadSuppressions(ctx, u.db, apikey.WorkspaceFromContext(ctx), param)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetSuppression implements SuppressionUsecase.
+func (u Usecase) GetSuppression(ctx context.Context, email string) (models.Suppression, int, error) {
+	result, err := u.repository.ReadSuppression(ctx, u.db, apikey.WorkspaceFromContext(ctx), utils.NormalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Suppression{}, http.StatusNotFound, fmt.Errorf("address: %s is not suppressed", email)
+	}
+	if err != nil {
+		return models.Suppression{}, http.StatusInternalServerError, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// CreateSuppression implements SuppressionUsecase.
+func (u Usecase) CreateSuppression(ctx context.Context, param models.PostSuppressionRequest) (models.Suppression, int, error) {
+	suppression := models.Suppression{
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+		Email:       utils.NormalizeEmail(param.Email),
+		Reason:      param.Reason,
+		Source:      param.Source,
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateSuppression(ctx, tx, suppression); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceSuppression, suppression.Email, nil, suppression)
+	})
+	if err != nil {
+		return models.Suppression{}, http.StatusInternalServerError, err
+	}
+
+	return u.GetSuppression(ctx, suppression.Email)
+}
+
+// UpdateSuppression implements SuppressionUsecase.
+func (u Usecase) UpdateSuppression(ctx context.Context, email string, param models.PutSuppressionRequest) (models.Suppression, int, error) {
+	suppression := models.Suppression{
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+		Email:       utils.NormalizeEmail(email),
+		Reason:      param.Reason,
+		Source:      param.Source,
+	}
+
+	before, statusCode, err := u.GetSuppression(ctx, email)
+	if err != nil {
+		return models.Suppression{}, statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateSuppression(ctx, tx, suppression); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceSuppression, suppression.Email, before, suppression)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Suppression{}, http.StatusNotFound, fmt.Errorf("address: %s is not suppressed", email)
+	}
+	if err != nil {
+		return models.Suppression{}, http.StatusInternalServerError, err
+	}
+
+	return u.GetSuppression(ctx, suppression.Email)
+}
+
+// DeleteSuppression implements SuppressionUsecase.
+func (u Usecase) DeleteSuppression(ctx context.Context, email string) (int, error) {
+	before, statusCode, err := u.GetSuppression(ctx, email)
+	if err != nil {
+		return statusCode, err
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteSuppression(ctx, tx, before.WorkspaceId, before.Email); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceSuppression, before.Email, before, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("address: %s is not suppressed", email)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// ImportSuppressions implements SuppressionUsecase.
+func (u Usecase) ImportSuppressions(ctx context.Context, param models.PostSuppressionImportRequest, r io.Reader) (imported int, statusCode int, err error) {
+	emails, err := utils.ReadCsv(r, param.TargetColumn)
+	if err != nil {
+		return 0, http.StatusBadRequest, err
+	}
+
+	// dedupe so the count reflects distinct addresses
+	seen := map[string]bool{}
+	for _, email := range emails {
+		email = utils.NormalizeEmail(email)
+		if email == "" {
+			continue
+		}
+		seen[email] = true
+	}
+
+	if len(seen) == 0 {
+		return 0, http.StatusBadRequest, fmt.Errorf("no address found to import")
+	}
+
+	source := param.Source
+	if source == "" {
+		source = fmt.Sprintf("import %s", time.Now().UTC().Format(time.RFC3339))
+	}
+
+	workspaceId := apikey.WorkspaceFromContext(ctx)
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		for email := range seen {
+			err := u.repository.CreateSuppression(ctx, tx, models.Suppression{
+				WorkspaceId: workspaceId,
+				Email:       email,
+				Reason:      param.Reason,
+				Source:      source,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// one entry for the whole import rather than one per address
+		return u.recorder.Record(ctx, tx, models.AuditActionImport, models.AuditResourceSuppression, "", nil, map[string]any{
+			"count":  len(seen),
+			"reason": param.Reason,
+			"source": source,
+		})
+	})
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	return len(seen), http.StatusOK, nil
+}
+
+// CheckUnsubscribeToken implements SuppressionUsecase.
+func (u Usecase) CheckUnsubscribeToken(ctx context.Context, token string) (email string, statusCode int, err error) {
+	_, email, err = parseUnsubscribeToken(u.cfg.TokenSecret, token)
+	if err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("unsubscribe link is invalid")
+	}
+
+	return email, http.StatusOK, nil
+}
+
+// Unsubscribe implements SuppressionUsecase.
+func (u Usecase) Unsubscribe(ctx context.Context, token string) (email string, statusCode int, err error) {
+	batchId, email, err := parseUnsubscribeToken(u.cfg.TokenSecret, token)
+	if err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("unsubscribe link is invalid")
+	}
+
+	// the address is suppressed for the workspace the batch was sent from
+	workspaceId, err := u.repository.ReadBatchWorkspace(ctx, u.db, batchId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", http.StatusNotFound, fmt.Errorf("unsubscribe link is invalid")
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	// an address that is already suppressed keeps its original reason
+	_, err = u.repository.ReadSuppression(ctx, u.db, workspaceId, email)
+	if err == nil {
+		return email, http.StatusOK, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", http.StatusInternalServerError, err
+	}
+
+	suppression := models.Suppression{
+		WorkspaceId: workspaceId,
+		Email:       email,
+		Reason:      models.SuppressionReasonUnsubscribed,
+		Source:      "unsubscribe link, batch: " + batchId,
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateSuppression(ctx, tx, suppression); err != nil {
+			return err
+		}
+
+		// the link is public, the change is the system's on behalf of the recipient
+		system := audit.System{WorkspaceId: workspaceId, Name: "unsubscribe link"}
+		return u.recorder.RecordSystem(ctx, tx, system, models.AuditActionCreate, models.AuditResourceSuppression, suppression.Email, nil, suppression)
+	})
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	return email, http.StatusOK, nil
+}
+
+func NewUsecase(db *sqlx.DB, repository SuppressionRepository, recorder audit.Recorder, cfg config.Config) SuppressionUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+		recorder:   recorder,
+		cfg:        cfg,
+	}
+}
@@ -0,0 +1,55 @@
+package tracking
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// transparent 1x1 gif
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+type Handler struct {
+	usecase TrackingUsecase
+}
+
+// GetOpen implements TrackingHandler.
+// the pixel is served whatever happens so a broken token never shows as a broken image
+func (h Handler) GetOpen(e echo.Context) error {
+	token := strings.TrimSuffix(e.Param("token"), ".gif")
+
+	err := h.usecase.RecordOpen(e.Request().Context(), token, e.Request().UserAgent(), e.RealIP())
+	if err != nil {
+		log.Printf("fail to record open: %s", err.Error())
+	}
+
+	// opens are only counted when the client asks again, so nothing may cache the pixel
+	e.Response().Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	e.Response().Header().Set("Pragma", "no-cache")
+
+	return e.Blob(http.StatusOK, "image/gif", pixel)
+}
+
+// GetClick implements TrackingHandler.
+func (h Handler) GetClick(e echo.Context) error {
+	target, statusCode, err := h.usecase.RecordClick(e.Request().Context(), e.Param("token"), e.Request().UserAgent(), e.RealIP())
+	if err != nil {
+		return e.String(statusCode, err.Error())
+	}
+
+	e.Response().Header().Set("Cache-Control", "no-store, private")
+
+	return e.Redirect(statusCode, target)
+}
+
+func NewHandler(usecase TrackingUsecase) TrackingHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
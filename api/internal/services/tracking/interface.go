@@ -0,0 +1,33 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// public endpoints hit by mail clients
+// they record engagement per email and must always answer, even for a bad token
+
+type TrackingHandler interface {
+	GetOpen(e echo.Context) error
+	GetClick(e echo.Context) error
+}
+
+type TrackingUsecase interface {
+	RecordOpen(ctx context.Context, token string, userAgent string, ip string) error
+	// RecordClick returns the original url to redirect to
+	RecordClick(ctx context.Context, token string, userAgent string, ip string) (target string, statusCode int, err error)
+}
+
+type TrackingRepository interface {
+	// IncrementOpen bumps the open counters of an email and returns its batch
+	// both read every workspace, the signed token only carries the email id
+	IncrementOpen(ctx context.Context, tx *sqlx.Tx, emailId int, openedAt time.Time) (batchId string, err error)
+	// IncrementClick bumps the click counters of an email and returns its batch
+	IncrementClick(ctx context.Context, tx *sqlx.Tx, emailId int, clickedAt time.Time) (batchId string, err error)
+	CreateEvent(ctx context.Context, tx *sqlx.Tx, param models.EmailEvent) error
+}
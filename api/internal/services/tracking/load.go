@@ -0,0 +1,22 @@
+package tracking
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+func Load(e *echo.Echo, db *sqlx.DB, cfg config.Config) {
+
+	// init repository
+	repository := NewRepository()
+
+	// init usecase
+	usecase := NewUsecase(db, repository, cfg)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler)
+}
@@ -0,0 +1,50 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+}
+
+// IncrementOpen implements TrackingRepository.
+func (r Repository) IncrementOpen(ctx context.Context, tx *sqlx.Tx, emailId int, openedAt time.Time) (batchId string, err error) {
+	query := `
+		UPDATE emails
+		SET open_count = open_count + 1, first_opened_at = COALESCE(first_opened_at, $2), last_opened_at = $2
+		WHERE id = $1
+		RETURNING batch_id`
+
+	err = tx.QueryRowxContext(ctx, query, emailId, openedAt).Scan(&batchId)
+	return batchId, err
+}
+
+// IncrementClick implements TrackingRepository.
+func (r Repository) IncrementClick(ctx context.Context, tx *sqlx.Tx, emailId int, clickedAt time.Time) (batchId string, err error) {
+	query := `
+		UPDATE emails
+		SET click_count = click_count + 1, first_clicked_at = COALESCE(first_clicked_at, $2)
+		WHERE id = $1
+		RETURNING batch_id`
+
+	err = tx.QueryRowxContext(ctx, query, emailId, clickedAt).Scan(&batchId)
+	return batchId, err
+}
+
+// CreateEvent implements TrackingRepository.
+func (r Repository) CreateEvent(ctx context.Context, tx *sqlx.Tx, param models.EmailEvent) error {
+	query := `
+		INSERT INTO email_events (email_id, batch_id, type, user_agent, ip, url, created_at)
+		VALUES (:email_id, :batch_id, :type, :user_agent, :ip, :url, :created_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+func NewRepository() TrackingRepository {
+	return Repository{}
+}
@@ -0,0 +1,11 @@
+package tracking
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler TrackingHandler) {
+	// the token param carries the .gif suffix, some clients only load images that look like images
+	e.GET("/t/o/:token", handler.GetOpen)
+	e.GET("/t/c/:token", handler.GetClick)
+}
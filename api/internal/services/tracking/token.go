@@ -0,0 +1,65 @@
+package tracking
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+)
+
+const (
+	openPurpose  = "open"
+	clickPurpose = "click"
+)
+
+// OpenPixelURL builds the per email tracking pixel url
+// it returns an empty string when the api has no public url or secret configured
+func OpenPixelURL(cfg config.Config, emailId int) string {
+	if cfg.BaseURL == "" || cfg.TokenSecret == "" {
+		return ""
+	}
+
+	token := utils.SignToken(cfg.TokenSecret, openPurpose, strconv.Itoa(emailId))
+	return strings.TrimRight(cfg.BaseURL, "/") + "/t/o/" + token + ".gif"
+}
+
+// ClickURL builds the signed redirect url that stands in for target in the email of emailId
+// the target is part of the signed payload so the endpoint can never be used as an open redirect
+func ClickURL(cfg config.Config, emailId int, target string) string {
+	if cfg.BaseURL == "" || cfg.TokenSecret == "" {
+		return ""
+	}
+
+	token := utils.SignToken(cfg.TokenSecret, clickPurpose, strconv.Itoa(emailId)+"\n"+target)
+	return strings.TrimRight(cfg.BaseURL, "/") + "/t/c/" + token
+}
+
+func parseClickToken(secret, token string) (emailId int, target string, err error) {
+	payload, err := utils.VerifyToken(secret, clickPurpose, token)
+	if err != nil {
+		return 0, "", err
+	}
+
+	encodedId, target, found := strings.Cut(payload, "\n")
+	if !found || target == "" {
+		return 0, "", fmt.Errorf("malformed token")
+	}
+
+	emailId, err = strconv.Atoi(encodedId)
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed token")
+	}
+
+	return emailId, target, nil
+}
+
+func parseEmailToken(secret, purpose, token string) (int, error) {
+	payload, err := utils.VerifyToken(secret, purpose, token)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(payload)
+}
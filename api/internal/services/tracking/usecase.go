@@ -0,0 +1,87 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository TrackingRepository
+	cfg        config.Config
+}
+
+// RecordOpen implements TrackingUsecase.
+func (u Usecase) RecordOpen(ctx context.Context, token string, userAgent string, ip string) error {
+	emailId, err := parseEmailToken(u.cfg.TokenSecret, openPurpose, token)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	return utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		batchId, err := u.repository.IncrementOpen(ctx, tx, emailId, now)
+		if err != nil {
+			return err
+		}
+
+		return u.repository.CreateEvent(ctx, tx, models.EmailEvent{
+			EmailId:   emailId,
+			BatchId:   batchId,
+			Type:      models.EmailEventOpen,
+			UserAgent: userAgent,
+			Ip:        ip,
+			CreatedAt: now,
+		})
+	})
+}
+
+// RecordClick implements TrackingUsecase.
+// the redirect still happens when recording fails, a lost click is better than a dead link
+func (u Usecase) RecordClick(ctx context.Context, token string, userAgent string, ip string) (target string, statusCode int, err error) {
+	emailId, target, err := parseClickToken(u.cfg.TokenSecret, token)
+	if err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("link is invalid")
+	}
+
+	now := time.Now()
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		batchId, err := u.repository.IncrementClick(ctx, tx, emailId, now)
+		if err != nil {
+			return err
+		}
+
+		return u.repository.CreateEvent(ctx, tx, models.EmailEvent{
+			EmailId:   emailId,
+			BatchId:   batchId,
+			Type:      models.EmailEventClick,
+			UserAgent: userAgent,
+			Ip:        ip,
+			Url:       target,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		log.Printf("fail to record click: %s", err.Error())
+	}
+
+	return target, http.StatusFound, nil
+}
+
+func NewUsecase(db *sqlx.DB, repository TrackingRepository, cfg config.Config) TrackingUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+		cfg:        cfg,
+	}
+}
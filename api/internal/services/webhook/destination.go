@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenDestination = errors.New("webhook destination is not a public address")
+
+// ValidateUrl accepts absolute http and https urls that do not point at a private address unless allowPrivate is set
+// names are checked again when a delivery dials, so a host that later resolves inward is still refused
+func ValidateUrl(raw string, allowPrivate bool) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("webhook url must use http or https")
+	}
+
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("webhook url must have a host")
+	}
+
+	if parsed.User != nil {
+		return fmt.Errorf("webhook url must not carry credentials")
+	}
+
+	if ip := net.ParseIP(parsed.Hostname()); !allowPrivate && ip != nil && !public(ip) {
+		return ErrForbiddenDestination
+	}
+
+	return nil
+}
+
+// public is false for loopback, private, link-local, unspecified and multicast addresses
+func public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// guard runs after the name is resolved and before connecting, so it also covers redirects and dns rebinding
+func guard(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, host)
+	}
+
+	return nil
+}
+
+// NewClient only connects to public addresses unless allowPrivate is set
+// proxies are not used, the guard has to see the real destination
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = guard
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
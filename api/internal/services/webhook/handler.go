@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase WebhookUsecase
+}
+
+// GetWebhooks implements WebhookHandler.
+func (h Handler) GetWebhooks(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetWebhooks(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// PostWebhook implements WebhookHandler.
+func (h Handler) PostWebhook(e echo.Context) error {
+	var request models.PostWebhookRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateWebhook(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// DeleteWebhook implements WebhookHandler.
+func (h Handler) DeleteWebhook(e echo.Context) error {
+	// call usecase
+	statusCode, err := h.usecase.DeleteWebhook(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.NoContent(statusCode)
+}
+
+// GetDeliveries implements WebhookHandler.
+func (h Handler) GetDeliveries(e echo.Context) error {
+	var request models.GetWebhookDeliveryRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	results, statusCode, err := h.usecase.GetDeliveries(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+func NewHandler(usecase WebhookUsecase) WebhookHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// batch events pushed to the webhooks of the user
+// every event is stored as one delivery per webhook and retried with backoff until it is accepted
+// subscriptions belong to a workspace and only hear about the batches of that workspace
+
+type WebhookHandler interface {
+	GetWebhooks(e echo.Context) error
+	PostWebhook(e echo.Context) error
+	DeleteWebhook(e echo.Context) error
+	GetDeliveries(e echo.Context) error
+}
+
+type WebhookUsecase interface {
+	GetWebhooks(ctx context.Context) ([]models.WebhookSubscription, int, error)
+	CreateWebhook(ctx context.Context, param models.PostWebhookRequest) (models.PostWebhookResponse, int, error)
+	DeleteWebhook(ctx context.Context, id string) (int, error)
+	GetDeliveries(ctx context.Context, param models.GetWebhookDeliveryRequest) ([]models.WebhookDelivery, int, error)
+
+	// Publish stores a delivery of the event for every webhook interested in it
+	Publish(ctx context.Context, batch models.EmailBatch, event string, data any) error
+	// DeliverDue attempts the deliveries that are due
+	DeliverDue(ctx context.Context) (delivered int, err error)
+
+	// the email service reports batch events through these, see email.BatchObserver
+	BatchStarted(ctx context.Context, batch models.EmailBatch) error
+	BatchProgress(ctx context.Context, batch models.EmailBatch) error
+	BatchCompleted(ctx context.Context, batch models.EmailBatch) error
+	EmailSent(ctx context.Context, batch models.EmailBatch, email models.Email) error
+	EmailFailed(ctx context.Context, batch models.EmailBatch, email models.Email) error
+}
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, tx *sqlx.Tx, param models.WebhookSubscription) error
+	ReadSubscriptions(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.WebhookSubscription, error)
+	ReadSubscriptionsByEvent(ctx context.Context, db *sqlx.DB, workspaceId string, event string) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string) error
+
+	CreateDelivery(ctx context.Context, tx *sqlx.Tx, param models.WebhookDelivery) error
+	ReadDeliveries(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetWebhookDeliveryRequest) ([]models.WebhookDelivery, error)
+	ReadDueDeliveries(ctx context.Context, db *sqlx.DB, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, tx *sqlx.Tx, param models.WebhookDelivery) error
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// retries come due without anything waking the dispatcher
+const dispatchInterval = 15 * time.Second
+
+// Load returns the usecase so the email service can report its batch events to it
+func Load(e *echo.Echo, db *sqlx.DB, cfg config.Config, authorize apikey.Authorizer) WebhookUsecase {
+
+	// init repository
+	repository := NewRepository()
+	recorder := audit.NewRecorder(audit.NewRepository())
+
+	// init usecase
+	wake := make(chan struct{}, 1)
+	usecase := NewUsecase(db, repository, recorder, cfg.Webhook, wake)
+
+	// init handler
+	handler := NewHandler(usecase)
+
+	// init routes
+	NewRoutes(e, handler, authorize)
+
+	// init dispatcher
+	go Dispatch(context.Background(), usecase, dispatchInterval, wake)
+
+	return usecase
+}
+
+// Dispatch attempts the due deliveries on every tick, or as soon as one is stored, until ctx is done
+func Dispatch(ctx context.Context, usecase WebhookUsecase, interval time.Duration, wake <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := usecase.DeliverDue(ctx); err != nil {
+			log.Printf("fail to deliver webhooks: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+)
+
+// BatchStarted implements WebhookUsecase.
+func (u Usecase) BatchStarted(ctx context.Context, batch models.EmailBatch) error {
+	return u.Publish(ctx, batch, models.WebhookEventBatchStarted, batch)
+}
+
+// BatchProgress implements WebhookUsecase.
+func (u Usecase) BatchProgress(ctx context.Context, batch models.EmailBatch) error {
+	return u.Publish(ctx, batch, models.WebhookEventBatchProgress, batch)
+}
+
+// BatchCompleted implements WebhookUsecase.
+func (u Usecase) BatchCompleted(ctx context.Context, batch models.EmailBatch) error {
+	return u.Publish(ctx, batch, models.WebhookEventBatchCompleted, batch)
+}
+
+// EmailSent implements WebhookUsecase.
+// there is no event for it, a webhook would receive one call per recipient
+func (u Usecase) EmailSent(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	return nil
+}
+
+// EmailFailed implements WebhookUsecase.
+func (u Usecase) EmailFailed(ctx context.Context, batch models.EmailBatch, email models.Email) error {
+	return u.Publish(ctx, batch, models.WebhookEventEmailFailed, models.EmailFailedData{
+		BatchId: batch.Id,
+		Email:   email,
+	})
+}
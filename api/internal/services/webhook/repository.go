@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	subscriptionColumns = `id, workspace_id, url, secret, events, created_at`
+	deliveryColumns     = `id, workspace_id, subscription_id, batch_id, event_id, event, url, secret, payload, status, attempts, response_code, error, next_attempt_at, created_at, delivered_at`
+)
+
+type Repository struct {
+}
+
+// CreateSubscription implements WebhookRepository.
+func (r Repository) CreateSubscription(ctx context.Context, tx *sqlx.Tx, param models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (` + subscriptionColumns + `)
+		VALUES (:id, :workspace_id, :url, :secret, :events, :created_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadSubscriptions implements WebhookRepository.
+func (r Repository) ReadSubscriptions(ctx context.Context, db *sqlx.DB, workspaceId string) ([]models.WebhookSubscription, error) {
+	results := []models.WebhookSubscription{}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions WHERE workspace_id = $1 ORDER BY created_at`
+	err := db.SelectContext(ctx, &results, query, workspaceId)
+
+	return results, err
+}
+
+// ReadSubscriptionsByEvent implements WebhookRepository.
+func (r Repository) ReadSubscriptionsByEvent(ctx context.Context, db *sqlx.DB, workspaceId string, event string) ([]models.WebhookSubscription, error) {
+	results := []models.WebhookSubscription{}
+
+	query := `
+		SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions
+		WHERE workspace_id = $1 AND (cardinality(events) = 0 OR $2 = ANY(events))
+		ORDER BY created_at`
+	err := db.SelectContext(ctx, &results, query, workspaceId, event)
+
+	return results, err
+}
+
+// DeleteSubscription implements WebhookRepository.
+func (r Repository) DeleteSubscription(ctx context.Context, tx *sqlx.Tx, workspaceId string, id string) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND workspace_id = $2`, id, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CreateDelivery implements WebhookRepository.
+func (r Repository) CreateDelivery(ctx context.Context, tx *sqlx.Tx, param models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (workspace_id, subscription_id, batch_id, event_id, event, url, secret, payload, status, next_attempt_at, created_at)
+		VALUES (:workspace_id, :subscription_id, :batch_id, :event_id, :event, :url, :secret, :payload, :status, :next_attempt_at, :created_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadDeliveries implements WebhookRepository.
+func (r Repository) ReadDeliveries(ctx context.Context, db *sqlx.DB, workspaceId string, param models.GetWebhookDeliveryRequest) ([]models.WebhookDelivery, error) {
+	results := []models.WebhookDelivery{}
+
+	query := `
+		SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE workspace_id = $1 AND ($2 = '' OR batch_id = $2) AND ($3 = '' OR status = $3)
+		ORDER BY id DESC
+		LIMIT $4 OFFSET $5`
+	err := db.SelectContext(ctx, &results, query, workspaceId, param.BatchId, param.Status, param.Limit, param.Offset)
+
+	return results, err
+}
+
+// ReadDueDeliveries implements WebhookRepository.
+// it reads every workspace for the dispatcher
+func (r Repository) ReadDueDeliveries(ctx context.Context, db *sqlx.DB, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	results := []models.WebhookDelivery{}
+
+	query := `
+		SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE status = $1 AND next_attempt_at <= $2
+		ORDER BY id
+		LIMIT $3`
+	err := db.SelectContext(ctx, &results, query, models.WebhookDeliveryPending, now, limit)
+
+	return results, err
+}
+
+// UpdateDelivery implements WebhookRepository.
+func (r Repository) UpdateDelivery(ctx context.Context, tx *sqlx.Tx, param models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = :status, attempts = :attempts, response_code = :response_code, error = :error,
+			next_attempt_at = :next_attempt_at, delivered_at = :delivered_at
+		WHERE id = :id AND workspace_id = :workspace_id`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+func NewRepository() WebhookRepository {
+	return Repository{}
+}
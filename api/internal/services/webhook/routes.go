@@ -0,0 +1,14 @@
+package webhook
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler WebhookHandler, authorize apikey.Authorizer) {
+	e.GET("/webhook", handler.GetWebhooks, authorize(models.ScopeWebhookManage))
+	e.POST("/webhook", handler.PostWebhook, authorize(models.ScopeWebhookManage))
+	e.GET("/webhook/deliveries", handler.GetDeliveries, authorize(models.ScopeWebhookManage))
+	e.DELETE("/webhook/:id", handler.DeleteWebhook, authorize(models.ScopeWebhookManage))
+}
@@ -0,0 +1,339 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/config"
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	defaultListLimit   = 50
+	maxListLimit       = 500
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 8
+
+	// deliveries attempted per round of DeliverDue
+	deliveryPageSize = 100
+	// first retry, every following one waits four times longer up to maxBackoff
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository WebhookRepository
+	recorder   audit.Recorder
+	client     *http.Client
+	cfg        config.WebhookConfig
+	// wakes the dispatcher when a delivery is stored
+	wake chan struct{}
+}
+
+// GetWebhooks implements WebhookUsecase.
+func (u Usecase) GetWebhooks(ctx context.Context) ([]models.WebhookSubscription, int, error) {
+	results, err := u.repository.ReadSubscriptions(ctx, u.db, apikey.WorkspaceFromContext(ctx))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// CreateWebhook implements WebhookUsecase.
+func (u Usecase) CreateWebhook(ctx context.Context, param models.PostWebhookRequest) (models.PostWebhookResponse, int, error) {
+	if err := ValidateUrl(param.Url, u.cfg.AllowPrivate); err != nil {
+		return models.PostWebhookResponse{}, http.StatusBadRequest, err
+	}
+
+	secret := param.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return models.PostWebhookResponse{}, http.StatusInternalServerError, err
+		}
+	}
+
+	events := param.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	subscription := models.WebhookSubscription{
+		Id:          uuid.NewV4().String(),
+		WorkspaceId: apikey.WorkspaceFromContext(ctx),
+		Url:         param.Url,
+		Secret:      secret,
+		Events:      events,
+		CreatedAt:   time.Now(),
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateSubscription(ctx, tx, subscription); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceWebhook, subscription.Id, nil, subscription)
+	})
+	if err != nil {
+		return models.PostWebhookResponse{}, http.StatusInternalServerError, err
+	}
+
+	return models.PostWebhookResponse{
+		WebhookSubscription: subscription,
+		Secret:              secret,
+	}, http.StatusCreated, nil
+}
+
+// DeleteWebhook implements WebhookUsecase.
+func (u Usecase) DeleteWebhook(ctx context.Context, id string) (int, error) {
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.DeleteSubscription(ctx, tx, apikey.WorkspaceFromContext(ctx), id); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionDelete, models.AuditResourceWebhook, id, nil, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("webhook: %s not found", id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// GetDeliveries implements WebhookUsecase.
+func (u Usecase) GetDeliveries(ctx context.Context, param models.GetWebhookDeliveryRequest) ([]models.WebhookDelivery, int, error) {
+	if param.Limit <= 0 {
+		param.Limit = defaultListLimit
+	}
+	if param.Limit > maxListLimit {
+		param.Limit = maxListLimit
+	}
+	if param.Offset < 0 {
+		param.Offset = 0
+	}
+
+	results, err := u.repository.ReadDeliveries(ctx, u.db, apikey.WorkspaceFromContext(ctx), param)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// Publish implements WebhookUsecase.
+// the webhook of the request that created the batch receives every event, subscriptions only those they asked for
+func (u Usecase) Publish(ctx context.Context, batch models.EmailBatch, event string, data any) error {
+	subscriptions, err := u.repository.ReadSubscriptionsByEvent(ctx, u.db, batch.WorkspaceId, event)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 0 && batch.WebhookUrl == "" {
+		return nil
+	}
+
+	eventId := uuid.NewV4().String()
+	now := time.Now()
+
+	payload, err := json.Marshal(models.WebhookEvent{
+		Id:        eventId,
+		Type:      event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	newDelivery := func(url string, secret string) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			WorkspaceId:   batch.WorkspaceId,
+			BatchId:       &batch.Id,
+			EventId:       eventId,
+			Event:         event,
+			Url:           url,
+			Secret:        secret,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+	}
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if batch.WebhookUrl != "" {
+			if err := u.repository.CreateDelivery(ctx, tx, newDelivery(batch.WebhookUrl, batch.WebhookSecret)); err != nil {
+				return err
+			}
+		}
+
+		for _, subscription := range subscriptions {
+			delivery := newDelivery(subscription.Url, subscription.Secret)
+			delivery.SubscriptionId = &subscription.Id
+
+			if err := u.repository.CreateDelivery(ctx, tx, delivery); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// DeliverDue implements WebhookUsecase.
+func (u Usecase) DeliverDue(ctx context.Context) (delivered int, err error) {
+	for {
+		deliveries, err := u.repository.ReadDueDeliveries(ctx, u.db, time.Now(), deliveryPageSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, delivery := range deliveries {
+			delivery = u.attempt(ctx, delivery)
+
+			err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+				return u.repository.UpdateDelivery(ctx, tx, delivery)
+			})
+			if err != nil {
+				return delivered, err
+			}
+
+			if delivery.Status == models.WebhookDeliveryDelivered {
+				delivered++
+			}
+		}
+
+		if len(deliveries) < deliveryPageSize {
+			return delivered, nil
+		}
+	}
+}
+
+// attempt posts a delivery once and records the outcome, a failed attempt is scheduled again with backoff
+func (u Usecase) attempt(ctx context.Context, delivery models.WebhookDelivery) models.WebhookDelivery {
+	delivery.Attempts++
+
+	statusCode, err := u.post(ctx, delivery)
+	if statusCode != 0 {
+		delivery.ResponseCode = &statusCode
+	}
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.Error = nil
+		return delivery
+	}
+
+	message := err.Error()
+	delivery.Error = &message
+
+	if delivery.Attempts >= u.maxAttempts() {
+		log.Printf("giving up webhook delivery: %d to %s, err: %s", delivery.Id, delivery.Url, message)
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		return delivery
+	}
+
+	next := time.Now().Add(backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+	return delivery
+}
+
+// post sends the payload, anything but a 2xx response is an error
+func (u Usecase) post(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "toa-webhook")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	if delivery.Secret != "" {
+		req.Header.Set(SignatureHeader, Signature(delivery.Secret, time.Now().Unix(), body))
+	}
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+func (u Usecase) maxAttempts() int {
+	if u.cfg.MaxAttempts > 0 {
+		return u.cfg.MaxAttempts
+	}
+
+	return defaultMaxAttempts
+}
+
+// backoff is the wait before the next attempt, 30s, 2m, 8m, 32m, ... up to 6h
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 4
+	}
+
+	return min(wait, maxBackoff)
+}
+
+func NewUsecase(db *sqlx.DB, repository WebhookRepository, recorder audit.Recorder, cfg config.WebhookConfig, wake chan struct{}) WebhookUsecase {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return Usecase{
+		db:         db,
+		repository: repository,
+		recorder:   recorder,
+		client:     NewClient(timeout, cfg.AllowPrivate),
+		cfg:        cfg,
+		wake:       wake,
+	}
+}
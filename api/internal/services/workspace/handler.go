@@ -0,0 +1,114 @@
+package workspace
+
+import (
+	"net/http"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	usecase WorkspaceUsecase
+}
+
+// GetWorkspaces implements WorkspaceHandler.
+func (h Handler) GetWorkspaces(e echo.Context) error {
+	// call usecase
+	results, statusCode, err := h.usecase.GetWorkspaces(e.Request().Context())
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": results,
+	})
+}
+
+// GetWorkspace implements WorkspaceHandler.
+func (h Handler) GetWorkspace(e echo.Context) error {
+	// call usecase
+	result, statusCode, err := h.usecase.GetWorkspace(e.Request().Context(), e.Param("id"))
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PostWorkspace implements WorkspaceHandler.
+func (h Handler) PostWorkspace(e echo.Context) error {
+	var request models.PostWorkspaceRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.CreateWorkspace(e.Request().Context(), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+// PutWorkspace implements WorkspaceHandler.
+func (h Handler) PutWorkspace(e echo.Context) error {
+	var request models.PutWorkspaceRequest
+
+	// bind request
+	if err := e.Bind(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// validate request
+	if err := e.Validate(&request); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// call usecase
+	result, statusCode, err := h.usecase.UpdateWorkspace(e.Request().Context(), e.Param("id"), request)
+	if err != nil {
+		return e.JSON(statusCode, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// return response
+	return e.JSON(statusCode, map[string]any{
+		"data": result,
+	})
+}
+
+func NewHandler(usecase WorkspaceUsecase) WorkspaceHandler {
+	return Handler{
+		usecase: usecase,
+	}
+}
@@ -0,0 +1,33 @@
+package workspace
+
+import (
+	"context"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// tenants sharing the deployment, each with its own sender, templates, suppression list and batches
+// the workspace of a request comes from its api key, only the admins of the default workspace manage them
+
+type WorkspaceHandler interface {
+	GetWorkspaces(e echo.Context) error
+	GetWorkspace(e echo.Context) error
+	PostWorkspace(e echo.Context) error
+	PutWorkspace(e echo.Context) error
+}
+
+type WorkspaceUsecase interface {
+	GetWorkspaces(ctx context.Context) ([]models.Workspace, int, error)
+	GetWorkspace(ctx context.Context, id string) (models.Workspace, int, error)
+	CreateWorkspace(ctx context.Context, param models.PostWorkspaceRequest) (models.Workspace, int, error)
+	UpdateWorkspace(ctx context.Context, id string, param models.PutWorkspaceRequest) (models.Workspace, int, error)
+}
+
+type WorkspaceRepository interface {
+	CreateWorkspace(ctx context.Context, tx *sqlx.Tx, param models.Workspace) error
+	ReadWorkspace(ctx context.Context, db *sqlx.DB, id string) (models.Workspace, error)
+	ReadWorkspaces(ctx context.Context, db *sqlx.DB) ([]models.Workspace, error)
+	UpdateWorkspace(ctx context.Context, tx *sqlx.Tx, param models.Workspace) error
+}
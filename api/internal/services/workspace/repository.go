@@ -0,0 +1,73 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const workspaceColumns = `id, name, email_from, email_alias, smtp_host, smtp_port, smtp_username, smtp_password, created_at, updated_at`
+
+type Repository struct {
+}
+
+// CreateWorkspace implements WorkspaceRepository.
+func (r Repository) CreateWorkspace(ctx context.Context, tx *sqlx.Tx, param models.Workspace) error {
+	query := `
+		INSERT INTO workspaces (` + workspaceColumns + `)
+		VALUES (:id, :name, :email_from, :email_alias, :smtp_host, :smtp_port, :smtp_username, :smtp_password, :created_at, :updated_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, param)
+	return err
+}
+
+// ReadWorkspace implements WorkspaceRepository.
+func (r Repository) ReadWorkspace(ctx context.Context, db *sqlx.DB, id string) (models.Workspace, error) {
+	var result models.Workspace
+
+	query := `SELECT ` + workspaceColumns + ` FROM workspaces WHERE id = $1`
+	err := db.GetContext(ctx, &result, query, id)
+
+	return result, err
+}
+
+// ReadWorkspaces implements WorkspaceRepository.
+func (r Repository) ReadWorkspaces(ctx context.Context, db *sqlx.DB) ([]models.Workspace, error) {
+	results := []models.Workspace{}
+
+	query := `SELECT ` + workspaceColumns + ` FROM workspaces ORDER BY created_at`
+	err := db.SelectContext(ctx, &results, query)
+
+	return results, err
+}
+
+// UpdateWorkspace implements WorkspaceRepository.
+func (r Repository) UpdateWorkspace(ctx context.Context, tx *sqlx.Tx, param models.Workspace) error {
+	query := `
+		UPDATE workspaces
+		SET name = :name, email_from = :email_from, email_alias = :email_alias, smtp_host = :smtp_host, smtp_port = :smtp_port,
+			smtp_username = :smtp_username, smtp_password = :smtp_password, updated_at = :updated_at
+		WHERE id = :id`
+
+	res, err := tx.NamedExecContext(ctx, query, param)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func NewRepository() WorkspaceRepository {
+	return Repository{}
+}
@@ -0,0 +1,14 @@
+package workspace
+
+import (
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+func NewRoutes(e *echo.Echo, handler WorkspaceHandler, authorize apikey.Authorizer) {
+	e.GET("/workspace", handler.GetWorkspaces, authorize(models.ScopeAdmin))
+	e.POST("/workspace", handler.PostWorkspace, authorize(models.ScopeAdmin))
+	e.GET("/workspace/:id", handler.GetWorkspace, authorize(models.ScopeAdmin))
+	e.PUT("/workspace/:id", handler.PutWorkspace, authorize(models.ScopeAdmin))
+}
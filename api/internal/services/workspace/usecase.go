@@ -0,0 +1,146 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abbyfakhri/toa-api/internal/models"
+	"github.com/abbyfakhri/toa-api/internal/services/apikey"
+	"github.com/abbyfakhri/toa-api/internal/services/audit"
+	"github.com/abbyfakhri/toa-api/internal/utils"
+	"github.com/jmoiron/sqlx"
+	uuid "github.com/satori/go.uuid"
+)
+
+type Usecase struct {
+	db         *sqlx.DB
+	repository WorkspaceRepository
+	recorder   audit.Recorder
+}
+
+// GetWorkspaces implements WorkspaceUsecase.
+func (u Usecase) GetWorkspaces(ctx context.Context) ([]models.Workspace, int, error) {
+	if !apikey.IsOperator(ctx) {
+		return nil, http.StatusForbidden, errNotOperator
+	}
+
+	results, err := u.repository.ReadWorkspaces(ctx, u.db)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// GetWorkspace implements WorkspaceUsecase.
+// any admin can read its own workspace
+func (u Usecase) GetWorkspace(ctx context.Context, id string) (models.Workspace, int, error) {
+	if !apikey.IsOperator(ctx) && id != apikey.WorkspaceFromContext(ctx) {
+		return models.Workspace{}, http.StatusForbidden, errNotOperator
+	}
+
+	result, err := u.repository.ReadWorkspace(ctx, u.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Workspace{}, http.StatusNotFound, fmt.Errorf("workspace: %s not found", id)
+	}
+	if err != nil {
+		return models.Workspace{}, http.StatusInternalServerError, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// CreateWorkspace implements WorkspaceUsecase.
+func (u Usecase) CreateWorkspace(ctx context.Context, param models.PostWorkspaceRequest) (models.Workspace, int, error) {
+	if !apikey.IsOperator(ctx) {
+		return models.Workspace{}, http.StatusForbidden, errNotOperator
+	}
+
+	now := time.Now()
+	workspace := models.Workspace{
+		Id:           param.Id,
+		Name:         param.Name,
+		EmailFrom:    param.EmailFrom,
+		EmailAlias:   param.EmailAlias,
+		SmtpHost:     param.SmtpHost,
+		SmtpPort:     param.SmtpPort,
+		SmtpUsername: param.SmtpUsername,
+		SmtpPassword: param.SmtpPassword,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if workspace.Id == "" {
+		workspace.Id = uuid.NewV4().String()
+	}
+
+	err := utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.CreateWorkspace(ctx, tx, workspace); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionCreate, models.AuditResourceWorkspace, workspace.Id, nil, workspace)
+	})
+	if utils.IsUniqueViolation(err) {
+		return models.Workspace{}, http.StatusConflict, fmt.Errorf("workspace: %s already exists", workspace.Id)
+	}
+	if err != nil {
+		return models.Workspace{}, http.StatusInternalServerError, err
+	}
+
+	return workspace, http.StatusCreated, nil
+}
+
+// UpdateWorkspace implements WorkspaceUsecase.
+func (u Usecase) UpdateWorkspace(ctx context.Context, id string, param models.PutWorkspaceRequest) (models.Workspace, int, error) {
+	// the smtp settings decide who the workspace sends as, so only operators change them
+	if !apikey.IsOperator(ctx) {
+		return models.Workspace{}, http.StatusForbidden, errNotOperator
+	}
+
+	workspace, statusCode, err := u.GetWorkspace(ctx, id)
+	if err != nil {
+		return models.Workspace{}, statusCode, err
+	}
+
+	before := workspace
+	workspace.Name = param.Name
+	workspace.EmailFrom = param.EmailFrom
+	workspace.EmailAlias = param.EmailAlias
+	workspace.SmtpHost = param.SmtpHost
+	workspace.SmtpPort = param.SmtpPort
+	workspace.SmtpUsername = param.SmtpUsername
+	if param.SmtpPassword != "" {
+		workspace.SmtpPassword = param.SmtpPassword
+	}
+	workspace.UpdatedAt = time.Now()
+
+	err = utils.WithTx(ctx, u.db, func(tx *sqlx.Tx) error {
+		if err := u.repository.UpdateWorkspace(ctx, tx, workspace); err != nil {
+			return err
+		}
+
+		return u.recorder.Record(ctx, tx, models.AuditActionUpdate, models.AuditResourceWorkspace, workspace.Id, before, workspace)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Workspace{}, http.StatusNotFound, fmt.Errorf("workspace: %s not found", id)
+	}
+	if err != nil {
+		return models.Workspace{}, http.StatusInternalServerError, err
+	}
+
+	return workspace, http.StatusOK, nil
+}
+
+var errNotOperator = fmt.Errorf("only admins of the %s workspace can manage workspaces", models.DefaultWorkspaceId)
+
+func NewUsecase(db *sqlx.DB, repository WorkspaceRepository, recorder audit.Recorder) WorkspaceUsecase {
+	return Usecase{
+		db:         db,
+		repository: repository,
+		recorder:   recorder,
+	}
+}
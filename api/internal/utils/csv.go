@@ -6,17 +6,38 @@ import (
 	"io"
 )
 
+// CsvRecord is the target column of a row along with every column of that row
+type CsvRecord struct {
+	Value   string
+	Columns map[string]string
+}
+
 func ReadCsv(r io.Reader, targetColumn string) ([]string, error) {
+	_, records, err := ReadCsvRecords(r, targetColumn)
+	if err != nil {
+		return []string{}, err
+	}
+
+	results := []string{}
+	for _, record := range records {
+		results = append(results, record.Value)
+	}
+
+	return results, nil
+}
+
+// ReadCsvRecords is ReadCsv keeping the other columns, columns is the header in file order
+func ReadCsvRecords(r io.Reader, targetColumn string) (columns []string, results []CsvRecord, err error) {
 	reader := csv.NewReader(r)
 
 	records, err := reader.ReadAll()
 
 	if err != nil {
-		return []string{}, fmt.Errorf("fail to read records from csv file")
+		return nil, nil, fmt.Errorf("fail to read records from csv file")
 	}
 
 	if len(records) == 0 {
-		return []string{}, fmt.Errorf("no records found for csv file")
+		return nil, nil, fmt.Errorf("no records found for csv file")
 	}
 
 	// get column names
@@ -29,18 +50,28 @@ func ReadCsv(r io.Reader, targetColumn string) ([]string, error) {
 
 	targetIndex, isExist := colToIndex[targetColumn]
 	if !isExist {
-		return []string{}, fmt.Errorf("column: %s not found in the csv file", targetColumn)
+		return nil, nil, fmt.Errorf("column: %s not found in the csv file", targetColumn)
 	}
 
-	results := []string{}
+	results = []CsvRecord{}
 
 	for _, row := range records[1:] { // skip the first row
+		if targetIndex >= len(row) {
+			continue
+		}
+
+		record := CsvRecord{
+			Value:   row[targetIndex],
+			Columns: map[string]string{},
+		}
 		for index, col := range row {
-			if index == targetIndex {
-				results = append(results, col)
+			if index < len(cols) {
+				record.Columns[cols[index]] = col
 			}
 		}
+
+		results = append(results, record)
 	}
 
-	return results, nil
+	return cols, results, nil
 }
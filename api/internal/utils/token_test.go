@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestVerifyToken(t *testing.T) {
+	token := SignToken("secret", "unsubscribe", "batch:someone@example.org")
+
+	tests := []struct {
+		name    string
+		secret  string
+		purpose string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid token", secret: "secret", purpose: "unsubscribe", token: token, want: "batch:someone@example.org"},
+		{name: "other secret", secret: "other", purpose: "unsubscribe", token: token, wantErr: true},
+		{name: "other purpose", secret: "secret", purpose: "click", token: token, wantErr: true},
+		{name: "empty secret", secret: "", purpose: "unsubscribe", token: SignToken("", "unsubscribe", "payload"), wantErr: true},
+		{name: "tampered payload", secret: "secret", purpose: "unsubscribe", token: "b3RoZXI" + token[len(token)-23:], wantErr: true},
+		{name: "no signature", secret: "secret", purpose: "unsubscribe", token: "YmF0Y2g", wantErr: true},
+		{name: "empty token", secret: "secret", purpose: "unsubscribe", token: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := VerifyToken(test.secret, test.purpose, test.token)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("VerifyToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if payload != test.want {
+				t.Errorf("VerifyToken() = %q, want %q", payload, test.want)
+			}
+		})
+	}
+}
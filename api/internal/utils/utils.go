@@ -1 +1,45 @@
-package utils
\ No newline at end of file
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// WithTx runs fn inside a transaction, it commits when fn succeeds and rolls back otherwise
+func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// NormalizeEmail trims and lowercases an address so lookups are case insensitive
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsUniqueViolation reports whether err is postgres refusing a duplicate key
+func IsUniqueViolation(err error) bool {
+	return hasErrorCode(err, "23505")
+}
+
+// IsForeignKeyViolation reports whether err is postgres refusing a reference to a missing row
+func IsForeignKeyViolation(err error) bool {
+	return hasErrorCode(err, "23503")
+}
+
+func hasErrorCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+const verpPurpose = "verp"
+
+// VerpAddress encodes emailId into a return path such as bounces+42-1f2e3d4c5b6a7988@example.com
+// the tag signs the id so a forged bounce cannot name an email it was never sent for
+func VerpAddress(secret, prefix, domain string, emailId int) string {
+	id := strconv.Itoa(emailId)
+	return prefix + "+" + id + "-" + verpTag(secret, id) + "@" + domain
+}
+
+// ParseVerpAddress returns the email id encoded by VerpAddress, addresses with a missing or wrong tag are rejected
+// matching is case insensitive since some servers lowercase the envelope, the tag is lowercase hex for that reason
+func ParseVerpAddress(secret, prefix, domain, address string) (int, bool) {
+	if secret == "" {
+		return 0, false
+	}
+
+	address = NormalizeEmail(strings.Trim(strings.TrimSpace(address), "<>"))
+
+	local, host, found := strings.Cut(address, "@")
+	if !found || host != strings.ToLower(domain) {
+		return 0, false
+	}
+
+	encoded, found := strings.CutPrefix(local, strings.ToLower(prefix)+"+")
+	if !found {
+		return 0, false
+	}
+
+	id, tag, found := strings.Cut(encoded, "-")
+	if !found || !hmac.Equal([]byte(tag), []byte(verpTag(secret, id))) {
+		return 0, false
+	}
+
+	emailId, err := strconv.Atoi(id)
+	if err != nil || emailId <= 0 {
+		return 0, false
+	}
+
+	return emailId, true
+}
+
+// verpTag is 8 bytes of the hmac, short enough for a local part
+func verpTag(secret, id string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(verpPurpose + ":" + id))
+
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// SplitAddress returns the local part and domain of an address
+func SplitAddress(address string) (local string, domain string) {
+	index := strings.LastIndex(address, "@")
+	if index < 0 {
+		return address, ""
+	}
+
+	return address[:index], address[index+1:]
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVerpAddress(t *testing.T) {
+	address := VerpAddress("secret", "bounces", "example.com", 42)
+
+	tests := []struct {
+		name    string
+		secret  string
+		address string
+		want    int
+		wantOk  bool
+	}{
+		{name: "signed address", secret: "secret", address: address, want: 42, wantOk: true},
+		{name: "lowercased and bracketed", secret: "secret", address: "<" + strings.ToUpper(address) + ">", want: 42, wantOk: true},
+		{name: "other secret", secret: "other", address: address},
+		{name: "empty secret", secret: "", address: VerpAddress("", "bounces", "example.com", 42)},
+		{name: "unsigned id", secret: "secret", address: "bounces+42@example.com"},
+		{name: "tag of another id", secret: "secret", address: "bounces+43-" + strings.TrimPrefix(strings.TrimSuffix(address, "@example.com"), "bounces+42-") + "@example.com"},
+		{name: "other domain", secret: "secret", address: strings.Replace(address, "example.com", "example.org", 1)},
+		{name: "other prefix", secret: "secret", address: strings.Replace(address, "bounces", "replies", 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			emailId, ok := ParseVerpAddress(test.secret, "bounces", "example.com", test.address)
+			if ok != test.wantOk || emailId != test.want {
+				t.Errorf("ParseVerpAddress(%q) = %d, %v, want %d, %v", test.address, emailId, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
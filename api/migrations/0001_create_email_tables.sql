@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS email_batches (
+    id               VARCHAR(36) PRIMARY KEY,
+    "from"           VARCHAR(255) NOT NULL,
+    email_count      INTEGER      NOT NULL DEFAULT 0,
+    success_count    INTEGER      NOT NULL DEFAULT 0,
+    fail_count       INTEGER      NOT NULL DEFAULT 0,
+    suppressed_count INTEGER      NOT NULL DEFAULT 0,
+    start_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    finish_at        TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS emails (
+    id       SERIAL PRIMARY KEY,
+    batch_id VARCHAR(36)  NOT NULL REFERENCES email_batches (id) ON DELETE CASCADE,
+    email    VARCHAR(255) NOT NULL,
+    status   VARCHAR(32)  NOT NULL DEFAULT 'pending',
+    is_sent  BOOLEAN      NOT NULL DEFAULT FALSE,
+    sent_at  TIMESTAMPTZ,
+    log      TEXT
+);
+
+CREATE INDEX IF NOT EXISTS emails_batch_id_idx ON emails (batch_id);
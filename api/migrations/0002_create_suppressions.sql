@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS suppressions (
+    email      VARCHAR(255) PRIMARY KEY,
+    reason     VARCHAR(32)  NOT NULL,
+    source     VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS suppressions_reason_idx ON suppressions (reason);
@@ -0,0 +1,9 @@
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS message_id  VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS bounce_type VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS bounced_at  TIMESTAMPTZ;
+
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS bounce_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS emails_message_id_idx ON emails (message_id);
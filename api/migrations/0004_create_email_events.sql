@@ -0,0 +1,20 @@
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS track_opens BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS open_count      INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS first_opened_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_opened_at  TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS email_events (
+    id         BIGSERIAL PRIMARY KEY,
+    email_id   INTEGER      NOT NULL REFERENCES emails (id) ON DELETE CASCADE,
+    batch_id   VARCHAR(36)  NOT NULL REFERENCES email_batches (id) ON DELETE CASCADE,
+    type       VARCHAR(16)  NOT NULL,
+    user_agent TEXT         NOT NULL DEFAULT '',
+    ip         VARCHAR(64)  NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS email_events_batch_id_type_idx ON email_events (batch_id, type);
+CREATE INDEX IF NOT EXISTS email_events_email_id_idx ON email_events (email_id);
@@ -0,0 +1,9 @@
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS track_clicks BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS click_count      INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS first_clicked_at TIMESTAMPTZ;
+
+ALTER TABLE email_events
+    ADD COLUMN IF NOT EXISTS url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS utm_source   VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_medium   VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_term     VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS utm_content  VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS status     VARCHAR(32)  NOT NULL DEFAULT 'completed',
+    ADD COLUMN IF NOT EXISTS subject    TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS body       TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS template   TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS send_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS time_zone  VARCHAR(64)  NOT NULL DEFAULT 'UTC',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW();
+
+-- start_at is now set when the batch actually starts sending
+ALTER TABLE email_batches
+    ALTER COLUMN start_at DROP NOT NULL,
+    ALTER COLUMN start_at DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS email_batches_status_send_at_idx ON email_batches (status, send_at);
+CREATE INDEX IF NOT EXISTS emails_batch_id_status_idx ON emails (batch_id, status);
@@ -0,0 +1,3 @@
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS canceled_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS pending_count  INT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- set on the batches created to retry the failed recipients of another batch
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS parent_id VARCHAR(36) REFERENCES email_batches (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS email_batches_parent_id_idx ON email_batches (parent_id);
@@ -0,0 +1,34 @@
+-- per request webhook, the secret is needed to sign every delivery
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS webhook_url    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS webhook_secret TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id         VARCHAR(36) PRIMARY KEY,
+    url        TEXT        NOT NULL,
+    secret     TEXT        NOT NULL,
+    -- empty means every event
+    events     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id VARCHAR(36) REFERENCES webhook_subscriptions (id) ON DELETE SET NULL,
+    batch_id        VARCHAR(36) REFERENCES email_batches (id) ON DELETE CASCADE,
+    event_id        VARCHAR(36) NOT NULL,
+    event           VARCHAR(64) NOT NULL,
+    url             TEXT        NOT NULL,
+    secret          TEXT        NOT NULL,
+    payload         TEXT        NOT NULL,
+    status          VARCHAR(32) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    response_code   INTEGER,
+    error           TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_batch_id_idx ON webhook_deliveries (batch_id);
@@ -0,0 +1,3 @@
+-- progress reports and the final summary of a batch are emailed here
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS report_to VARCHAR(255) NOT NULL DEFAULT '';
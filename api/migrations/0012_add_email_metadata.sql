@@ -0,0 +1,6 @@
+-- the other columns of the csv row a recipient came from, kept for exports
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS metadata_columns TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           VARCHAR(36) PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    -- first characters of the key, enough to recognise it in a list
+    prefix       VARCHAR(16)  NOT NULL,
+    -- sha256 of the key, the key itself is never stored
+    key_hash     VARCHAR(64)  NOT NULL UNIQUE,
+    scopes       TEXT[]       NOT NULL DEFAULT '{}',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS api_key_id VARCHAR(36) REFERENCES api_keys (id) ON DELETE SET NULL;
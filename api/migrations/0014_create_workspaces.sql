@@ -0,0 +1,56 @@
+-- tenants sharing the deployment, everything a team owns carries its workspace
+CREATE TABLE IF NOT EXISTS workspaces (
+    id            VARCHAR(36)  PRIMARY KEY,
+    name          VARCHAR(255) NOT NULL,
+    -- a workspace without a smtp host sends through the server client and its sender
+    email_from    VARCHAR(255) NOT NULL DEFAULT '',
+    email_alias   VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_host     VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_port     VARCHAR(8)   NOT NULL DEFAULT '',
+    smtp_username VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_password TEXT         NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- the data that existed before workspaces belongs to the default one
+INSERT INTO workspaces (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+
+ALTER TABLE webhook_deliveries
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+
+-- an address suppressed by one workspace can still be mailed by another
+ALTER TABLE suppressions
+    ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36) NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+ALTER TABLE suppressions DROP CONSTRAINT IF EXISTS suppressions_pkey;
+ALTER TABLE suppressions ADD PRIMARY KEY (workspace_id, email);
+
+CREATE TABLE IF NOT EXISTS templates (
+    id           VARCHAR(36)  PRIMARY KEY,
+    workspace_id VARCHAR(36)  NOT NULL REFERENCES workspaces (id),
+    name         VARCHAR(255) NOT NULL,
+    subject      TEXT         NOT NULL,
+    body         TEXT         NOT NULL DEFAULT '',
+    template     TEXT         NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_workspace_id_idx ON api_keys (workspace_id);
+CREATE INDEX IF NOT EXISTS email_batches_workspace_id_idx ON email_batches (workspace_id);
+CREATE INDEX IF NOT EXISTS emails_workspace_id_idx ON emails (workspace_id);
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_workspace_id_idx ON webhook_subscriptions (workspace_id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_workspace_id_idx ON webhook_deliveries (workspace_id);
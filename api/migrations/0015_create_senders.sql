@@ -0,0 +1,28 @@
+-- the addresses a workspace sends as, a sender without a smtp host shares the connection of its workspace
+CREATE TABLE IF NOT EXISTS senders (
+    id               VARCHAR(36)  PRIMARY KEY,
+    workspace_id     VARCHAR(36)  NOT NULL REFERENCES workspaces (id),
+    name             VARCHAR(255) NOT NULL,
+    email_from       VARCHAR(255) NOT NULL,
+    email_alias      VARCHAR(255) NOT NULL DEFAULT '',
+    reply_to         VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_host        VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_port        VARCHAR(8)   NOT NULL DEFAULT '',
+    smtp_username    VARCHAR(255) NOT NULL DEFAULT '',
+    smtp_password    TEXT         NOT NULL DEFAULT '',
+    dkim_selector    VARCHAR(255) NOT NULL DEFAULT '',
+    dkim_domain      VARCHAR(255) NOT NULL DEFAULT '',
+    -- PEM encoded
+    dkim_private_key TEXT         NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, name)
+);
+
+-- a batch whose sender is deleted goes out as the workspace sender
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS sender_id VARCHAR(36) REFERENCES senders (id) ON DELETE SET NULL;
+
+-- empty means the key may use every sender of its workspace
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS sender_ids TEXT[] NOT NULL DEFAULT '{}';
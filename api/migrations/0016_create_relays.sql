@@ -0,0 +1,21 @@
+-- smtp servers a workspace fails over between, a workspace without relays sends through its own connection
+CREATE TABLE IF NOT EXISTS relays (
+    id                VARCHAR(36)  PRIMARY KEY,
+    workspace_id      VARCHAR(36)  NOT NULL REFERENCES workspaces (id),
+    name              VARCHAR(255) NOT NULL,
+    smtp_host         VARCHAR(255) NOT NULL,
+    smtp_port         VARCHAR(8)   NOT NULL,
+    smtp_username     VARCHAR(255) NOT NULL,
+    smtp_password     TEXT         NOT NULL DEFAULT '',
+    -- lowest first, relays of the same priority share the traffic by weight
+    priority          INT          NOT NULL DEFAULT 0,
+    weight            INT          NOT NULL DEFAULT 1,
+    -- routing rules, a relay with rules only carries the mail they match
+    recipient_domains TEXT[]       NOT NULL DEFAULT '{}',
+    sender_ids        TEXT[]       NOT NULL DEFAULT '{}',
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS relays_workspace_id_idx ON relays (workspace_id);
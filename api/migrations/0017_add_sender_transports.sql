@@ -0,0 +1,7 @@
+-- a sender sends over smtp or through the http api of a provider
+ALTER TABLE senders
+    ADD COLUMN IF NOT EXISTS transport  VARCHAR(16)  NOT NULL DEFAULT 'smtp',
+    ADD COLUMN IF NOT EXISTS api_url    TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS api_key    TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS api_secret TEXT         NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS api_region VARCHAR(32)  NOT NULL DEFAULT '';
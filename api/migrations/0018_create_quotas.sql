@@ -0,0 +1,19 @@
+-- caps on the emails an api key, a workspace or a sender is charged for per hour, day or month
+CREATE TABLE IF NOT EXISTS quotas (
+    id           VARCHAR(36) PRIMARY KEY,
+    workspace_id VARCHAR(36) NOT NULL REFERENCES workspaces (id),
+    scope        VARCHAR(16) NOT NULL,
+    scope_id     VARCHAR(36) NOT NULL,
+    period       VARCHAR(8)  NOT NULL,
+    max_emails   INT         NOT NULL CHECK (max_emails > 0),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, scope, scope_id, period)
+);
+
+-- the window an email is charged to, it is not sent before then
+-- emails from before quotas are left empty and are charged to no window
+ALTER TABLE emails
+    ADD COLUMN IF NOT EXISTS quota_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS emails_workspace_id_quota_at_idx ON emails (workspace_id, quota_at);
@@ -0,0 +1,3 @@
+-- a key gets the scopes of its role on top of its own, keys without a role only have their own
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT '';
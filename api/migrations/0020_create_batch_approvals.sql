@@ -0,0 +1,16 @@
+-- batches over the approval threshold or sent as these senders wait in pending_approval
+ALTER TABLE senders
+    ADD COLUMN IF NOT EXISTS requires_approval BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- every approve and reject decision, rows are never updated or deleted
+CREATE TABLE IF NOT EXISTS batch_approvals (
+    id           VARCHAR(36) PRIMARY KEY,
+    batch_id     VARCHAR(36) NOT NULL REFERENCES email_batches (id),
+    workspace_id VARCHAR(36) NOT NULL REFERENCES workspaces (id),
+    api_key_id   VARCHAR(36) REFERENCES api_keys (id) ON DELETE SET NULL,
+    decision     VARCHAR(16) NOT NULL,
+    comment      TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS batch_approvals_batch_id_idx ON batch_approvals (batch_id, created_at);
@@ -0,0 +1,31 @@
+-- who changed what and when, written in the same transaction as the change
+CREATE TABLE IF NOT EXISTS audit_log (
+    id           BIGSERIAL PRIMARY KEY,
+    workspace_id VARCHAR(36)  NOT NULL,
+    -- not a reference, the entries outlive the keys
+    api_key_id   VARCHAR(36),
+    api_key_name VARCHAR(255) NOT NULL DEFAULT '',
+    ip           VARCHAR(64)  NOT NULL DEFAULT '',
+    request_id   VARCHAR(64)  NOT NULL DEFAULT '',
+    action       VARCHAR(32)  NOT NULL,
+    resource     VARCHAR(32)  NOT NULL,
+    resource_id  VARCHAR(320) NOT NULL DEFAULT '',
+    before       JSONB,
+    after        JSONB,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_workspace_id_created_at_idx ON audit_log (workspace_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_resource_idx ON audit_log (resource, resource_id);
+
+-- the log is append only, even for the application
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,12 @@
+-- the batch each Idempotency-Key of a workspace created, a retry with the key gets the same batch back
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    workspace_id    VARCHAR(36)  NOT NULL REFERENCES workspaces (id),
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash    VARCHAR(64)  NOT NULL,
+    batch_id        VARCHAR(36)  NOT NULL REFERENCES email_batches (id) ON DELETE CASCADE,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (workspace_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
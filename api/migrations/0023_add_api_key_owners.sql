@@ -0,0 +1,7 @@
+-- the person a key is issued to, batches remember the owner of the key that requested them
+-- so an approval can be refused to the same person holding a second key
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS owner VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE email_batches
+    ADD COLUMN IF NOT EXISTS requested_by VARCHAR(255) NOT NULL DEFAULT '';
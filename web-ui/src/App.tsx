@@ -27,6 +27,11 @@ const API_BASE_URL = (() => {
   return raw.replace(/\/+$/, '')
 })()
 
+// the api only accepts requests carrying a key with the email:send scope
+const API_KEY = import.meta.env.VITE_API_KEY ?? ''
+
+const authHeaders = (): Record<string, string> => (API_KEY ? { Authorization: `Bearer ${API_KEY}` } : {})
+
 const TemplatePreview = ({ label, value }: { label: string; value: string }) => {
   const trimmed = value.trim()
   const iframeRef = useRef<HTMLIFrameElement | null>(null)
@@ -177,6 +182,7 @@ function App() {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify(payload),
     })
@@ -194,6 +200,7 @@ function App() {
   const postFormData = async (path: string, payload: FormData) => {
     const response = await fetch(`${API_BASE_URL}${path}`, {
       method: 'POST',
+      headers: authHeaders(),
       body: payload,
     })
     const data = await response.json().catch(() => ({}))
@@ -241,8 +248,8 @@ function App() {
         template: manualForm.template.trim(),
       }
 
-      const result = (await postJson('/email', payload)) as { data?: { batchId?: string } }
-      const batchId = result?.data?.batchId ?? 'N/A'
+      const result = (await postJson('/email', payload)) as { data?: { emailBatchId?: string } }
+      const batchId = result?.data?.emailBatchId ?? 'N/A'
 
       setManualStatus({
         type: 'success',
@@ -287,8 +294,8 @@ function App() {
       formData.append('targetColumn', csvForm.targetColumn.trim())
       formData.append('data', csvForm.file)
 
-      const result = (await postFormData('/email/csv', formData)) as { data?: { batchId?: string } }
-      const batchId = result?.data?.batchId ?? 'N/A'
+      const result = (await postFormData('/email/csv', formData)) as { data?: { emailBatchId?: string } }
+      const batchId = result?.data?.emailBatchId ?? 'N/A'
 
       setCsvStatus({
         type: 'success',